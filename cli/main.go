@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/razims/siebel_prometheus_exporter/pkg/exporter"
@@ -15,32 +21,134 @@ import (
 	"go.uber.org/zap"
 )
 
+var (
+	// Set at build time via -ldflags
+	version   = "dev"
+	buildTime = "unknown"
+)
+
 var (
 	// Command line arguments
-	listenAddress               = flag.String("web.listen-address", "0.0.0.0:9963", "Address to listen on for web interface and telemetry.")
+	listenAddress               = flag.String("web.listen-address", "0.0.0.0:9963", "Address to listen on for web interface and telemetry, or a Unix domain socket as unix:/path/to.sock.")
+	adminListenAddress          = flag.String("web.admin-listen-address", "", "Separate address, e.g. 127.0.0.1:9964, serving the logs, configuration, control and debug endpoints instead of -web.listen-address. Empty serves them together with the metrics.")
 	metricsPath                 = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
 	disableExporterMetrics      = flag.Bool("web.disable-exporter-metrics", false, "Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).")
 	disableLogs                 = flag.Bool("web.disable-logs", false, "Disable the /logs endpoint and in-memory log storage.")
+	disableCompression          = flag.Bool("web.disable-compression", false, "Do not compress the metrics response even if the client accepts gzip or zstd.")
+	routePrefix                 = flag.String("web.route-prefix", "", "Path prefix of all endpoints, e.g. /siebel when served under a subpath by a reverse proxy.")
+	enablePprof                 = flag.Bool("web.enable-pprof", false, "Serve the Go profiling endpoints under /debug/pprof/.")
+	pprofToken                  = flag.String("web.pprof-token", "", "Bearer token required by /debug/pprof/. Empty leaves the profiles open if enabled.")
+	logStreamBuffer             = flag.Int("web.log-stream-buffer", 100, "Log entries buffered per /logs/stream client. Entries beyond it are dropped for slow clients.")
+	enableMultiTarget           = flag.Bool("web.enable-multi-target", false, "Enable the /scrape endpoint to scrape arbitrary targets given by query parameters.")
+	shutdownTimeout             = flag.Duration("web.shutdown-timeout", 30*time.Second, "Maximum time to wait for in-flight requests and scrapes to complete on shutdown.")
+	enableCommandEndpoint       = flag.Bool("web.enable-command-endpoint", false, "Enable POST /debug/command, which runs arbitrary srvrmgr commands for troubleshooting.")
+	responseHeaders             = newStringSliceFlag("web.response-header", nil, "Header added to the metrics response as \"Name: Value\", e.g. \"X-Scope-OrgID: tenant1\". Repeat for several headers.")
+	tlsCertFile                 = flag.String("web.tls-cert-file", "", "Certificate file to serve the web interface over HTTPS, requires -web.tls-key-file.")
+	tlsKeyFile                  = flag.String("web.tls-key-file", "", "Private key file of -web.tls-cert-file.")
+	tlsClientCAFile             = flag.String("web.tls-client-ca-file", "", "CA certificates file to require and verify client certificates with (mTLS), requires -web.tls-cert-file.")
+	logLevelToken               = flag.String("web.log-level-token", "", "Bearer token required to change the log level with PUT /-/log-level. Empty refuses changes.")
+	controlToken                = flag.String("web.control-token", "", "Bearer token required to pause and resume scraping with POST /-/pause and /-/resume. Empty refuses both.")
+	commandEndpointToken        = flag.String("web.command-endpoint-token", "", "Bearer token required by /debug/command.")
 	maxProcs                    = flag.Int("runtime.gomaxprocs", 0, "The target number of CPUs Go will run on (GOMAXPROCS). 0 means use default (number of logical CPUs).")
 	gateway                     = flag.String("siebel.gateway", "", "Siebel Gateway server address.")
-	enterprise                  = flag.String("siebel.enterprise", "", "Siebel Enterprise name.")
-	server                      = flag.String("siebel.server", "", "Siebel Application server name.")
+	connectionMode              = flag.String("siebel.connection-mode", string(servermanager.GatewayMode), "How srvrmgr connects: \"gateway\" through -siebel.gateway, or \"direct\" to the application server at -siebel.server-address without a gateway.")
+	serverAddress               = flag.String("siebel.server-address", "", "Host and port of the application server srvrmgr connects to in direct connection mode.")
+	enterprise                  = flag.String("siebel.enterprise", "", "Siebel Enterprise name. Comma-separated list to scrape multiple enterprises of the gateway.")
+	server                      = flag.String("siebel.server", "", "Siebel Application server name. Comma-separated list to scrape multiple servers. ENTERPRISE/SERVER scrapes a server in one of several enterprises only.")
 	user                        = flag.String("siebel.user", "", "Siebel user name.")
 	password                    = flag.String("siebel.password", "", "Siebel user password.")
+	passwordFile                = flag.String("siebel.password-file", "", "File to read the Siebel user password from. Takes precedence over -siebel.password.")
 	srvrmgrPath                 = flag.String("siebel.srvrmgr-path", "srvrmgr", "Full path to srvrmgr executable.")
-	metricsFile                 = flag.String("siebel.metrics-file", "metrics.toml", "Metrics configuration file.")
-	dateFormat                  = flag.String("siebel.date-format", "2006-01-02 15:04:05", "Go datetime formatting layout to use with empty value.")
+	srvrmgrProbe                = flag.Bool("siebel.srvrmgr-probe", false, "Run \"srvrmgr /?\" at startup to confirm the srvrmgr executable can be run.")
+	normalizeCommands           = flag.Bool("siebel.normalize-commands", true, "Trim surrounding whitespace and trailing semicolons from commands before sending them to srvrmgr.")
+	drainQuietPeriod            = flag.Duration("siebel.drain-quiet-period", 100*time.Millisecond, "Discard pending srvrmgr output until it stays silent this long before sending a command. 0 disables draining.")
+	timeoutResyncWait           = flag.Duration("siebel.timeout-resync-wait", 10*time.Second, "How long srvrmgr gets to finish a timed-out command before the session is reconnected. 0 only skips its late output.")
+	resyncBeforeCommand         = flag.Bool("siebel.resync-before-command", true, "Before each command, discard output until the prompts of timed-out commands have arrived.")
+	mergeStderr                 = flag.Bool("siebel.merge-stderr", false, "Append srvrmgr stderr lines to the command output instead of only logging them.")
+	extraArgs                   = newStringSliceFlag("siebel.extra-arg", nil, "Argument appended to the srvrmgr command line after the connection flags, e.g. \"-l\" and \"enu\" as two arguments. Repeat for several, in order.")
+	startupCommands             = newStringSliceFlag("siebel.startup-command", nil, "srvrmgr command run right after connecting, e.g. \"set ColumnWidth true\". Repeat to run several in order; a failing command aborts the connection.")
+	promptPattern               = flag.String("siebel.prompt-pattern", servermanager.DefaultPromptPattern, "Regular expression matching the srvrmgr prompt.")
+	promptEndedPattern          = flag.String("siebel.prompt-ended-pattern", servermanager.DefaultPromptEndedPattern, "Regular expression matching the line that ends a srvrmgr result table, e.g. for localized srvrmgr.")
+	namespace                   = flag.String("siebel.namespace", "siebel", "Prefix of all exported metric names.")
+	enterpriseLabel             = flag.Bool("siebel.enterprise-label", false, "Add the Siebel enterprise as \"enterprise\" label to all metrics.")
+	enterpriseInNamespace       = flag.Bool("siebel.enterprise-in-namespace", false, "Append the Siebel enterprise to the metric namespace, e.g. siebel_<enterprise>_...")
+	metricsFile                 = flag.String("siebel.metrics-file", "metrics.toml", "Metrics configuration file, a path or an http(s) URL.")
+	customMetricsFiles          = flag.String("siebel.custom-metrics-files", "", "Comma-separated list of additional metrics files, appended to and overriding the default metrics by subsystem and command.")
+	metricsFileTimeout          = flag.Duration("siebel.metrics-file-timeout", exporter.DefaultRemoteMetricsConfig.Timeout, "Maximum time of a request fetching a metrics file given as URL.")
+	metricsFileMaxSize          = flag.Int64("siebel.metrics-file-max-size", exporter.DefaultRemoteMetricsConfig.MaxSize, "Maximum size in bytes of a metrics file given as URL. 0 for no limit.")
+	metricsFileRetries          = flag.Int("siebel.metrics-file-retries", exporter.DefaultRemoteMetricsConfig.Retries, "Additional attempts after a failed fetch of a metrics file given as URL.")
+	metricsFileRetryDelay       = flag.Duration("siebel.metrics-file-retry-delay", exporter.DefaultRemoteMetricsConfig.RetryDelay, "Wait before the first retry of a failed metrics file fetch, doubled after every further failure.")
+	dateFormats                 = newStringSliceFlag("siebel.date-format", []string{"2006-01-02 15:04:05"}, "Go datetime layout of date columns. Repeat to try several layouts in order.")
+	timeZone                    = flag.String("siebel.timezone", "UTC", "IANA time zone (e.g. Europe/Berlin) in which Siebel reports datetimes.")
+	defaultMetricType           = flag.String("siebel.default-metric-type", "gauge", "Type of metric columns without explicit Type in the metrics file: gauge or counter.")
 	disableEmptyMetricsOverride = flag.Bool("siebel.disable-empty-metrics-override", false, "Disable override of empty metrics in results with value of 0.")
+	collapseLabelWhitespace     = flag.Bool("siebel.collapse-label-whitespace", false, "Replace runs of whitespace within label values by a single space, so that cosmetically different values do not split series.")
+	dedupScrapeErrors           = flag.Bool("siebel.dedup-scrape-errors", false, "Log a scrape error repeating for the same metric in full only once and at debug level afterwards, until the metric is scraped again.")
+	ignoreZeroResult            = flag.Bool("siebel.ignore-zero-result", false, "Do not fail the scrape of a metric whose command returned rows but no metrics could be parsed from them, for all metrics.")
+	debugUnmappedColumns        = flag.Bool("siebel.debug-unmapped-columns", false, "Export siebel_debug_column{subsystem,column} for columns of the command output that the metric definition does not use. Meant for writing metrics files.")
 	disableExtendedMetrics      = flag.Bool("siebel.disable-extended-metrics", false, "Disable any metric defined as 'Extended' in metrics file.")
+	chunkSize                   = flag.Int("siebel.chunk-size", 1000, "Number of result rows converted to metrics at a time.")
+	forceGCBetweenChunks        = flag.Bool("siebel.force-gc-between-chunks", false, "Run the garbage collector between chunks of large results. Lowers peak memory at the cost of slower scrapes.")
+	scrapeConcurrency           = flag.Int("siebel.scrape-concurrency", 1, "Number of srvrmgr sessions per server used to run metric commands in parallel.")
+	poolIdleTimeout             = flag.Duration("siebel.pool-idle-timeout", 10*time.Minute, "Close additional srvrmgr sessions of -siebel.scrape-concurrency unused for this long. 0 keeps them open.")
+	maxScrapeMemory             = flag.Int64("siebel.max-scrape-memory", 0, "Estimated size in bytes above which a parsed command result is rejected. 0 means no limit.")
+	componentHealth             = flag.Bool("siebel.component-health", false, "Export siebel_components_running and siebel_components_down, counted from the rows of the component health command.")
+	componentHealthCommand      = flag.String("siebel.component-health-command", exporter.DefaultComponentHealthCommand, "srvrmgr command listing the server components and their state.")
+	componentHealthStatusColumn = flag.String("siebel.component-health-status-column", exporter.DefaultComponentHealthStatusColumn, "Column of the component health command holding the component state.")
+	componentRunningValues      = flag.String("siebel.component-running-values", strings.Join(exporter.DefaultComponentRunningValues, ","), "Comma-separated list of component states counted as running.")
+	maintenanceWindows          = flag.String("siebel.maintenance-windows", "", "Comma-separated list of recurring windows in the Siebel time zone during which scraping is paused, e.g. \"Mon-Fri 22:00-02:00,Sun 00:00-06:00\".")
 	autoReconnect               = flag.Bool("siebel.auto-reconnect", true, "Enable automatic reconnection if connection is lost.")
+	exitTimeout                 = flag.Duration("siebel.exit-timeout", 1*time.Second, "How long srvrmgr gets to exit cleanly after the exit command before it is killed. 0 always kills.")
 	reconnectDelay              = flag.Duration("siebel.reconnect-delay", 10*time.Second, "Delay between reconnection attempts.")
+	backoffInitial              = flag.Duration("siebel.backoff-initial", servermanager.DefaultBackoffConfig.InitialDelay, "Delay before the first reconnection attempt after the connection was lost.")
+	backoffMax                  = flag.Duration("siebel.backoff-max", servermanager.DefaultBackoffConfig.MaxDelay, "Maximum delay between reconnection attempts.")
+	backoffMultiplier           = flag.Float64("siebel.backoff-multiplier", servermanager.DefaultBackoffConfig.Multiplier, "Factor the delay grows by after every failed reconnection attempt, at least 1.")
+	backoffMaxRetries           = flag.Int("siebel.backoff-max-retries", servermanager.DefaultBackoffConfig.MaxRetries, "Number of reconnection attempts before giving up. 0 retries forever.")
+	backoffMaxElapsed           = flag.Duration("siebel.backoff-max-elapsed", 0, "Give up reconnecting after this long, however many retries are left. 0 means no limit.")
+	backoffJitter               = flag.Float64("siebel.backoff-jitter", servermanager.DefaultBackoffConfig.JitterFactor, "Random variation of the reconnection delay as fraction of it, from 0 to below 1.")
+	commandRetries              = flag.Int("siebel.command-retries", servermanager.DefaultCommandRetries, "How often a command that lost its connection is retried, each time after waiting for the reconnect. 0 disables the retry.")
+	commandRetryWait            = flag.Duration("siebel.command-retry-wait", 10*time.Second, "How long a command that lost its connection waits for the reconnect before it is retried. 0 disables the retry.")
+	serverDownIsScrapeError     = flag.Bool("siebel.server-down-is-scrape-error", true, "Count a down gateway or application server as scrape error. If false, the scrape succeeds and only the up metrics report the server as down.")
+	staleMetricsIsScrapeError   = flag.Bool("siebel.stale-metrics-is-scrape-error", false, "Count scrapes as failed while the previous metric definitions are used because the last reload of the metrics files failed.")
 	reconnectAfterScrape        = flag.Bool("siebel.reconnect-after-scrape", false, "Reconnect to server after each scrape")
+	reconnectWaitAttempts       = flag.Int("siebel.reconnect-wait-attempts", 5, "Number of times a scrape checks whether an ongoing reconnection completed before it fails.")
+	reconnectWaitInterval       = flag.Duration("siebel.reconnect-wait-interval", 500*time.Millisecond, "Interval between the checks of a scrape for an ongoing reconnection.")
+	watchdogFailures            = flag.Int("siebel.watchdog-failures", 0, "Number of failed heartbeats in a row after which the srvrmgr process is killed and started again. 0 disables the watchdog.")
+	watchdogWindow              = flag.Duration("siebel.watchdog-window", 10*time.Minute, "Time window in which the failed heartbeats must occur to trigger the watchdog. 0 means no window.")
+	watchdogMaxFailedRestarts   = flag.Int("siebel.watchdog-max-failed-restarts", 0, "Number of failed watchdog restarts in a row after which the exporter exits. 0 never exits.")
+	watchdogExitCode            = flag.Int("siebel.watchdog-exit-code", 1, "Exit code used when the watchdog gives up.")
+	collectInterval             = flag.Duration("collect.interval", time.Minute, "Interval of the internal collection feeding the push and file output modes.")
+	collectTextfile             = flag.String("collect.textfile", "", "Write the metrics to this file (e.g. for the node exporter textfile collector) on every collection. Empty disables the textfile mode.")
+	collectTextfileInterval     = flag.Duration("collect.textfile-interval", 0, "Collection interval of the textfile mode. 0 uses -collect.interval.")
+	pushGatewayURL              = flag.String("push.gateway-url", "", "Push the metrics to this Pushgateway on every collection, e.g. http://pushgateway:9091. Empty disables the push mode.")
+	pushJob                     = flag.String("push.job", "siebel_exporter", "Job label of the pushed metrics.")
+	pushGrouping                = newStringSliceFlag("push.grouping-label", nil, "Additional grouping label of the pushed metrics as \"name=value\". Repeat for several labels. The instance label is -exporter.instance-id.")
+	pushInterval                = flag.Duration("push.interval", 0, "Collection interval of the push mode. 0 uses -collect.interval.")
+	pushRetries                 = flag.Int("push.retries", 2, "Additional attempts after a failed push.")
+	pushRetryDelay              = flag.Duration("push.retry-delay", 5*time.Second, "Wait between push attempts.")
+	pushOnly                    = flag.Bool("push.only", false, "Only push the metrics, do not serve HTTP. Requires -push.gateway-url.")
+	instanceID                  = flag.String("exporter.instance-id", "", "Stable identity of this exporter, exposed in siebel_exporter_build_info and used as instance label when pushing. Defaults to the hostname.")
+	exemplars                   = flag.Bool("exporter.exemplars", false, "Attach the ID of the last scrape as exemplar to siebel_exporter_scrapes_total and siebel_exporter_scrape_errors_total. Only visible with the OpenMetrics format.")
+	exemplarLabels              = newStringSliceFlag("exporter.exemplar-label", nil, "Additional exemplar label as \"name=value\", e.g. \"service=siebel-exporter\". Repeat for several labels.")
+	pidFile                     = flag.String("pid-file", "", "Write the process ID to this file on startup and remove it on clean shutdown.")
+	validateMetrics             = flag.Bool("validate-metrics", false, "Validate the metrics files, print the problems found and exit without connecting to Siebel. Exits non-zero if any metric is invalid.")
 	logLevel                    = flag.String("log.level", "info", "Log level (debug, info, warn, error)")
+	logFormat                   = flag.String("log.format", "console", "Log line format: console or json.")
+	logOutput                   = flag.String("log.output", "stdout", "Comma-separated list of log outputs: stdout, stderr, syslog or a file path.")
+	logFile                     = flag.String("log.file", "", "Log file written in addition to -log.output. Startup fails if it cannot be written.")
+	logMaxSize                  = flag.Int("log.max-size", 100, "Size in megabytes at which log files are rotated. 0 disables rotation.")
+	logMaxBackups               = flag.Int("log.max-backups", 0, "Number of rotated log files to keep. 0 keeps all.")
+	logMaxAge                   = flag.Duration("log.max-age", 0, "Rotated log files older than this are removed, e.g. 168h. 0 keeps them.")
+	logSyslogAddress            = flag.String("log.syslog-address", "", "Syslog server address like udp://host:514. Empty logs to the local syslog daemon.")
+	logSyslogTag                = flag.String("log.syslog-tag", "siebel_exporter", "Tag of log messages sent to syslog.")
 )
 
 func main() {
 	flag.Parse()
 
+	// Fill in flags that were not given on the command line from the environment
+	envFlags := applyEnvironmentFallback()
+
 	// Set GOMAXPROCS if specified
 	if *maxProcs > 0 {
 		runtime.GOMAXPROCS(*maxProcs)
@@ -71,88 +179,654 @@ func main() {
 		normalizedLevel = "info"
 	}
 
+	normalizedFormat := strings.ToLower(*logFormat)
+	if normalizedFormat != "console" && normalizedFormat != "json" {
+		fmt.Printf("Warning: Invalid log format '%s', defaulting to 'console'\n", *logFormat)
+		normalizedFormat = "console"
+	}
+
 	// Set disabled logs flag before initializing logger
 	logger.SetDisableLogs(*disableLogs)
 
-	// Initialize the logger with the validated level
-	logger.Init(logger.Level(normalizedLevel))
+	// An explicitly configured log file must be writable, logs would silently go missing otherwise
+	logOutputs := splitList(*logOutput)
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Printf("Error: cannot write log file: %v\n", err)
+			os.Exit(1)
+		}
+		f.Close()
+		if len(logOutputs) == 0 {
+			logOutputs = []string{"stdout"}
+		}
+		logOutputs = append(logOutputs, *logFile)
+	}
+
+	// Initialize the logger with the validated level and the configured outputs
+	logErr := logger.InitWithConfig(logger.Config{
+		Level:         logger.Level(normalizedLevel),
+		Format:        normalizedFormat,
+		Outputs:       logOutputs,
+		SyslogAddress: *logSyslogAddress,
+		SyslogTag:     *logSyslogTag,
+		Rotation: logger.RotationConfig{
+			MaxSize:    *logMaxSize,
+			MaxBackups: *logMaxBackups,
+			MaxAge:     *logMaxAge,
+		},
+	})
 	defer logger.Sync()
+	if logErr != nil {
+		logger.Warn("Some log outputs could not be opened", zap.Error(logErr))
+	}
+
+	if *metricsFileTimeout <= 0 || *metricsFileRetries < 0 {
+		logger.Error("Invalid metrics file fetch limits, the timeout must be positive and the retries not negative",
+			zap.Duration("timeout", *metricsFileTimeout),
+			zap.Int("retries", *metricsFileRetries))
+		os.Exit(1)
+	}
+	exporter.SetRemoteMetricsConfig(exporter.RemoteMetricsConfig{
+		Timeout:    *metricsFileTimeout,
+		MaxSize:    *metricsFileMaxSize,
+		Retries:    *metricsFileRetries,
+		RetryDelay: *metricsFileRetryDelay,
+	})
+
+	// Check the metrics files only, e.g. in CI before deploying them
+	if *validateMetrics {
+		os.Exit(runMetricsValidation(*metricsFile, splitList(*customMetricsFiles)))
+	}
+
+	if *pidFile != "" {
+		if err := writePIDFile(*pidFile); err != nil {
+			logger.Error("Cannot write PID file", zap.Error(err))
+			os.Exit(1)
+		}
+	}
 
 	logger.Info("Starting Siebel Exporter",
+		zap.String("version", version),
+		zap.String("buildTime", buildTime),
 		zap.String("logLevel", normalizedLevel))
 
+	// The instance identity defaults to the hostname
+	if *instanceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			logger.Warn("Unable to determine hostname for the instance ID", zap.Error(err))
+			hostname = "unknown"
+		}
+		*instanceID = hostname
+	}
+
+	if len(envFlags) > 0 {
+		// Only flag names are logged, values may contain secrets
+		logger.Info("Resolved flags from environment variables (command-line flags take precedence)",
+			zap.Strings("flags", envFlags))
+	}
+
 	// Test log level
 	logger.Debug("This is a DEBUG message - you should see this if debug level is enabled")
 
 	// Create a ServerManagerConfig from command line arguments
 	smConfig := servermanager.ServerManagerConfig{
-		Gateway:        *gateway,
-		Enterprise:     *enterprise,
-		Server:         *server,
-		User:           *user,
-		Password:       *password,
-		SrvrmgrPath:    *srvrmgrPath,
-		AutoReconnect:  *autoReconnect,
-		ReconnectDelay: *reconnectDelay,
-		BackoffConfig:  servermanager.DefaultBackoffConfig,
+		Gateway:             *gateway,
+		Enterprise:          *enterprise,
+		Server:              *server,
+		User:                *user,
+		Password:            *password,
+		PasswordFile:        *passwordFile,
+		ConnectionMode:      servermanager.ConnectionMode(*connectionMode),
+		ServerAddress:       *serverAddress,
+		ExtraArgs:           extraArgs.values,
+		SrvrmgrPath:         *srvrmgrPath,
+		NormalizeCommands:   *normalizeCommands,
+		DrainQuietPeriod:    *drainQuietPeriod,
+		TimeoutResyncWait:   *timeoutResyncWait,
+		ResyncBeforeCommand: *resyncBeforeCommand,
+		MergeStderr:         *mergeStderr,
+		StartupCommands:     startupCommands.values,
+		PromptPattern:       *promptPattern,
+		PromptEndedPattern:  *promptEndedPattern,
+		AutoReconnect:       *autoReconnect,
+		ReconnectDelay:      *reconnectDelay,
+		CommandRetryWait:    *commandRetryWait,
+		CommandRetries:      *commandRetries,
+		ExitTimeout:         *exitTimeout,
+		BackoffConfig: servermanager.BackoffConfig{
+			InitialDelay:   *backoffInitial,
+			MaxDelay:       *backoffMax,
+			Multiplier:     *backoffMultiplier,
+			MaxRetries:     *backoffMaxRetries,
+			JitterFactor:   *backoffJitter,
+			MaxElapsedTime: *backoffMaxElapsed,
+		},
+		Watchdog: servermanager.WatchdogConfig{
+			Failures:          *watchdogFailures,
+			Window:            *watchdogWindow,
+			MaxFailedRestarts: *watchdogMaxFailedRestarts,
+			ExitCode:          *watchdogExitCode,
+		},
+	}
+
+	if err := smConfig.BackoffConfig.Validate(); err != nil {
+		logger.Error("Invalid reconnection backoff", zap.Error(err))
+		os.Exit(1)
+	}
+	if smConfig.AutoReconnect {
+		logger.Info("Reconnection backoff",
+			zap.Duration("initialDelay", smConfig.BackoffConfig.InitialDelay),
+			zap.Duration("maxDelay", smConfig.BackoffConfig.MaxDelay),
+			zap.Float64("multiplier", smConfig.BackoffConfig.Multiplier),
+			zap.Int("maxRetries", smConfig.BackoffConfig.MaxRetries),
+			zap.Duration("maxElapsedTime", smConfig.BackoffConfig.MaxElapsedTime),
+			zap.Float64("jitter", smConfig.BackoffConfig.JitterFactor))
+	}
+
+	// Prompt patterns that do not compile would make every command time out
+	if err := smConfig.ValidatePromptPatterns(); err != nil {
+		logger.Error("Invalid srvrmgr prompt pattern", zap.Error(err))
+		os.Exit(1)
 	}
 
 	// Validate configuration
-	if smConfig.Gateway == "" || smConfig.Enterprise == "" || smConfig.Server == "" ||
-		smConfig.User == "" || smConfig.Password == "" || smConfig.SrvrmgrPath == "" {
-		logger.Error("Missing required parameters. All Siebel connection parameters are required.")
+	if err := smConfig.ValidateConnection(); err != nil {
+		logger.Error("Missing or invalid Siebel connection parameters", zap.Error(err))
+		flag.Usage()
+		os.Exit(1)
+	}
+	// Multi-target scrapes select the gateway with the target parameter
+	if *enableMultiTarget && smConfig.ConnectionMode == servermanager.DirectMode {
+		logger.Error("-web.enable-multi-target requires the gateway connection mode")
+		os.Exit(1)
+	}
+
+	// A missing srvrmgr would only show up as a confusing error when starting it
+	if _, err := smConfig.ResolveSrvrmgrPath(); err != nil {
+		logger.Error("Invalid srvrmgr path", zap.Error(err))
+		os.Exit(1)
+	}
+	if *srvrmgrProbe {
+		if err := smConfig.ProbeSrvrmgr(10 * time.Second); err != nil {
+			logger.Error("Probing srvrmgr failed", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	if smConfig.PasswordFile != "" && smConfig.Password != "" {
+		logger.Warn("Both password and password file are set, the password file takes precedence",
+			zap.String("passwordFile", smConfig.PasswordFile))
+	}
+
+	// Create a ServerManager instance for every configured application server of every enterprise
+	serverTargets, err := expandServerTargets(splitList(smConfig.Enterprise), splitList(smConfig.Server))
+	if err != nil {
+		logger.Error("Invalid Siebel servers", zap.Error(err))
+		os.Exit(1)
+	}
+	// The server address belongs to one application server
+	if smConfig.ConnectionMode == servermanager.DirectMode && len(serverTargets) > 1 {
+		logger.Error("The direct connection mode connects to a single application server, -siebel.server must name one")
+		os.Exit(1)
+	}
+	if *enterpriseInNamespace && len(splitList(smConfig.Enterprise)) > 1 {
+		logger.Error("-siebel.enterprise-in-namespace requires a single enterprise, use -siebel.enterprise-label with several")
+		os.Exit(1)
+	}
+	var srvrmgrs []*servermanager.ServerManager
+	for _, serverTarget := range serverTargets {
+		serverConfig := smConfig
+		serverConfig.Enterprise = serverTarget.enterprise
+		serverConfig.Server = serverTarget.server
+		srvrmgrs = append(srvrmgrs, servermanager.NewServerManager(serverConfig))
+	}
+
+	if len(srvrmgrs) == 0 {
+		logger.Error("Missing required parameters. At least one Siebel server is required.")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Create ServerManager instance
-	sm := servermanager.NewServerManager(smConfig)
+	// An unknown time zone would silently skew every exported timestamp
+	if _, err := time.LoadLocation(*timeZone); err != nil {
+		logger.Error("Invalid time zone", zap.String("timeZone", *timeZone), zap.Error(err))
+		os.Exit(1)
+	}
+
+	if t := strings.ToLower(*defaultMetricType); t != "gauge" && t != "counter" {
+		logger.Error("Invalid default metric type, must be gauge or counter", zap.String("type", *defaultMetricType))
+		os.Exit(1)
+	}
+
+	windows, err := exporter.ParseMaintenanceWindows(*maintenanceWindows)
+	if err != nil {
+		logger.Error("Invalid maintenance windows", zap.Error(err))
+		os.Exit(1)
+	}
+
+	exemplarLabelValues, err := parseLabelPairs(exemplarLabels.values)
+	if err == nil {
+		err = exporter.ValidateExemplarLabels(exemplarLabelValues)
+	}
+	if err != nil {
+		logger.Error("Invalid exemplar label", zap.Error(err))
+		os.Exit(1)
+	}
+
+	headers, err := parseResponseHeaders(responseHeaders.values)
+	if err != nil {
+		logger.Error("Invalid response header", zap.Error(err))
+		os.Exit(1)
+	}
 
-	// Try to connect to Siebel Server Manager
-	logger.Info("Connecting to Siebel Server Manager...",
-		zap.String("gateway", smConfig.Gateway),
-		zap.String("enterprise", smConfig.Enterprise),
-		zap.String("server", smConfig.Server))
+	// The command endpoint allows arbitrary srvrmgr execution and must never be open
+	if *enableCommandEndpoint && *commandEndpointToken == "" {
+		logger.Error("The command endpoint requires -web.command-endpoint-token")
+		os.Exit(1)
+	}
 
-	if err := sm.Connect(); err != nil {
-		logger.Error("Failed to connect to Siebel Server Manager", zap.Error(err))
+	// Outbound modes collect on an internal schedule and need a positive interval
+	if *collectTextfile != "" && collectionInterval(*collectTextfileInterval) <= 0 {
+		logger.Error("Invalid textfile collection interval, must be positive",
+			zap.Duration("interval", collectionInterval(*collectTextfileInterval)))
+		os.Exit(1)
+	}
+	var pushSink *exporter.PushSink
+	if *pushGatewayURL != "" {
+		grouping, err := parseGroupingLabels(pushGrouping.values)
+		if err != nil {
+			logger.Error("Invalid push grouping label", zap.Error(err))
+			os.Exit(1)
+		}
+		grouping["instance"] = *instanceID
+		pushSink, err = exporter.NewPushSink(exporter.PushConfig{
+			URL:        *pushGatewayURL,
+			Job:        *pushJob,
+			Grouping:   grouping,
+			Retries:    *pushRetries,
+			RetryDelay: *pushRetryDelay,
+		}, *namespace)
+		if err != nil {
+			logger.Error("Invalid push configuration", zap.Error(err))
+			os.Exit(1)
+		}
+		if collectionInterval(*pushInterval) <= 0 {
+			logger.Error("Invalid push collection interval, must be positive",
+				zap.Duration("interval", collectionInterval(*pushInterval)))
+			os.Exit(1)
+		}
+	} else if *pushOnly {
+		logger.Error("-push.only requires -push.gateway-url")
 		os.Exit(1)
 	}
-	logger.Info("Successfully connected to Siebel Server Manager")
+
+	// Connect only now that all flags are checked, so that a typo does not start
+	// srvrmgr sessions that are abandoned right away
+	for _, sm := range srvrmgrs {
+		logger.Info("Connecting to Siebel Server Manager...",
+			zap.String("gateway", smConfig.Gateway),
+			zap.String("enterprise", sm.GetConfig().Enterprise),
+			zap.String("server", sm.GetConfig().Server))
+
+		if err := sm.Connect(); err != nil {
+			logger.Error("Failed to connect to Siebel Server Manager",
+				zap.String("server", sm.GetConfig().Server),
+				zap.Error(err))
+			os.Exit(1)
+		}
+	}
+	logger.Info("Successfully connected to Siebel Server Manager", zap.Int("servers", len(srvrmgrs)))
 
 	// Create exporter configuration
 	exporterConfig := &exporter.ExporterConfig{
 		ServerManagerConfig:         &smConfig,
-		MetricsFile:                 *metricsFile,
-		DateFormat:                  *dateFormat,
+		InstanceID:                  *instanceID,
+		EnterpriseLabel:             *enterpriseLabel,
+		EnterpriseInNamespace:       *enterpriseInNamespace,
+		Namespace:                   *namespace,
+		DefaultMetricsFile:          *metricsFile,
+		CustomMetricsFiles:          splitList(*customMetricsFiles),
+		DateFormats:                 dateFormats.values,
+		TimeZone:                    *timeZone,
+		DefaultMetricType:           strings.ToLower(*defaultMetricType),
 		DisableEmptyMetricsOverride: *disableEmptyMetricsOverride,
 		DisableExtendedMetrics:      *disableExtendedMetrics,
+		DebugUnmappedColumns:        *debugUnmappedColumns,
+		IgnoreZeroResult:            *ignoreZeroResult,
+		DedupScrapeErrors:           *dedupScrapeErrors,
+		CollapseLabelWhitespace:     *collapseLabelWhitespace,
 		ReconnectAfterScrape:        *reconnectAfterScrape,
+		ServerDownIsScrapeError:     *serverDownIsScrapeError,
+		StaleMetricsIsScrapeError:   *staleMetricsIsScrapeError,
+		ReconnectWaitAttempts:       *reconnectWaitAttempts,
+		ReconnectWaitInterval:       *reconnectWaitInterval,
+		ChunkSize:                   *chunkSize,
+		ForceGCBetweenChunks:        *forceGCBetweenChunks,
+		MaxScrapeMemory:             *maxScrapeMemory,
+		ScrapeConcurrency:           *scrapeConcurrency,
+		PoolIdleTimeout:             *poolIdleTimeout,
+		MaintenanceWindows:          windows,
+		ComponentHealth: exporter.ComponentHealthConfig{
+			Enabled:       *componentHealth,
+			Command:       *componentHealthCommand,
+			StatusColumn:  *componentHealthStatusColumn,
+			RunningValues: splitList(*componentRunningValues),
+		},
+		Exemplars:      *exemplars,
+		ExemplarLabels: exemplarLabelValues,
 	}
 
 	// Create exporter
-	siebelExporter := exporter.NewExporter(sm, exporterConfig)
+	siebelExporter := exporter.NewExporter(srvrmgrs, exporterConfig)
 
 	// Create web server config
 	webConfig := web.ServerConfig{
 		ListenAddress:          *listenAddress,
+		AdminListenAddress:     *adminListenAddress,
 		MetricsPath:            *metricsPath,
 		DisableExporterMetrics: *disableExporterMetrics,
 		DisableLogs:            *disableLogs,
+		LogStreamBuffer:        *logStreamBuffer,
+		RoutePrefix:            *routePrefix,
+		DisableCompression:     *disableCompression,
+		EnablePprof:            *enablePprof,
+		PprofToken:             *pprofToken,
+		EnableMultiTarget:      *enableMultiTarget,
+		EnableCommandEndpoint:  *enableCommandEndpoint,
+		CommandEndpointToken:   *commandEndpointToken,
+		LogLevelToken:          *logLevelToken,
+		ControlToken:           *controlToken,
+		TLSCertFile:            *tlsCertFile,
+		TLSKeyFile:             *tlsKeyFile,
+		TLSClientCAFile:        *tlsClientCAFile,
+		ResponseHeaders:        headers,
 	}
 
 	// Create and start web server
-	webServer := web.NewServer(webConfig, &smConfig, exporterConfig, normalizedLevel)
+	webServer := web.NewServer(webConfig, &smConfig, exporterConfig)
 	webServer.RegisterExporter(siebelExporter)
+	webServer.RegisterCollector(exporter.NewBuildInfo(version, buildTime, exporterConfig))
+	if !*disableLogs {
+		webServer.RegisterCollector(exporter.NewLogStreamCollector(exporterConfig))
+	}
 
-	// Setup shutdown hook to disconnect ServerManager on exit
-	defer func() {
-		logger.Info("Disconnecting from Siebel Server Manager...")
-		if err := sm.Disconnect(); err != nil {
-			logger.Error("Error during disconnection from Siebel Server Manager", zap.Error(err))
+	// Outbound modes collect on an internal schedule instead of being scraped
+	var schedulers []*exporter.Scheduler
+	if *collectTextfile != "" {
+		scheduler, err := exporter.NewScheduler(webServer.Gatherer(), exporter.NewTextfileSink(*collectTextfile),
+			collectionInterval(*collectTextfileInterval))
+		if err != nil {
+			logger.Error("Invalid textfile collection interval", zap.Error(err))
+			os.Exit(1)
 		}
+		schedulers = append(schedulers, scheduler)
+	}
+	if pushSink != nil {
+		webServer.RegisterCollector(pushSink)
+		scheduler, err := exporter.NewScheduler(webServer.Gatherer(), pushSink, collectionInterval(*pushInterval))
+		if err != nil {
+			logger.Error("Invalid push collection interval", zap.Error(err))
+			os.Exit(1)
+		}
+		schedulers = append(schedulers, scheduler)
+	}
+
+	collectCtx, stopCollect := context.WithCancel(context.Background())
+	var collectWG sync.WaitGroup
+	for _, scheduler := range schedulers {
+		collectWG.Add(1)
+		go func() {
+			defer collectWG.Done()
+			scheduler.Run(collectCtx)
+		}()
+	}
+	collectDone := make(chan struct{})
+	go func() {
+		collectWG.Wait()
+		close(collectDone)
 	}()
 
-	// Start web server (this blocks until server shutdown)
-	logger.Error("HTTP server error", zap.Error(webServer.Start()))
+	// Start web server in the background so that shutdown signals can be handled
+	serverErr := make(chan error, 1)
+	if *pushOnly {
+		logger.Info("Push only mode, not serving HTTP")
+	} else {
+		go func() {
+			serverErr <- webServer.Start()
+		}()
+	}
+
+	// Wait for a shutdown signal or for the web server to fail
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case sig := <-signals:
+		logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
+	case err := <-serverErr:
+		if err != nil {
+			logger.Error("HTTP server error", zap.Error(err))
+			exitCode = 1
+		}
+	}
+	signal.Stop(signals)
+
+	// Stop accepting new requests and let in-flight scrapes finish
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	stopCollect()
+	select {
+	case <-collectDone:
+	case <-ctx.Done():
+	}
+	if err := webServer.Stop(ctx); err != nil {
+		logger.Error("Error during HTTP server shutdown", zap.Error(err))
+	}
+
+	// A scrape may still be running if a request was abandoned, let it complete
+	if err := siebelExporter.WaitForScrape(ctx); err != nil {
+		logger.Warn("Disconnecting with a scrape still in progress", zap.Error(err))
+	}
+
+	// Disconnect ServerManagers so the srvrmgr child processes are cleaned up
+	logger.Info("Disconnecting from Siebel Server Manager...")
+	siebelExporter.Close()
+	for _, sm := range srvrmgrs {
+		if err := sm.Disconnect(); err != nil {
+			logger.Error("Error during disconnection from Siebel Server Manager",
+				zap.String("server", sm.GetConfig().Server),
+				zap.Error(err))
+		}
+	}
+
+	logger.Info("Siebel Exporter stopped")
+	if *pidFile != "" {
+		removePIDFile(*pidFile)
+	}
+	logger.Sync()
+	os.Exit(exitCode)
+}
+
+// collectionInterval returns the interval of an output mode, falling back to -collect.interval
+func collectionInterval(modeInterval time.Duration) time.Duration {
+	if modeInterval > 0 {
+		return modeInterval
+	}
+	return *collectInterval
+}
+
+// runMetricsValidation prints a summary of the problems of the metrics files and
+// returns the exit code
+func runMetricsValidation(metricsFile string, customMetricsFiles []string) int {
+	count, problems := exporter.ValidateMetrics(metricsFile, customMetricsFiles)
+
+	files := append([]string{metricsFile}, customMetricsFiles...)
+	fmt.Printf("Validated %d metrics from %s\n", count, strings.Join(files, ", "))
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+		return 0
+	}
+
+	fmt.Printf("%d problems found:\n", len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  - %v\n", problem)
+	}
+	return 1
+}
+
+// parseResponseHeaders parses "Name: Value" pairs into a header map
+func parseResponseHeaders(values []string) (map[string]string, error) {
+	headers := make(map[string]string, len(values))
+	for _, value := range values {
+		name, headerValue, found := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%q is not of the form \"Name: Value\"", value)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+// serverTarget is an application server of an enterprise scraped by the exporter
+type serverTarget struct {
+	enterprise, server string
+}
+
+// expandServerTargets pairs the configured servers with the enterprises. A plain
+// server is scraped in every enterprise, ENTERPRISE/SERVER only in that enterprise.
+func expandServerTargets(enterprises, servers []string) ([]serverTarget, error) {
+	var targets []serverTarget
+	seen := make(map[serverTarget]bool)
+	add := func(t serverTarget) {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	for _, server := range servers {
+		if enterpriseName, serverName, qualified := strings.Cut(server, "/"); qualified {
+			if !slices.Contains(enterprises, enterpriseName) {
+				return nil, fmt.Errorf("server %q belongs to enterprise %q, which is not in -siebel.enterprise", server, enterpriseName)
+			}
+			if serverName == "" {
+				return nil, fmt.Errorf("server %q has no server name", server)
+			}
+			add(serverTarget{enterprise: enterpriseName, server: serverName})
+			continue
+		}
+		for _, enterpriseName := range enterprises {
+			add(serverTarget{enterprise: enterpriseName, server: server})
+		}
+	}
+	return targets, nil
+}
+
+// parseLabelPairs parses "name=value" label flags
+func parseLabelPairs(values []string) (map[string]string, error) {
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		name, labelValue, found := strings.Cut(value, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("%q is not of the form \"name=value\"", value)
+		}
+		labels[name] = strings.TrimSpace(labelValue)
+	}
+	return labels, nil
+}
+
+// parseGroupingLabels parses the grouping labels of the push mode
+func parseGroupingLabels(values []string) (map[string]string, error) {
+	labels, err := parseLabelPairs(values)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"job", "instance"} {
+		if _, exists := labels[name]; exists {
+			return nil, fmt.Errorf("the %s label is set by -push.job and -exporter.instance-id", name)
+		}
+	}
+	return labels, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// stringSliceFlag is a repeatable string flag. Values given on the command line
+// replace the default instead of being appended to it.
+type stringSliceFlag struct {
+	values []string
+	set    bool
+}
+
+// newStringSliceFlag defines a repeatable string flag with default values
+func newStringSliceFlag(name string, defaults []string, usage string) *stringSliceFlag {
+	f := &stringSliceFlag{values: defaults}
+	flag.Var(f, name, usage)
+	return f
+}
+
+func (f *stringSliceFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, ", ")
+}
+
+func (f *stringSliceFlag) Set(value string) error {
+	if !f.set {
+		f.values = nil
+		f.set = true
+	}
+	f.values = append(f.values, value)
+	return nil
+}
+
+// envVarName returns the environment variable used as fallback for a flag,
+// e.g. SIEBEL_PASSWORD for siebel.password.
+func envVarName(flagName string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flagName))
+}
+
+// applyEnvironmentFallback sets every flag that was not passed on the command
+// line from its environment variable, so flags always take precedence.
+// It returns the names of the flags that were resolved from the environment.
+func applyEnvironmentFallback() []string {
+	setOnCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	var resolved []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if setOnCommandLine[f.Name] {
+			return
+		}
+
+		value, ok := os.LookupEnv(envVarName(f.Name))
+		if !ok {
+			return
+		}
+
+		if err := flag.Set(f.Name, value); err != nil {
+			fmt.Printf("Warning: Invalid value in environment variable %s: %v\n", envVarName(f.Name), err)
+			return
+		}
+		resolved = append(resolved, f.Name)
+	})
+
+	return resolved
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// writePIDFile writes the PID of this process to path. An existing file of a
+// running process is an error, a stale one from an unclean shutdown is replaced.
+func writePIDFile(path string) error {
+	if content, err := os.ReadFile(path); err == nil {
+		pid, parseErr := strconv.Atoi(strings.TrimSpace(string(content)))
+		if parseErr == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("PID file %s belongs to running process %d, is another exporter running?", path, pid)
+		}
+		logger.Warn("Replacing stale PID file",
+			zap.String("pidFile", path),
+			zap.String("content", strings.TrimSpace(string(content))))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading PID file: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing PID file: %w", err)
+	}
+	return nil
+}
+
+// removePIDFile removes the PID file on shutdown
+func removePIDFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Error removing PID file", zap.String("pidFile", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// processRunning reports whether a process with the given PID exists
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks for existence; EPERM means it exists but belongs to another user
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,23 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewBuildInfo returns a siebel_exporter_build_info gauge with the value 1, labeled
+// with the version and build time of the binary and the instance identity
+func NewBuildInfo(version, buildTime string, config *ExporterConfig) prometheus.Collector {
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricNamespace(config),
+		Subsystem: "exporter",
+		Name:      "build_info",
+		Help:      "A metric with a constant '1' value labeled by version, build time and instance ID of the exporter.",
+		ConstLabels: prometheus.Labels{
+			"version":     version,
+			"build_time":  buildTime,
+			"instance_id": config.InstanceID,
+		},
+	})
+	buildInfo.Set(1)
+	return buildInfo
+}
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// cachedResult is the raw output of a command and the time it expires
+type cachedResult struct {
+	lines   []string
+	expires time.Time
+}
+
+// resultCache keeps the raw command output of metrics with a CacheTTL, keyed by
+// command. The output is parsed per metric after the lookup, as metrics sharing a
+// command may parse it differently. All entries belong to one srvrmgr session and
+// are dropped when it reconnects.
+type resultCache struct {
+	mu         sync.Mutex
+	entries    map[string]cachedResult
+	generation uint64
+	hits       prometheus.Counter
+}
+
+func newResultCache(hits prometheus.Counter) *resultCache {
+	return &resultCache{
+		entries: make(map[string]cachedResult),
+		hits:    hits,
+	}
+}
+
+// invalidateOnReconnect drops all entries if the session generation changed since they were stored
+func (c *resultCache) invalidateOnReconnect(generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		if len(c.entries) > 0 {
+			logger.Debug("Session reconnected, dropping cached results",
+				zap.Int("entries", len(c.entries)))
+		}
+		c.entries = make(map[string]cachedResult)
+		c.generation = generation
+	}
+}
+
+// get returns the cached output of a command if it has not expired yet
+func (c *resultCache) get(command string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[command]
+	if !exists || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	c.hits.Inc()
+	return entry.lines, true
+}
+
+// put stores the output of a command for ttl
+func (c *resultCache) put(command string, lines []string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[command] = cachedResult{
+		lines:   lines,
+		expires: time.Now().Add(ttl),
+	}
+}
@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+	"go.uber.org/zap"
+)
+
+// Defaults of the built-in component health counts
+const (
+	DefaultComponentHealthCommand      = "list comp show CC_ALIAS, CP_DISP_RUN_STATE"
+	DefaultComponentHealthStatusColumn = "CP_DISP_RUN_STATE"
+)
+
+// DefaultComponentRunningValues are the component states counted as running
+var DefaultComponentRunningValues = []string{"Running", "Online"}
+
+// ComponentHealthConfig defines the built-in count of running and down server
+// components, derived from the rows of a component status command
+type ComponentHealthConfig struct {
+	Enabled       bool
+	Command       string
+	StatusColumn  string   // Column holding the component state
+	RunningValues []string // States counted as running, compared case-insensitively
+}
+
+// componentHealthDescs are the descriptors of the component health counts
+type componentHealthDescs struct {
+	running, down *prometheus.Desc
+}
+
+func newComponentHealthDescs(namespace string, labelNames []string) componentHealthDescs {
+	return componentHealthDescs{
+		running: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "components", "running"),
+			"Number of server components in a running state.",
+			labelNames, nil,
+		),
+		down: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "components", "down"),
+			"Number of server components not in a running state.",
+			labelNames, nil,
+		),
+	}
+}
+
+// scrapeComponentHealth runs the component status command once and sends the running and down counts to ch
+func (e *Exporter) scrapeComponentHealth(ch chan<- prometheus.Metric, t *target, smgr *servermanager.ServerManager) error {
+	config := e.config.ComponentHealth
+	command := config.Command
+	if command == "" {
+		command = DefaultComponentHealthCommand
+	}
+	statusColumn := config.StatusColumn
+	if statusColumn == "" {
+		statusColumn = DefaultComponentHealthStatusColumn
+	}
+	runningValues := config.RunningValues
+	if len(runningValues) == 0 {
+		runningValues = DefaultComponentRunningValues
+	}
+
+	rows, err := getSiebelData(smgr, Metric{Command: command, Subsystem: "components"},
+		e.config.DateFormats, e.location, true, e.config.MaxScrapeMemory)
+	if err != nil {
+		return fmt.Errorf("component health command: %w", err)
+	}
+
+	running, down := 0, 0
+	for _, row := range rows {
+		state, exists := row[statusColumn]
+		if !exists {
+			return fmt.Errorf("component health command returned no column %q", statusColumn)
+		}
+		if isRunningState(state, runningValues) {
+			running++
+		} else {
+			down++
+		}
+	}
+
+	logger.Debug("Counted component states",
+		zap.String("server", t.name),
+		zap.Int("running", running),
+		zap.Int("down", down))
+
+	ch <- prometheus.MustNewConstMetric(e.componentHealth.running, prometheus.GaugeValue, float64(running), t.labelValues...)
+	ch <- prometheus.MustNewConstMetric(e.componentHealth.down, prometheus.GaugeValue, float64(down), t.labelValues...)
+	return nil
+}
+
+func isRunningState(state string, runningValues []string) bool {
+	state = strings.TrimSpace(state)
+	for _, value := range runningValues {
+		if strings.EqualFold(state, strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
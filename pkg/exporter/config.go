@@ -1,34 +1,97 @@
 package exporter
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
 )
 
+// Namespace of all metrics unless configured otherwise
+const defaultNamespace = "siebel"
+
+// Default wait of a scrape for an ongoing reconnection, 5 times 500ms
+const (
+	defaultReconnectWaitAttempts = 5
+	defaultReconnectWaitInterval = 500 * time.Millisecond
+)
+
 // ExporterConfig contains all configuration parameters for the Exporter
 type ExporterConfig struct {
 	// Siebel server connection config (directly from server manager)
 	ServerManagerConfig *servermanager.ServerManagerConfig
 
+	// Stable identity of this exporter, independent of the hostname
+	InstanceID string
+
+	// Identify the Siebel enterprise on all metrics, by an "enterprise" label or
+	// as part of the namespace, e.g. siebel_<enterprise>_...
+	EnterpriseLabel       bool
+	EnterpriseInNamespace bool
+
 	// Metrics configuration
-	MetricsFile string
-	DateFormat  string
+	Namespace          string // Prefix of all metric names, "siebel" by default
+	DefaultMetricsFile string
+	CustomMetricsFiles []string // Appended to the default metrics, overriding them by subsystem and command
+	DateFormats        []string // Layouts tried in order to parse date columns
+	TimeZone           string   // IANA time zone name used to interpret Siebel datetimes
+	DefaultMetricType  string   // Type of columns without explicit type: "gauge" (default) or "counter"
 
 	// Behavior configuration
 	DisableEmptyMetricsOverride bool
 	DisableExtendedMetrics      bool
 	ReconnectAfterScrape        bool
+	ServerDownIsScrapeError     bool // A down gateway or application server fails the scrape instead of only setting the up metrics to 0
+	StaleMetricsIsScrapeError   bool // Scrapes fail while the previous metric definitions are used after a failed reload
+	DebugUnmappedColumns        bool // Export siebel_debug_column for columns no metric definition uses
+	CollapseLabelWhitespace     bool // Replace runs of whitespace within label values by a single space
+	DedupScrapeErrors           bool // Log a scrape error repeating for the same metric in full only once, until the metric recovers
+	IgnoreZeroResult            bool // Rows that yield no metrics are not an error for any metric, as with the IgnoreZeroResult of a metric
+
+	// How often and how long a scrape waits for an ongoing reconnection to complete
+	ReconnectWaitAttempts int
+	ReconnectWaitInterval time.Duration
+
+	// Processing configuration
+	ChunkSize            int           // Number of rows converted to metrics at a time
+	ForceGCBetweenChunks bool          // Run the garbage collector between chunks of large results
+	MaxScrapeMemory      int64         // Estimated size in bytes above which a parsed result is rejected, 0 for no limit
+	ScrapeConcurrency    int           // Number of srvrmgr sessions per server used to run metric commands in parallel
+	PoolIdleTimeout      time.Duration // Additional sessions unused this long are closed, 0 keeps them open
+
+	// Recurring windows, in the configured time zone, during which scraping is paused
+	MaintenanceWindows []MaintenanceWindow
+
+	// Built-in counts of running and down server components
+	ComponentHealth ComponentHealthConfig
+
+	// Attach the ID of the last scrape and these labels as exemplars to the scrape
+	// counters, to correlate scrapes with traces and logs
+	Exemplars      bool
+	ExemplarLabels map[string]string
 }
 
 // NewDefaultExporterConfig creates a new ExporterConfig with default values
 func NewDefaultExporterConfig() *ExporterConfig {
 	return &ExporterConfig{
 		ServerManagerConfig:         &servermanager.ServerManagerConfig{},
-		MetricsFile:                 "metrics.toml",
-		DateFormat:                  "2006-01-02 15:04:05",
+		Namespace:                   defaultNamespace,
+		DefaultMetricsFile:          "metrics.toml",
+		DateFormats:                 []string{"2006-01-02 15:04:05"},
+		TimeZone:                    "UTC",
+		DefaultMetricType:           "gauge",
 		DisableEmptyMetricsOverride: false,
 		DisableExtendedMetrics:      false,
 		ReconnectAfterScrape:        false,
+		ServerDownIsScrapeError:     true,
+		ReconnectWaitAttempts:       defaultReconnectWaitAttempts,
+		ReconnectWaitInterval:       defaultReconnectWaitInterval,
+		ChunkSize:                   defaultChunkSize,
+		ForceGCBetweenChunks:        false,
+		MaxScrapeMemory:             0,
+		ScrapeConcurrency:           1,
 	}
 }
 
@@ -40,11 +103,62 @@ type Metric struct {
 	HelpField        map[string]string
 	Type             map[string]string
 	Buckets          map[string]map[string]string
+	Quantiles        map[string]map[string]string
 	ValueMap         map[string]map[string]string
+	ValueExtract     map[string]string // Regex with one capture group per column, selecting the value to parse
 	Labels           []string
+	LabelRename      map[string]string // Output label name per label column, e.g. CC_ALIAS = "component"
 	FieldToAppend    string
 	IgnoreZeroResult bool
 	Extended         bool
+
+	// Replace empty cells by "0", separately for metric value and label columns.
+	// Unset means the global -siebel.disable-empty-metrics-override applies.
+	EmptyValueOverride *bool
+	EmptyLabelOverride *bool
+
+	// How empty metric values are exported: "zero" (default), "skip" or "nan".
+	// When set, empty value cells are no longer replaced by "0" while parsing.
+	EmptyValue string
+
+	DateFields      []string          // Columns holding dates, converted to Unix timestamps
+	DateFormat      string            // Date layout for the date columns, overrides the global date formats
+	FieldDateFormat map[string]string // Date layout per column, overrides DateFormat and marks the column as date
+	CacheTTL        time.Duration     // Reuse the command output for this long, e.g. "5m"
+	Timeout         time.Duration     // Maximum time the command may take, e.g. "10s". Defaults to 60s
+	TimestampField  string            // Date column whose value is used as timestamp of the samples
+	MinRows         int               // Fewer rows fail the scrape of the metric, regardless of IgnoreZeroResult
+
+	// Count drops of counter values, e.g. after a Siebel server restart, in
+	// siebel_exporter_counter_resets_total
+	DetectCounterResets bool
+
+	// Also export <name>_raw with the original string of value-mapped columns in a
+	// "value" label, next to the mapped value
+	RawValueMetric bool
+
+	// Also export <name>_present, 1 if the cell had a value and 0 if it was empty
+	// and the exported value was substituted, e.g. by the empty metrics override
+	PresentMetric bool
+
+	// Treat every row as a task and export <subsystem>_tasks, the number of rows
+	// per combination of the TaskGroupBy columns (Labels if unset), instead of a
+	// series per row. With TaskDetail the per-row metrics are exported too, for
+	// at most TaskDetailLimit rows.
+	Tasks           bool
+	TaskGroupBy     []string
+	TaskDetail      bool
+	TaskDetailLimit int
+
+	// Type of columns without explicit Type, set from ExporterConfig.DefaultMetricType
+	defaultType prometheus.ValueType
+
+	// Set from ExporterConfig.CollapseLabelWhitespace
+	collapseLabelWhitespace bool
+
+	// Set from ExporterConfig.DisableEmptyMetricsOverride, for the override of
+	// PresentMetric columns applied when converting rows
+	disableEmptyMetricsOverride bool
 }
 
 // Metrics used to load multiple metrics from file
@@ -57,15 +171,49 @@ type Exporter struct {
 	namespace             string
 	subsystem             string
 	config                *ExporterConfig
-	srvrmgr               *servermanager.ServerManager
+	location              *time.Location
+	targets               []*target
+	scrapeMu              sync.Mutex     // serializes overlapping scrapes sharing the srvrmgr sessions
+	inFlight              sync.WaitGroup // scrapes waiting or running, awaited on shutdown
+	scraping              atomic.Int32   // number of scrapes waiting or running
+	paused                atomic.Bool    // skip Siebel commands, e.g. during maintenance
+	pausedGauge           prometheus.Gauge
+	inMaintenance         prometheus.Gauge
+	wasInMaintenance      bool           // guarded by scrapeMu
+	lastScrape            scrapeExemplar // guarded by scrapeMu
+	status                scrapeStatus   // state reported by Status
+	scrapeWait            prometheus.Gauge
 	duration, error       prometheus.Gauge
 	totalScrapes          prometheus.Counter
 	scrapeErrors          prometheus.Counter
-	gatewayServerUp       prometheus.Gauge
-	applicationServerUp   prometheus.Gauge
+	memoryExceeded        prometheus.Counter
+	up                    *prometheus.GaugeVec
+	gatewayServerUp       *prometheus.GaugeVec
+	applicationServerUp   *prometheus.GaugeVec
+	enterpriseUp          *prometheus.GaugeVec // nil unless several enterprises are scraped
+	lastReloadSuccess     prometheus.Gauge
+	metricsStale          prometheus.GaugeFunc
+	lastReloadTime        prometheus.Gauge
 	reconnectsTotal       prometheus.Counter
 	reconnectErrors       prometheus.Counter
 	lastReconnectDuration prometheus.Gauge
+	reconnectDelay        *prometheus.GaugeVec
+	reconnectAttempts     *prometheus.GaugeVec
+	watchdogRestarts      *prometheus.Desc
+	srvrmgrInfo           *prometheus.Desc
+	configDesc            *prometheus.Desc
+	configValues          []configValue // resolved once, the config does not change at runtime
+	metricScrapeDuration  *prometheus.GaugeVec
+	metricRowsReturned    *prometheus.GaugeVec
+	counterResets         *prometheus.CounterVec
+	componentHealth       componentHealthDescs
+	poolDescs             poolDescs
+
+	// Cache metrics
+	cacheHits prometheus.Counter
+
+	// Names of the metrics of the last scrape, for listing them without scraping
+	metricNames metricNames
 }
 
 var (
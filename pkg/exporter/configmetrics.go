@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// configValue is a setting exported as siebel_exporter_config{name="..."}
+type configValue struct {
+	name  string
+	value float64
+}
+
+// boolValue converts a flag to the value of a config metric
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// configValues returns the settings that change what or how the exporter scrapes,
+// so that configuration drift between instances shows in a query. The set is
+// fixed to keep the number of series bounded; strings and secrets are left out.
+func configValues(config *ExporterConfig) []configValue {
+	values := []configValue{
+		{"enterprise_label", boolValue(config.EnterpriseLabel)},
+		{"enterprise_in_namespace", boolValue(config.EnterpriseInNamespace)},
+		{"disable_empty_metrics_override", boolValue(config.DisableEmptyMetricsOverride)},
+		{"disable_extended_metrics", boolValue(config.DisableExtendedMetrics)},
+		{"reconnect_after_scrape", boolValue(config.ReconnectAfterScrape)},
+		{"server_down_is_scrape_error", boolValue(config.ServerDownIsScrapeError)},
+		{"stale_metrics_is_scrape_error", boolValue(config.StaleMetricsIsScrapeError)},
+		{"debug_unmapped_columns", boolValue(config.DebugUnmappedColumns)},
+		{"collapse_label_whitespace", boolValue(config.CollapseLabelWhitespace)},
+		{"ignore_zero_result", boolValue(config.IgnoreZeroResult)},
+		{"dedup_scrape_errors", boolValue(config.DedupScrapeErrors)},
+		{"component_health", boolValue(config.ComponentHealth.Enabled)},
+		{"exemplars", boolValue(config.Exemplars)},
+		{"force_gc_between_chunks", boolValue(config.ForceGCBetweenChunks)},
+		{"chunk_size", float64(config.ChunkSize)},
+		{"max_scrape_memory_bytes", float64(config.MaxScrapeMemory)},
+		{"scrape_concurrency", float64(config.ScrapeConcurrency)},
+		{"pool_idle_timeout_seconds", config.PoolIdleTimeout.Seconds()},
+		{"reconnect_wait_attempts", float64(config.ReconnectWaitAttempts)},
+		{"reconnect_wait_interval_seconds", config.ReconnectWaitInterval.Seconds()},
+		{"maintenance_windows", float64(len(config.MaintenanceWindows))},
+	}
+
+	if smConfig := config.ServerManagerConfig; smConfig != nil {
+		values = append(values,
+			configValue{"auto_reconnect", boolValue(smConfig.AutoReconnect)},
+			configValue{"reconnect_delay_seconds", smConfig.ReconnectDelay.Seconds()},
+			configValue{"command_retry_wait_seconds", smConfig.CommandRetryWait.Seconds()},
+			configValue{"command_retries", float64(smConfig.CommandRetries)},
+			configValue{"normalize_commands", boolValue(smConfig.NormalizeCommands)},
+			configValue{"resync_before_command", boolValue(smConfig.ResyncBeforeCommand)},
+			configValue{"merge_stderr", boolValue(smConfig.MergeStderr)},
+			configValue{"startup_commands", float64(len(smConfig.StartupCommands))},
+			configValue{"extra_args", float64(len(smConfig.ExtraArgs))},
+		)
+	}
+	return values
+}
+
+// collectConfig sends the config metrics
+func (e *Exporter) collectConfig(ch chan<- prometheus.Metric) {
+	for _, v := range e.configValues {
+		ch <- prometheus.MustNewConstMetric(e.configDesc, prometheus.GaugeValue, v.value, v.name)
+	}
+}
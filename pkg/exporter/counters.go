@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Series not returned for this long are forgotten, a reset is not detected when they come back
+const counterResetRetention = time.Hour
+
+// counterSample is the last value of a counter series and when it was seen
+type counterSample struct {
+	value float64
+	seen  time.Time
+}
+
+// counterTracker remembers the previous value of counter series of metrics with
+// DetectCounterResets, keyed by metric key. All series belong to one target.
+type counterTracker struct {
+	mu      sync.Mutex
+	samples map[string]counterSample
+	resets  *prometheus.CounterVec
+	labels  prometheus.Labels
+}
+
+func newCounterTracker(resets *prometheus.CounterVec, labels prometheus.Labels) *counterTracker {
+	return &counterTracker{
+		samples: make(map[string]counterSample),
+		resets:  resets,
+		labels:  labels,
+	}
+}
+
+// observe records the value of a counter series and counts a reset if it dropped
+// below the previous value, e.g. after a Siebel server restart
+func (c *counterTracker) observe(key, name string, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	previous, exists := c.samples[key]
+	c.samples[key] = counterSample{value: value, seen: now}
+	if !exists || value >= previous.value {
+		return
+	}
+
+	logger.Info("Counter reset detected",
+		zap.String("metric", name),
+		zap.String("series", key),
+		zap.Float64("previous", previous.value),
+		zap.Float64("value", value))
+
+	labels := prometheus.Labels{"metric": name}
+	for labelName, labelValue := range c.labels {
+		labels[labelName] = labelValue
+	}
+	c.resets.With(labels).Inc()
+}
+
+// prune forgets series not seen within the retention
+func (c *counterTracker) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, sample := range c.samples {
+		if now.Sub(sample.seen) > counterResetRetention {
+			delete(c.samples, key)
+		}
+	}
+}
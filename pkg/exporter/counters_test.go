@@ -0,0 +1,107 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const counterMetric = `
+[[Metric]]
+Command = "list statistics show CC_ALIAS, TOTAL_TASKS"
+Subsystem = "statistics"
+Labels = [ "CC_ALIAS" ]
+DetectCounterResets = true
+[Metric.Help]
+TOTAL_TASKS = "Total number of tasks started."
+[Metric.Type]
+TOTAL_TASKS = "counter"
+`
+
+// resetCount returns the number of resets counted for a metric
+func resetCount(t *testing.T, resets *prometheus.CounterVec, name string) float64 {
+	t.Helper()
+	written := &dto.Metric{}
+	if err := resets.With(prometheus.Labels{"metric": name}).Write(written); err != nil {
+		t.Fatalf("writing counter resets: %v", err)
+	}
+	return written.GetCounter().GetValue()
+}
+
+func TestCounterResetDetection(t *testing.T) {
+	tests := []struct {
+		name       string
+		detect     bool
+		scrapes    [][]map[string]string
+		wantResets float64
+	}{
+		{
+			name:   "increasing counter",
+			detect: true,
+			scrapes: [][]map[string]string{
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "10"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "20"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "20"}},
+			},
+			wantResets: 0,
+		},
+		{
+			name:   "counter reset by a server restart",
+			detect: true,
+			scrapes: [][]map[string]string{
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "10"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "20"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "3"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "8"}},
+			},
+			wantResets: 1,
+		},
+		{
+			name:   "resets of several series",
+			detect: true,
+			scrapes: [][]map[string]string{
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "10"}, {"CC_ALIAS": "SCCObjMgr_enu", "TOTAL_TASKS": "50"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "2"}, {"CC_ALIAS": "SCCObjMgr_enu", "TOTAL_TASKS": "60"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "4"}, {"CC_ALIAS": "SCCObjMgr_enu", "TOTAL_TASKS": "1"}},
+			},
+			wantResets: 2,
+		},
+		{
+			name:   "detection disabled",
+			detect: false,
+			scrapes: [][]map[string]string{
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "10"}},
+				{{"CC_ALIAS": "EAIObjMgr_enu", "TOTAL_TASKS": "3"}},
+			},
+			wantResets: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := decodeMetric(t, counterMetric)
+			metric.DetectCounterResets = tt.detect
+
+			resets := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "counter_resets_total"}, []string{"metric"})
+			tracker := newCounterTracker(resets, prometheus.Labels{})
+
+			for i, rows := range tt.scrapes {
+				seen := make(map[string]bool)
+				for _, row := range rows {
+					metrics, err := convertRowToMetrics(row, "siebel", nil, tracker, metric, seen)
+					if err != nil {
+						t.Fatalf("scrape %d: convertRowToMetrics() error = %v", i+1, err)
+					}
+					if len(metrics) != 1 {
+						t.Fatalf("scrape %d: got %d metrics, want 1", i+1, len(metrics))
+					}
+				}
+			}
+
+			if got := resetCount(t, resets, "siebel_statistics_total_tasks"); got != tt.wantResets {
+				t.Errorf("counter resets = %v, want %v", got, tt.wantResets)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"sync"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// failingMetric is the error a metric keeps failing with and how often in a row
+type failingMetric struct {
+	err   string
+	count int
+}
+
+// metricErrorLog deduplicates the scrape errors of the metrics of one target. An
+// error is logged in full the first time, repetitions of the same error only at
+// debug level with their count, and the recovery with the number of failed scrapes.
+type metricErrorLog struct {
+	mu      sync.Mutex
+	failing map[string]*failingMetric
+}
+
+func newMetricErrorLog() *metricErrorLog {
+	return &metricErrorLog{failing: make(map[string]*failingMetric)}
+}
+
+// metricKey identifies a metric definition by subsystem and command
+func metricKey(metric Metric) string {
+	return metric.Subsystem + "\x00" + metric.Command
+}
+
+// failed records a failed scrape of a metric and reports whether it is a new error
+// to be logged in full, and how often the metric failed in a row
+func (l *metricErrorLog) failed(metric Metric, err error) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := metricKey(metric)
+	f, exists := l.failing[key]
+	if !exists || f.err != err.Error() {
+		l.failing[key] = &failingMetric{err: err.Error(), count: 1}
+		return true, 1
+	}
+	f.count++
+	return false, f.count
+}
+
+// succeeded records a successful scrape of a metric and returns the number of
+// scrapes it failed before, 0 if it was not failing
+func (l *metricErrorLog) succeeded(metric Metric) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := metricKey(metric)
+	f, exists := l.failing[key]
+	if !exists {
+		return 0
+	}
+	delete(l.failing, key)
+	return f.count
+}
+
+// logScrapeError logs the failed scrape of a metric, deduplicated per metric if configured
+func (e *Exporter) logScrapeError(t *target, metric Metric, err error) {
+	if !e.config.DedupScrapeErrors {
+		logger.Error("Error scraping metric",
+			zap.String("server", t.name),
+			zap.String("subsystem", metric.Subsystem),
+			zap.Any("help", metric.Help),
+			zap.Error(err))
+		return
+	}
+
+	if first, count := t.errors.failed(metric, err); first {
+		logger.Error("Error scraping metric, repetitions are logged at debug level until it recovers",
+			zap.String("server", t.name),
+			zap.String("subsystem", metric.Subsystem),
+			zap.String("command", metric.Command),
+			zap.Any("help", metric.Help),
+			zap.Error(err))
+	} else {
+		logger.Debug("Error scraping metric repeated",
+			zap.String("server", t.name),
+			zap.String("subsystem", metric.Subsystem),
+			zap.Int("failedScrapes", count),
+			zap.Error(err))
+	}
+}
+
+// logScrapeRecovery logs that a metric is scraped again after deduplicated errors
+func (e *Exporter) logScrapeRecovery(t *target, metric Metric) {
+	if !e.config.DedupScrapeErrors {
+		return
+	}
+	if count := t.errors.succeeded(metric); count > 0 {
+		logger.Info("Scraping metric recovered",
+			zap.String("server", t.name),
+			zap.String("subsystem", metric.Subsystem),
+			zap.String("command", metric.Command),
+			zap.Int("failedScrapes", count))
+	}
+}
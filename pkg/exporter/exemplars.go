@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Exemplar label identifying the scrape, generated for every scrape
+const scrapeIDLabel = "scrape_id"
+
+// Length of a scrape ID, a hex string like a trace ID
+const scrapeIDLength = 32
+
+// OpenMetrics limits the labels of an exemplar to 128 UTF-8 characters in total
+const maxExemplarRunes = 128
+
+// scrapeExemplar identifies the last scrape in the exemplars of the scrape counters
+type scrapeExemplar struct {
+	id     string
+	start  time.Time
+	failed bool
+}
+
+// newScrapeID returns a random ID for a scrape
+func newScrapeID() string {
+	return fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+}
+
+// ValidateExemplarLabels checks that the configured exemplar labels leave room for
+// the scrape ID within the OpenMetrics length limit
+func ValidateExemplarLabels(labels map[string]string) error {
+	runes := utf8.RuneCountInString(scrapeIDLabel) + scrapeIDLength
+	for name, value := range labels {
+		if name == scrapeIDLabel {
+			return fmt.Errorf("exemplar label %q is set by the exporter", name)
+		}
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if runes > maxExemplarRunes {
+		return fmt.Errorf("exemplar labels have %d characters including %s, at most %d are allowed", runes, scrapeIDLabel, maxExemplarRunes)
+	}
+	return nil
+}
+
+// withScrapeExemplar attaches the ID of the last scrape and the ExemplarLabels to
+// a counter. Exemplars are only exposed to clients negotiating OpenMetrics, the
+// plain text format leaves them out.
+func (e *Exporter) withScrapeExemplar(m prometheus.Metric, scrape scrapeExemplar) prometheus.Metric {
+	if !e.config.Exemplars || scrape.id == "" {
+		return m
+	}
+
+	labels := prometheus.Labels{scrapeIDLabel: scrape.id}
+	for name, value := range e.config.ExemplarLabels {
+		labels[name] = value
+	}
+
+	withExemplar, err := prometheus.NewMetricWithExemplars(m, prometheus.Exemplar{
+		Value:     1,
+		Labels:    labels,
+		Timestamp: scrape.start,
+	})
+	if err != nil {
+		logger.Debug("Unable to attach exemplar, exporting metric without it", zap.Error(err))
+		return m
+	}
+	return withExemplar
+}
@@ -1,7 +1,14 @@
 package exporter
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -11,23 +18,82 @@ import (
 )
 
 // NewExporter returns a new Siebel exporter for the provided args.
-func NewExporter(srvrmgr *servermanager.ServerManager, config *ExporterConfig) *Exporter {
+// Every ServerManager is scraped as a separate Siebel application server.
+func NewExporter(srvrmgrs []*servermanager.ServerManager, config *ExporterConfig) *Exporter {
 	logger.Debug("Creating new exporter",
-		zap.String("metricsFile", config.MetricsFile))
+		zap.String("defaultMetricsFile", config.DefaultMetricsFile),
+		zap.Strings("customMetricsFiles", config.CustomMetricsFiles),
+		zap.Int("servers", len(srvrmgrs)))
 
-	const (
-		namespace = "siebel"
-		subsystem = "exporter"
-	)
+	// Load metrics from files
+	loadMetrics(config.DefaultMetricsFile, config.CustomMetricsFiles)
+
+	e := newExporter(srvrmgrs, config)
+	logger.Info("Interpreting Siebel datetimes in time zone",
+		zap.String("timeZone", e.location.String()),
+		zap.Strings("dateFormats", config.DateFormats))
+	if config.ScrapeConcurrency > 1 {
+		for _, t := range e.targets {
+			t.pool = servermanager.NewPool(t.srvrmgr, config.ScrapeConcurrency, config.PoolIdleTimeout)
+		}
+	}
+	e.lastReloadSuccess.Set(1)
+	e.lastReloadTime.SetToCurrentTime()
+	return e
+}
 
-	// Load metrics from file
-	loadMetrics(config.MetricsFile)
+// NewTargetExporter returns an exporter for a single ServerManager, used to scrape
+// targets on demand. It reuses the metrics already loaded by NewExporter.
+func NewTargetExporter(srvrmgr *servermanager.ServerManager, config *ExporterConfig) *Exporter {
+	logger.Debug("Creating new target exporter",
+		zap.String("gateway", srvrmgr.GetConfig().Gateway),
+		zap.String("server", srvrmgr.GetConfig().Server))
+
+	return newExporter([]*servermanager.ServerManager{srvrmgr}, config)
+}
+
+func newExporter(srvrmgrs []*servermanager.ServerManager, config *ExporterConfig) *Exporter {
+	const subsystem = "exporter"
+	namespace := metricNamespace(config)
+	if config.EnterpriseInNamespace && len(srvrmgrs) > 0 {
+		namespace = enterpriseNamespace(namespace, srvrmgrs[0].GetConfig().Enterprise)
+	}
 
-	return &Exporter{
+	// Siebel datetimes carry no zone information, interpret them in the configured one
+	location, err := time.LoadLocation(config.TimeZone)
+	if err != nil {
+		logger.Error("Unknown time zone, falling back to UTC",
+			zap.String("timeZone", config.TimeZone),
+			zap.Error(err))
+		location = time.UTC
+	}
+
+	targets, targetLabelNames := newTargets(srvrmgrs, config.EnterpriseLabel)
+
+	e := &Exporter{
 		namespace: namespace,
 		subsystem: subsystem,
 		config:    config,
-		srvrmgr:   srvrmgr,
+		location:  location,
+		targets:   targets,
+		pausedGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "paused",
+			Help:      "Whether scraping of Siebel is paused (1 for paused, 0 for active).",
+		}),
+		inMaintenance: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "in_maintenance",
+			Help:      "Whether scraping is paused by a maintenance window (1 for in maintenance, 0 otherwise).",
+		}),
+		scrapeWait: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "scrape_wait_seconds",
+			Help:      "Time the last scrape spent waiting for a concurrent scrape to finish.",
+		}),
 		duration: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subsystem,
@@ -46,21 +112,55 @@ func NewExporter(srvrmgr *servermanager.ServerManager, config *ExporterConfig) *
 			Name:      "scrape_errors_total",
 			Help:      "Total number of times an error occurred scraping a Siebel.",
 		}),
+		memoryExceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "scrape_memory_limit_exceeded_total",
+			Help:      "Total number of command results rejected because they exceeded the configured memory limit.",
+		}),
 		error: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subsystem,
 			Name:      "last_scrape_error",
 			Help:      "Whether the last scrape of metrics from Siebel resulted in an error (1 for error, 0 for success).",
 		}),
-		gatewayServerUp: prometheus.NewGauge(prometheus.GaugeOpts{
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "up",
+			Help:      "Whether the Siebel Gateway and Application Server are up and the last scrape succeeded (1 for healthy, 0 otherwise).",
+		}, targetLabelNames),
+		gatewayServerUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "gateway_server_up",
 			Help:      "Whether the Siebel Gateway Server is up (1 for up, 0 for down).",
-		}),
-		applicationServerUp: prometheus.NewGauge(prometheus.GaugeOpts{
+		}, targetLabelNames),
+		applicationServerUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "application_server_up",
 			Help:      "Whether the Siebel Application Server is up (1 for up, 0 for down).",
+		}, targetLabelNames),
+		lastReloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "last_reload_success",
+			Help:      "Whether the last reload of the metrics file was successful (1 for success, 0 for failure).",
+		}),
+		metricsStale: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "metrics_stale",
+			Help:      "Whether the scrapes use the previous metric definitions because the last reload of the metrics files failed (1 for stale, 0 for current).",
+		}, func() float64 {
+			if metricsStale.Load() {
+				return 1
+			}
+			return 0
+		}),
+		lastReloadTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "last_reload_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful reload of the metrics file.",
 		}),
 		reconnectsTotal: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -80,82 +180,325 @@ func NewExporter(srvrmgr *servermanager.ServerManager, config *ExporterConfig) *
 			Name:      "last_reconnect_duration_seconds",
 			Help:      "Duration of the last reconnection attempt in seconds.",
 		}),
+		reconnectDelay: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "reconnect_current_delay_seconds",
+			Help:      "Current backoff delay of the active reconnection cycle in seconds, 0 when not reconnecting.",
+		}, targetLabelNames),
+		reconnectAttempts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "reconnect_attempts_current",
+			Help:      "Number of attempts in the active reconnection cycle, 0 when not reconnecting.",
+		}, targetLabelNames),
+		watchdogRestarts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "watchdog_restarts_total"),
+			"Total number of times the watchdog restarted an unresponsive srvrmgr process.",
+			targetLabelNames, nil,
+		),
+		srvrmgrInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "srvrmgr", "info"),
+			"Version and build of the srvrmgr of the session, from its banner on the last connect.",
+			append([]string{"version", "build"}, targetLabelNames...), nil,
+		),
+		configDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "config"),
+			"Value of a setting of the exporter, 1 or 0 for flags, to compare the configuration of instances.",
+			[]string{"name"}, nil,
+		),
+		configValues: configValues(config),
+		metricScrapeDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "metric_scrape_duration_seconds",
+			Help:      "Duration of the last scrape of the command of a metric subsystem, including failed ones.",
+		}, append([]string{"subsystem"}, targetLabelNames...)),
+		metricRowsReturned: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "metric_rows_returned",
+			Help:      "Number of rows returned by the command of a metric subsystem in the last scrape.",
+		}, append([]string{"subsystem"}, targetLabelNames...)),
+		counterResets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "counter_resets_total",
+			Help:      "Total number of times a counter exported from Siebel dropped below its previous value, for metrics with DetectCounterResets.",
+		}, append([]string{"metric"}, targetLabelNames...)),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_hits_total",
+			Help:      "Total number of command results served from the cache instead of running the command.",
+		}),
 	}
+
+	// Every enterprise is reported separately when several are scraped
+	if enterprises := targetEnterprises(srvrmgrs); len(enterprises) > 1 {
+		e.enterpriseUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "enterprise_up",
+			Help:      "Whether all scraped servers of the Siebel Enterprise are up and their last scrape succeeded (1 for healthy, 0 otherwise).",
+		}, []string{"enterprise"})
+		for _, enterprise := range enterprises {
+			e.enterpriseUp.WithLabelValues(enterprise).Set(0)
+		}
+	}
+
+	e.componentHealth = newComponentHealthDescs(namespace, targetLabelNames)
+	e.poolDescs = newPoolDescs(namespace, targetLabelNames)
+
+	for _, t := range e.targets {
+		t.cache = newResultCache(e.cacheHits)
+		t.resets = newCounterTracker(e.counterResets, t.labels)
+		t.errors = newMetricErrorLog()
+	}
+
+	return e
 }
 
-// Describe describes all the metrics exported by the Siebel exporter.
-func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	logger.Debug("Describing exporter metrics")
+// metricNamespace returns the configured metric namespace, cleaned up to follow
+// the Prometheus naming rules. It defaults to "siebel".
+func metricNamespace(config *ExporterConfig) string {
+	if config.Namespace == "" {
+		return defaultNamespace
+	}
 
-	metricCh := make(chan prometheus.Metric)
-	doneCh := make(chan struct{})
+	namespace := cleanName(config.Namespace)
+	if namespace == "" || (namespace[0] >= '0' && namespace[0] <= '9') {
+		logger.Error("Invalid metric namespace, using default",
+			zap.String("namespace", config.Namespace),
+			zap.String("default", defaultNamespace))
+		return defaultNamespace
+	}
+	if namespace != config.Namespace {
+		logger.Warn("Metric namespace does not follow Prometheus naming rules, using cleaned name",
+			zap.String("namespace", config.Namespace),
+			zap.String("cleaned", namespace))
+	}
+	return namespace
+}
 
-	go func() {
-		for m := range metricCh {
-			ch <- m.Desc()
-		}
-		close(doneCh)
-	}()
+// enterpriseNamespace appends the cleaned enterprise name to the namespace
+func enterpriseNamespace(namespace, enterprise string) string {
+	cleaned := cleanName(enterprise)
+	if cleaned == "" {
+		logger.Warn("Enterprise name is empty after cleaning, not adding it to the namespace",
+			zap.String("enterprise", enterprise))
+		return namespace
+	}
+	return namespace + "_" + cleaned
+}
 
-	e.Collect(metricCh)
-	close(metricCh)
-	<-doneCh
+// Describe implements prometheus.Collector. It intentionally sends no descriptors,
+// which registers the exporter as an unchecked collector: the metric set is defined
+// by the metrics file and only known after a scrape, and describing it would
+// require running a full scrape against Siebel on registration.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	logger.Debug("Describing exporter metrics")
 }
 
 // Collect implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	logger.Debug("Collecting metrics")
-	e.scrape(ch)
+
+	// Let shutdown wait for this scrape before the srvrmgr sessions are closed
+	e.inFlight.Add(1)
+	e.scraping.Add(1)
+	defer func() {
+		e.scraping.Add(-1)
+		e.inFlight.Done()
+	}()
+
+	// Overlapping scrapes would interleave commands on the shared srvrmgr sessions
+	waitStart := time.Now()
+	e.scrapeMu.Lock()
+	defer e.scrapeMu.Unlock()
+	waitTime := time.Since(waitStart)
+	e.scrapeWait.Set(waitTime.Seconds())
+	if waitTime > time.Second {
+		logger.Debug("Scrape waited for a concurrent scrape to finish", zap.Duration("waitTime", waitTime))
+	}
+
+	// Remember the names of the metrics sent, for the metric names endpoint
+	ch, recorded := e.metricNames.record(ch)
+	defer recorded()
+
+	// While paused or in a maintenance window no commands are sent and the up metrics
+	// are left out, so that planned maintenance does not look like an outage
+	inMaintenance := e.checkMaintenance(time.Now())
+	paused := e.IsPaused() || inMaintenance
+	if paused {
+		logger.Debug("Scraping is paused, emitting exporter metrics only")
+		e.pausedGauge.Set(1)
+	} else {
+		e.pausedGauge.Set(0)
+		e.scrape(ch)
+	}
+
+	ch <- e.pausedGauge
+	ch <- e.inMaintenance
+	ch <- e.scrapeWait
 	ch <- e.duration
-	ch <- e.totalScrapes
+	ch <- e.withScrapeExemplar(e.totalScrapes, e.lastScrape)
 	ch <- e.error
-	e.scrapeErrors.Collect(ch)
-	ch <- e.gatewayServerUp
-	ch <- e.applicationServerUp
+	if e.lastScrape.failed {
+		ch <- e.withScrapeExemplar(e.scrapeErrors, e.lastScrape)
+	} else {
+		ch <- e.scrapeErrors
+	}
+	ch <- e.memoryExceeded
+	if !paused {
+		e.up.Collect(ch)
+		e.gatewayServerUp.Collect(ch)
+		e.applicationServerUp.Collect(ch)
+		if e.enterpriseUp != nil {
+			e.enterpriseUp.Collect(ch)
+		}
+		e.metricScrapeDuration.Collect(ch)
+		e.metricRowsReturned.Collect(ch)
+	}
+
+	ch <- e.lastReloadSuccess
+	ch <- e.metricsStale
+	ch <- e.lastReloadTime
 
 	// Emit reconnection metrics
 	e.reconnectsTotal.Collect(ch)
 	e.reconnectErrors.Collect(ch)
 	ch <- e.lastReconnectDuration
+	for _, t := range e.targets {
+		attempts, delay := t.srvrmgr.ReconnectState()
+		e.reconnectAttempts.With(t.labels).Set(float64(attempts))
+		e.reconnectDelay.With(t.labels).Set(delay.Seconds())
+	}
+	e.reconnectAttempts.Collect(ch)
+	e.reconnectDelay.Collect(ch)
+	for _, t := range e.targets {
+		ch <- prometheus.MustNewConstMetric(e.watchdogRestarts, prometheus.CounterValue,
+			float64(t.srvrmgr.WatchdogRestarts()), t.labelValues...)
+		if version, build := t.srvrmgr.Version(); version != "" {
+			ch <- prometheus.MustNewConstMetric(e.srvrmgrInfo, prometheus.GaugeValue, 1,
+				append([]string{version, build}, t.labelValues...)...)
+		}
+	}
+
+	ch <- e.cacheHits
+	e.counterResets.Collect(ch)
+	e.collectPools(ch)
+	e.collectConfig(ch)
 }
 
 func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 	logger.Debug("Starting metric scrape")
 
 	e.totalScrapes.Inc()
-	e.gatewayServerUp.Set(0)
-	e.applicationServerUp.Set(0)
+	for _, t := range e.targets {
+		t.up = false
+		e.up.With(t.labels).Set(0)
+		e.gatewayServerUp.With(t.labels).Set(0)
+		e.applicationServerUp.With(t.labels).Set(0)
+	}
 
 	var err error
-	defer func(begun time.Time) {
+	begun := time.Now()
+	if e.config.Exemplars {
+		e.lastScrape = scrapeExemplar{id: newScrapeID(), start: begun}
+		logger.Debug("Starting scrape", zap.String(scrapeIDLabel, e.lastScrape.id))
+	}
+	defer func() {
+		e.lastScrape.failed = err != nil
+		e.status.recordScrape(begun, err)
 		e.duration.Set(time.Since(begun).Seconds())
 		if err == nil {
 			e.error.Set(0)
 		} else {
 			e.error.Set(1)
 		}
-	}(time.Now())
+	}()
+
+	if reloaded, reloadErr := reloadMetricsIfItChanged(e.config.DefaultMetricsFile, e.config.CustomMetricsFiles); reloadErr != nil {
+		logger.Error("Reloading the changed metrics files failed, keeping the current metrics", zap.Error(reloadErr))
+		e.lastReloadSuccess.Set(0)
+	} else if reloaded {
+		e.lastReloadSuccess.Set(1)
+		e.lastReloadTime.SetToCurrentTime()
+	}
+	if e.config.StaleMetricsIsScrapeError && metricsStale.Load() {
+		logger.Warn("Scraping with stale metric definitions, the last reload of the metrics files failed")
+		e.scrapeErrors.Inc()
+		err = errStaleMetrics
+	}
+
+	enterpriseUp := make(map[string]bool)
+	for _, t := range e.targets {
+		if targetErr := e.scrapeTarget(ch, t); targetErr != nil {
+			err = targetErr
+		}
+		up, seen := enterpriseUp[t.enterprise]
+		enterpriseUp[t.enterprise] = t.up && (up || !seen)
+	}
+	if e.enterpriseUp != nil {
+		for enterprise, up := range enterpriseUp {
+			if up {
+				e.enterpriseUp.WithLabelValues(enterprise).Set(1)
+			} else {
+				e.enterpriseUp.WithLabelValues(enterprise).Set(0)
+			}
+		}
+	}
+
+	// If reconnectAfterScrape is enabled, reconnect to the servers
+	if e.config.ReconnectAfterScrape {
+		for _, t := range e.targets {
+			e.reconnectTarget(t)
+		}
+	}
+}
 
-	if !checkConnection(e.srvrmgr, e.config.ServerManagerConfig) {
-		return
+// scrapeTarget scrapes all configured metrics from a single Siebel application server
+func (e *Exporter) scrapeTarget(ch chan<- prometheus.Metric, t *target) error {
+	logger.Debug("Scraping target", zap.String("server", t.name))
+
+	var err error
+
+	if !checkConnection(t.srvrmgr, e.config) {
+		return nil
 	}
 
-	if err = pingGatewayServer(e.srvrmgr); err != nil {
-		return
+	if err = pingGatewayServer(t.srvrmgr); err != nil {
+		return e.serverDown(t, "gateway", err)
 	}
-	e.gatewayServerUp.Set(1)
+	e.gatewayServerUp.With(t.labels).Set(1)
 
-	if err = pingApplicationServer(e.srvrmgr); err != nil {
-		return
+	if err = pingApplicationServer(t.srvrmgr); err != nil {
+		return e.serverDown(t, "application", err)
 	}
-	e.applicationServerUp.Set(1)
+	e.applicationServerUp.With(t.labels).Set(1)
 
-	reloadMetricsIfItChanged(e.config.MetricsFile)
+	// Cached results are only valid for the session they were read from
+	t.cache.invalidateOnReconnect(t.srvrmgr.Generation())
 
-	for _, metric := range defaultMetrics.Metric {
+	metrics := []Metric{}
+	for _, metric := range currentMetrics() {
 		logMetricDesc(metric)
 
-		if !validateMetricDesc(metric) {
+		if problems := validateMetricDesc(metric); len(problems) > 0 {
+			for _, problem := range problems {
+				logger.Error("Invalid metric definition, skipping",
+					zap.String("command", metric.Command),
+					zap.String("subsystem", metric.Subsystem),
+					zap.Error(problem))
+			}
+			continue
+		}
+
+		if label := conflictingLabel(metric, t.labels); label != "" {
+			logger.Error("Metric label collides with a label added by the exporter, skipping",
+				zap.String("command", metric.Command),
+				zap.String("subsystem", metric.Subsystem),
+				zap.String("label", label))
 			continue
 		}
 
@@ -164,56 +507,332 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 			continue
 		}
 
-		scrapeStart := time.Now()
+		metrics = append(metrics, metric)
+	}
 
-		if err = scrapeGenericValues(e.namespace, e.config.DateFormat, e.config.DisableEmptyMetricsOverride, e.srvrmgr, &ch, metric); err != nil {
-			logger.Error("Error scraping metric",
-				zap.String("subsystem", metric.Subsystem),
-				zap.Any("help", metric.Help),
-				zap.Error(err))
+	if e.config.ComponentHealth.Enabled {
+		if healthErr := e.scrapeComponentHealth(ch, t, t.srvrmgr); healthErr != nil {
+			logger.Error("Error counting component states",
+				zap.String("server", t.name),
+				zap.Error(healthErr))
 			e.scrapeErrors.Inc()
-		} else {
-			scrapeEnd := time.Since(scrapeStart)
-			logger.Debug("Successfully scraped metric",
-				zap.String("subsystem", metric.Subsystem),
-				zap.Any("help", metric.Help),
-				zap.Duration("duration", scrapeEnd))
+			err = healthErr
 		}
 	}
 
-	// If reconnectAfterScrape is enabled, reconnect to the server
-	if e.config.ReconnectAfterScrape {
-		logger.Info("Reconnecting after scrape as configured")
-		reconnectStart := time.Now()
-		e.reconnectsTotal.Inc()
+	if t.pool != nil {
+		if poolErr := e.scrapeMetricsConcurrently(ch, t, metrics); poolErr != nil {
+			err = poolErr
+		}
+	} else {
+		for _, metric := range metrics {
+			if metricErr := e.scrapeMetric(ch, t, t.srvrmgr, metric); metricErr != nil {
+				err = metricErr
+			}
+		}
+	}
+	t.resets.prune(time.Now())
+
+	// The target is healthy when both servers answered and every metric was scraped
+	if err == nil {
+		t.up = true
+		e.up.With(t.labels).Set(1)
+	}
+
+	return err
+}
+
+// serverDown handles a failed ping of the gateway or application server. The down
+// server counts as scrape error unless ServerDownIsScrapeError is disabled, in which
+// case the scrape is clean and only the up metrics report it.
+func (e *Exporter) serverDown(t *target, server string, err error) error {
+	if !e.config.ServerDownIsScrapeError {
+		logger.Debug("Server is down, reporting it through the up metrics only",
+			zap.String("server", t.name),
+			zap.String("type", server),
+			zap.Error(err))
+		return nil
+	}
+	e.scrapeErrors.Inc()
+	return err
+}
+
+// scrapeMetricsConcurrently runs the metric commands of a target on its pool of
+// srvrmgr sessions, as many at a time as the pool has sessions
+func (e *Exporter) scrapeMetricsConcurrently(ch chan<- prometheus.Metric, t *target, metrics []Metric) error {
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		lastErr error
+	)
+
+	jobs := make(chan Metric)
+	workers := t.pool.Size()
+	if workers > len(metrics) {
+		workers = len(metrics)
+	}
+
+	logger.Debug("Scraping metrics concurrently",
+		zap.String("server", t.name),
+		zap.Int("metrics", len(metrics)),
+		zap.Int("workers", workers))
 
-		// First disconnect
-		disconnectErr := e.srvrmgr.Disconnect()
-		if disconnectErr != nil {
-			logger.Warn("Error during disconnect for after-scrape reconnection",
-				zap.Error(disconnectErr))
-			// Continue with reconnect anyway
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for metric := range jobs {
+				smgr, err := t.pool.Acquire()
+				if err == nil {
+					err = e.scrapeMetric(ch, t, smgr, metric)
+					t.pool.Release(smgr)
+				} else {
+					logger.Error("No srvrmgr session available for metric",
+						zap.String("server", t.name),
+						zap.String("subsystem", metric.Subsystem),
+						zap.Error(err))
+					e.scrapeErrors.Inc()
+				}
+
+				if err != nil {
+					errMu.Lock()
+					lastErr = err
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, metric := range metrics {
+		jobs <- metric
+	}
+	close(jobs)
+	wg.Wait()
+
+	return lastErr
+}
+
+// scrapeMetric runs the command of a single metric on the given session and sends the results to ch
+func (e *Exporter) scrapeMetric(ch chan<- prometheus.Metric, t *target, smgr *servermanager.ServerManager, metric Metric) error {
+	scrapeStart := time.Now()
+
+	rows, err := scrapeGenericValues(e.namespace, e.config, e.location, smgr, t.cache, t.resets, t.labels, &ch, metric)
+	e.status.recordMetric(t.name, metric, scrapeStart, rows, err)
+	e.recordMetricScrape(t, metric, time.Since(scrapeStart), rows)
+	if err != nil {
+		e.logScrapeError(t, metric, err)
+		e.scrapeErrors.Inc()
+		if errors.Is(err, errScrapeMemoryExceeded) {
+			e.memoryExceeded.Inc()
 		}
+		return err
+	}
 
-		// Short pause to ensure clean disconnection
-		time.Sleep(1 * time.Second)
+	e.logScrapeRecovery(t, metric)
+	scrapeEnd := time.Since(scrapeStart)
+	logger.Debug("Successfully scraped metric",
+		zap.String("server", t.name),
+		zap.String("subsystem", metric.Subsystem),
+		zap.Any("help", metric.Help),
+		zap.Duration("duration", scrapeEnd))
+	return nil
+}
 
-		// Now reconnect
-		if reconnectErr := e.srvrmgr.Connect(); reconnectErr != nil {
-			logger.Error("Failed to reconnect after scrape", zap.Error(reconnectErr))
-			e.reconnectErrors.Inc()
-			e.error.Set(1)
+// recordMetricScrape sets the per-subsystem scrape duration and row count of a target
+func (e *Exporter) recordMetricScrape(t *target, metric Metric, duration time.Duration, rows int) {
+	labels := prometheus.Labels{"subsystem": cleanName(metric.Subsystem)}
+	for name, value := range t.labels {
+		labels[name] = value
+	}
+	e.metricScrapeDuration.With(labels).Set(duration.Seconds())
+	e.metricRowsReturned.With(labels).Set(float64(rows))
+}
+
+// Pause stops sending commands to Siebel until Resume is called
+func (e *Exporter) Pause() {
+	if !e.paused.Swap(true) {
+		logger.Info("Scraping paused")
+	}
+}
+
+// Resume continues scraping after Pause
+func (e *Exporter) Resume() {
+	if e.paused.Swap(false) {
+		logger.Info("Scraping resumed")
+	}
+}
+
+// IsPaused reports whether scraping is paused
+func (e *Exporter) IsPaused() bool {
+	return e.paused.Load()
+}
+
+// checkMaintenance reports whether now is inside a maintenance window, updates the
+// in_maintenance gauge and logs when a window starts or ends. Must be called with scrapeMu held.
+func (e *Exporter) checkMaintenance(now time.Time) bool {
+	active := e.activeMaintenanceWindow(now)
+
+	inMaintenance := active != nil
+	if inMaintenance != e.wasInMaintenance {
+		if inMaintenance {
+			logger.Info("Maintenance window started, pausing scraping", zap.Stringer("window", active))
 		} else {
-			logger.Info("Successfully reconnected after scrape")
+			logger.Info("Maintenance window ended, resuming scraping")
+		}
+		e.wasInMaintenance = inMaintenance
+	}
+
+	if inMaintenance {
+		e.inMaintenance.Set(1)
+	} else {
+		e.inMaintenance.Set(0)
+	}
+	return inMaintenance
+}
+
+// activeMaintenanceWindow returns the maintenance window containing now, or nil
+func (e *Exporter) activeMaintenanceWindow(now time.Time) *MaintenanceWindow {
+	for i, window := range e.config.MaintenanceWindows {
+		if window.Contains(now.In(e.location)) {
+			return &e.config.MaintenanceWindows[i]
+		}
+	}
+	return nil
+}
+
+// ScrapeInProgress reports whether a scrape is currently waiting or running
+func (e *Exporter) ScrapeInProgress() bool {
+	return e.scraping.Load() > 0
+}
+
+// WaitForScrape waits until in-flight scrapes have finished or ctx is done,
+// so the srvrmgr sessions are not closed in the middle of a command
+func (e *Exporter) WaitForScrape(ctx context.Context) error {
+	if !e.ScrapeInProgress() {
+		return nil
+	}
+
+	logger.Info("Waiting for in-flight scrape to finish")
+	done := make(chan struct{})
+	go func() {
+		e.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("In-flight scrape finished")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("in-flight scrape did not finish: %w", ctx.Err())
+	}
+}
+
+// Close closes the additional srvrmgr sessions opened for concurrent scraping
+func (e *Exporter) Close() {
+	for _, t := range e.targets {
+		if t.pool != nil {
+			t.pool.Close()
+		}
+	}
+}
+
+// reconnectTarget disconnects and reconnects the srvrmgr session of a target
+func (e *Exporter) reconnectTarget(t *target) {
+	logger.Info("Reconnecting after scrape as configured", zap.String("server", t.name))
+	reconnectStart := time.Now()
+	e.reconnectsTotal.Inc()
+
+	// First disconnect
+	disconnectErr := t.srvrmgr.Disconnect()
+	if disconnectErr != nil {
+		logger.Warn("Error during disconnect for after-scrape reconnection",
+			zap.String("server", t.name),
+			zap.Error(disconnectErr))
+		// Continue with reconnect anyway
+	}
+
+	// Short pause to ensure clean disconnection
+	time.Sleep(1 * time.Second)
+
+	// Now reconnect
+	if reconnectErr := t.srvrmgr.Connect(); reconnectErr != nil {
+		logger.Error("Failed to reconnect after scrape",
+			zap.String("server", t.name),
+			zap.Error(reconnectErr))
+		e.reconnectErrors.Inc()
+		e.error.Set(1)
+	} else {
+		logger.Info("Successfully reconnected after scrape", zap.String("server", t.name))
+	}
+
+	// Record reconnection duration
+	e.lastReconnectDuration.Set(time.Since(reconnectStart).Seconds())
+}
+
+// ReloadMetrics reloads the metrics files immediately, regardless of whether they changed.
+// The current metrics are kept if any file is invalid.
+func (e *Exporter) ReloadMetrics() error {
+	logger.Info("Reloading metrics files on demand",
+		zap.String("defaultFile", e.config.DefaultMetricsFile),
+		zap.Strings("customFiles", e.config.CustomMetricsFiles))
+
+	if err := reloadMetrics(e.config.DefaultMetricsFile, e.config.CustomMetricsFiles); err != nil {
+		e.lastReloadSuccess.Set(0)
+		return err
+	}
+
+	e.lastReloadSuccess.Set(1)
+	e.lastReloadTime.SetToCurrentTime()
+	return nil
+}
+
+// ServerManagers returns the srvrmgr sessions of all scraped servers keyed by server name
+func (e *Exporter) ServerManagers() map[string]*servermanager.ServerManager {
+	srvrmgrs := make(map[string]*servermanager.ServerManager, len(e.targets))
+	for _, t := range e.targets {
+		srvrmgrs[t.name] = t.srvrmgr
+	}
+	return srvrmgrs
+}
+
+// Errors of RunCommand
+var (
+	ErrUnknownServer = errors.New("unknown server")
+	ErrNotConnected  = errors.New("srvrmgr is not connected")
+)
+
+// RunCommand sends a command to the srvrmgr session of a scraped server and returns
+// the raw output lines. An empty server selects the first one. Scrapes are not
+// running at the same time, as they share the session.
+func (e *Exporter) RunCommand(server, command string, timeout time.Duration) ([]string, error) {
+	var t *target
+	for _, candidate := range e.targets {
+		if server == "" || candidate.name == server {
+			t = candidate
+			break
 		}
+	}
+	if t == nil {
+		return nil, ErrUnknownServer
+	}
+
+	e.scrapeMu.Lock()
+	defer e.scrapeMu.Unlock()
 
-		// Record reconnection duration
-		e.lastReconnectDuration.Set(time.Since(reconnectStart).Seconds())
+	if t.srvrmgr.GetStatus() != servermanager.Connected {
+		return nil, ErrNotConnected
 	}
+
+	logger.Info("Running command on request",
+		zap.String("server", t.name),
+		zap.String("command", command))
+	return t.srvrmgr.SendCommandWithTimeout(command, timeout)
 }
 
 // Check srvrmgr connection status
-func checkConnection(smgr *servermanager.ServerManager, config *servermanager.ServerManagerConfig) bool {
+func checkConnection(smgr *servermanager.ServerManager, exporterConfig *ExporterConfig) bool {
+	config := exporterConfig.ServerManagerConfig
 	status := smgr.GetStatus()
 
 	switch status {
@@ -271,9 +890,14 @@ func checkConnection(smgr *servermanager.ServerManager, config *servermanager.Se
 	case servermanager.Reconnecting:
 		logger.Info("ServerManager is currently reconnecting, waiting for completion")
 
+		attempts, interval := exporterConfig.ReconnectWaitAttempts, exporterConfig.ReconnectWaitInterval
+		if attempts <= 0 || interval <= 0 {
+			attempts, interval = defaultReconnectWaitAttempts, defaultReconnectWaitInterval
+		}
+
 		// Wait briefly for reconnection to complete
-		for i := 0; i < 5; i++ {
-			time.Sleep(500 * time.Millisecond)
+		for i := 0; i < attempts; i++ {
+			time.Sleep(interval)
 
 			// Check if connection completed
 			currentStatus := smgr.GetStatus()
@@ -286,7 +910,8 @@ func checkConnection(smgr *servermanager.ServerManager, config *servermanager.Se
 			}
 		}
 
-		logger.Warn("Timed out waiting for reconnection to complete")
+		logger.Warn("Timed out waiting for reconnection to complete",
+			zap.Duration("waited", time.Duration(attempts)*interval))
 		return false
 
 	default:
@@ -346,43 +971,169 @@ func logMetricDesc(metric Metric) {
 			zap.Any("helpField", metric.HelpField),
 			zap.Any("type", metric.Type),
 			zap.Any("buckets", metric.Buckets),
+			zap.Any("quantiles", metric.Quantiles),
 			zap.Any("valueMap", metric.ValueMap),
+			zap.Any("valueExtract", metric.ValueExtract),
 			zap.Any("labels", metric.Labels),
+			zap.Any("labelRename", metric.LabelRename),
 			zap.String("fieldToAppend", metric.FieldToAppend),
 			zap.Bool("ignoreZeroResult", metric.IgnoreZeroResult),
-			zap.Bool("extended", metric.Extended))
+			zap.String("emptyValue", metric.EmptyValue),
+			zap.Bool("extended", metric.Extended),
+			zap.Strings("dateFields", metric.DateFields),
+			zap.String("dateFormat", metric.DateFormat),
+			zap.Any("fieldDateFormat", metric.FieldDateFormat),
+			zap.Duration("cacheTTL", metric.CacheTTL),
+			zap.Duration("timeout", metric.Timeout),
+			zap.String("timestampField", metric.TimestampField),
+			zap.Int("minRows", metric.MinRows),
+			zap.Bool("detectCounterResets", metric.DetectCounterResets),
+			zap.Bool("rawValueMetric", metric.RawValueMetric),
+			zap.Bool("presentMetric", metric.PresentMetric),
+			zap.Bool("tasks", metric.Tasks),
+			zap.Strings("taskGroupBy", metric.TaskGroupBy),
+			zap.Bool("taskDetail", metric.TaskDetail),
+			zap.Int("taskDetailLimit", metric.TaskDetailLimit))
 	}
 }
 
-func validateMetricDesc(metric Metric) bool {
+// getMetricTypeCount returns the number of columns of a metric with the given type
+func getMetricTypeCount(metric Metric, metricType string) int {
+	count := 0
+	for _, t := range metric.Type {
+		if strings.EqualFold(strings.TrimSpace(t), metricType) {
+			count++
+		}
+	}
+	return count
+}
+
+// validateMetricDesc checks a metric definition and returns all problems found.
+// Questionable but usable definitions are only logged as warnings.
+func validateMetricDesc(metric Metric) []error {
+	var problems []error
+
 	if len(metric.Command) == 0 {
-		logger.Error("Missing 'command' in metric definition",
-			zap.Any("help", metric.Help))
-		return false
+		problems = append(problems, errors.New("missing 'command'"))
+	}
+
+	// Task counts need no help, only the per-row metrics do
+	if len(metric.Help) == 0 && (!metric.Tasks || metric.TaskDetail) {
+		problems = append(problems, errors.New("missing 'help'"))
 	}
 
-	if len(metric.Help) == 0 {
-		logger.Error("Missing 'help' in metric definition",
+	if metric.Tasks && len(taskGroupBy(metric)) == 0 {
+		logger.Warn("'Tasks' without 'TaskGroupBy' or 'Labels' only counts all tasks, like the row count",
+			zap.String("command", metric.Command))
+	}
+	if (metric.TaskDetail || len(metric.TaskGroupBy) > 0) && !metric.Tasks {
+		logger.Warn("'TaskDetail' and 'TaskGroupBy' have no effect without 'Tasks'",
+			zap.String("command", metric.Command))
+	}
+	if metric.TaskDetailLimit < 0 {
+		problems = append(problems, fmt.Errorf("invalid 'TaskDetailLimit' %d, must not be negative", metric.TaskDetailLimit))
+	}
+
+	if metric.TimestampField != "" {
+		if _, isDate := metric.FieldDateFormat[metric.TimestampField]; !isDate && !slices.Contains(metric.DateFields, metric.TimestampField) {
+			logger.Warn("'TimestampField' is not a date column, its value must be a Unix timestamp",
+				zap.String("command", metric.Command),
+				zap.String("timestampField", metric.TimestampField))
+		}
+		logger.Warn("Metric uses Siebel timestamps, samples older than the Prometheus lookback delta (5m by default) are not returned by instant queries",
+			zap.String("command", metric.Command),
+			zap.String("timestampField", metric.TimestampField))
+	}
+
+	if metric.DetectCounterResets && getMetricTypeCount(metric, "counter") == 0 {
+		logger.Warn("'DetectCounterResets' has no effect, the metric has no counter columns",
 			zap.String("command", metric.Command))
-		return false
+	}
+
+	if metric.RawValueMetric {
+		if len(metric.ValueMap) == 0 {
+			logger.Warn("'RawValueMetric' has no effect, the metric has no value-mapped columns",
+				zap.String("command", metric.Command))
+		}
+		for _, label := range metric.Labels {
+			if labelName(label, metric.LabelRename) == "value" {
+				problems = append(problems, fmt.Errorf("label %q conflicts with the 'value' label of 'RawValueMetric'", label))
+			}
+		}
+	}
+
+	if metric.MinRows < 0 {
+		problems = append(problems, fmt.Errorf("invalid 'MinRows' %d, must not be negative", metric.MinRows))
+	}
+
+	if metric.Timeout < 0 {
+		problems = append(problems, fmt.Errorf("invalid 'Timeout' %s, must not be negative", metric.Timeout))
+	}
+
+	switch strings.ToLower(metric.EmptyValue) {
+	case "", "zero", "skip", "nan":
+	default:
+		problems = append(problems, fmt.Errorf("invalid 'EmptyValue' %q, must be zero, skip or nan", metric.EmptyValue))
+	}
+
+	for columnName, pattern := range metric.ValueExtract {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid regex in 'ValueExtract' of column %s: %v", columnName, err))
+			continue
+		}
+		if re.NumSubexp() != 1 {
+			problems = append(problems, fmt.Errorf("'ValueExtract' regex %q of column %s must have exactly one capture group", pattern, columnName))
+		}
+	}
+
+	// Renamed labels must not collide with each other or with other labels
+	labelColumns := make(map[string]string, len(metric.Labels))
+	for _, label := range metric.Labels {
+		name := labelName(label, metric.LabelRename)
+		if other, exists := labelColumns[name]; exists {
+			problems = append(problems, fmt.Errorf("label columns %s and %s map to the same label name %s", other, label, name))
+			continue
+		}
+		labelColumns[name] = label
+	}
+	for column := range metric.LabelRename {
+		if !slices.Contains(metric.Labels, column) {
+			logger.Warn("LabelRename refers to a column that is not a label",
+				zap.String("command", metric.Command),
+				zap.String("column", column))
+		}
 	}
 
 	for columnName, metricType := range metric.Type {
 		if strings.ToLower(metricType) == "histogram" {
-			if len(metric.Buckets) == 0 {
-				logger.Error("Missing 'buckets' for histogram metric",
-					zap.String("command", metric.Command))
-				return false
+			if _, exists := metric.Buckets[columnName]; !exists {
+				problems = append(problems, fmt.Errorf("missing 'buckets' for histogram column %s", columnName))
 			}
-			_, exists := metric.Buckets[columnName]
-			if !exists {
-				logger.Error("Missing bucket configuration for column",
-					zap.String("command", metric.Command),
-					zap.String("column", columnName))
-				return false
+		}
+
+		if strings.ToLower(metricType) == "info" && len(metric.Labels) == 0 {
+			problems = append(problems, fmt.Errorf("info column %s requires at least one label", columnName))
+		}
+
+		if strings.ToLower(metricType) == "summary" {
+			quantiles, exists := metric.Quantiles[columnName]
+			if !exists || len(quantiles) == 0 {
+				problems = append(problems, fmt.Errorf("missing 'quantiles' for summary column %s", columnName))
+			}
+			for field, q := range quantiles {
+				quantile, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+				if err != nil || quantile < 0 || quantile > 1 {
+					problems = append(problems, fmt.Errorf("invalid quantile %q of field %s for summary column %s, must be between 0 and 1", q, field, columnName))
+				}
+			}
+			// Columns can only be checked when the command selects them with a show clause
+			command := strings.ToLower(metric.Command)
+			if strings.Contains(command, " show ") && !strings.Contains(command[strings.Index(command, " show "):], "count") {
+				problems = append(problems, fmt.Errorf("summary column %s requires a 'count' column in the command output", columnName))
 			}
 		}
 	}
 
-	return true
+	return problems
 }
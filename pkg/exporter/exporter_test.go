@@ -0,0 +1,718 @@
+package exporter
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+// testServerManagerConfig returns a configuration for the fake srvrmgr
+func testServerManagerConfig(fake *srvrmgrtest.Fake) servermanager.ServerManagerConfig {
+	config := servermanager.NewConfig()
+	config.Gateway = "gateway:2320"
+	config.Enterprise = "SBA_81"
+	config.Server = "SRV01"
+	config.User = "SADMIN"
+	config.Password = "secret"
+	config.SrvrmgrPath = fake.Path
+	config.TimeoutResyncWait = 2 * time.Second
+	return config
+}
+
+// connectTestServerManager connects to the fake srvrmgr and disconnects at the end of the test
+func connectTestServerManager(t testing.TB, config servermanager.ServerManagerConfig) *servermanager.ServerManager {
+	t.Helper()
+	sm := servermanager.NewServerManager(config)
+	if err := sm.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() {
+		sm.Disconnect()
+	})
+	return sm
+}
+
+// newTestExporter connects to the fake srvrmgr and returns an exporter scraping it
+// with the given metric definitions. configure may change the configuration first.
+func newTestExporter(t testing.TB, fake *srvrmgrtest.Fake, metrics string, configure func(*ExporterConfig)) *Exporter {
+	t.Helper()
+
+	smConfig := testServerManagerConfig(fake)
+	config := NewDefaultExporterConfig()
+	config.ServerManagerConfig = &smConfig
+	config.DefaultMetricsFile = writeMetricsFile(t, "metrics.toml", metrics)
+	if configure != nil {
+		configure(config)
+	}
+
+	sm := connectTestServerManager(t, *config.ServerManagerConfig)
+	e := NewExporter([]*servermanager.ServerManager{sm}, config)
+	t.Cleanup(e.Close)
+	return e
+}
+
+// gather scrapes the exporter through a registry, as the metrics endpoint does
+func gather(t testing.TB, e *Exporter) []*dto.MetricFamily {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	return families
+}
+
+// sampleValue returns the value of the sample of a metric having all the given labels
+func sampleValue(families []*dto.MetricFamily, name string, labels map[string]string) (float64, bool) {
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if !hasLabels(m, labels) {
+				continue
+			}
+			switch {
+			case m.Gauge != nil:
+				return m.GetGauge().GetValue(), true
+			case m.Counter != nil:
+				return m.GetCounter().GetValue(), true
+			case m.Untyped != nil:
+				return m.GetUntyped().GetValue(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	for name, value := range labels {
+		found := false
+		for _, pair := range m.GetLabel() {
+			if pair.GetName() == name && pair.GetValue() == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// collect runs a scrape, discarding the metrics
+func collect(e *Exporter) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	e.Collect(ch)
+	close(ch)
+	<-done
+}
+
+// slowMetrics are metric definitions of four commands the fake answers after a delay
+const slowMetrics = `
+[[Metric]]
+Command = "list comp 1"
+Subsystem = "slow_1"
+Labels = [ "NAME" ]
+[Metric.Help]
+VALUE = "Value."
+
+[[Metric]]
+Command = "list comp 2"
+Subsystem = "slow_2"
+Labels = [ "NAME" ]
+[Metric.Help]
+VALUE = "Value."
+
+[[Metric]]
+Command = "list comp 3"
+Subsystem = "slow_3"
+Labels = [ "NAME" ]
+[Metric.Help]
+VALUE = "Value."
+
+[[Metric]]
+Command = "list comp 4"
+Subsystem = "slow_4"
+Labels = [ "NAME" ]
+[Metric.Help]
+VALUE = "Value."
+`
+
+// respondSlowly makes the fake answer the commands of slowMetrics after delay
+func respondSlowly(fake *srvrmgrtest.Fake, delay time.Duration) {
+	for _, n := range []string{"1", "2", "3", "4"} {
+		fake.Respond("list comp "+n, srvrmgrtest.Response{
+			Lines: srvrmgrtest.Table([]string{"NAME", "VALUE"}, []string{"Comp" + n, n}),
+			Delay: delay,
+		})
+	}
+}
+
+func TestScrapeConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{name: "sequential", concurrency: 1},
+		{name: "two sessions", concurrency: 2},
+		{name: "one session per metric", concurrency: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			respondSlowly(fake, 0)
+			e := newTestExporter(t, fake, slowMetrics, func(config *ExporterConfig) {
+				config.ScrapeConcurrency = tt.concurrency
+			})
+
+			families := gather(t, e)
+			for _, n := range []string{"1", "2", "3", "4"} {
+				name := "siebel_slow_" + n + "_value"
+				if got, ok := sampleValue(families, name, nil); !ok || got != float64(n[0]-'0') {
+					t.Errorf("%s = %v (found %v), want %s", name, got, ok, n)
+				}
+			}
+			if got, _ := sampleValue(families, "siebel_up", nil); got != 1 {
+				t.Errorf("siebel_up = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func BenchmarkScrapeConcurrency(b *testing.B) {
+	for _, concurrency := range []int{1, 2, 4} {
+		b.Run("sessions="+strconv.Itoa(concurrency), func(b *testing.B) {
+			fake := srvrmgrtest.New(b)
+			respondSlowly(fake, 200*time.Millisecond)
+			e := newTestExporter(b, fake, slowMetrics, func(config *ExporterConfig) {
+				config.ScrapeConcurrency = concurrency
+			})
+
+			// Open the pooled sessions before timing
+			collect(e)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				collect(e)
+			}
+		})
+	}
+}
+
+func TestValidateMetricDescSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		wantErr    bool
+	}{
+		{name: "valid", definition: summaryMetric},
+		{
+			name: "missing quantiles",
+			definition: `
+[[Metric]]
+Command = "list statistics show SUM, count"
+Subsystem = "stats"
+[Metric.Help]
+SUM = "Sum."
+[Metric.Type]
+SUM = "summary"
+`,
+			wantErr: true,
+		},
+		{
+			name: "quantile above 1",
+			definition: `
+[[Metric]]
+Command = "list statistics show SUM, count, P50"
+Subsystem = "stats"
+[Metric.Help]
+SUM = "Sum."
+[Metric.Type]
+SUM = "summary"
+[Metric.Quantiles.SUM]
+P50 = "50"
+`,
+			wantErr: true,
+		},
+		{
+			name: "no count column",
+			definition: `
+[[Metric]]
+Command = "list statistics show SUM, P50"
+Subsystem = "stats"
+[Metric.Help]
+SUM = "Sum."
+[Metric.Type]
+SUM = "summary"
+[Metric.Quantiles.SUM]
+P50 = "0.5"
+`,
+			wantErr: true,
+		},
+		{
+			name: "count not checked without show clause",
+			definition: `
+[[Metric]]
+Command = "list statistics"
+Subsystem = "stats"
+[Metric.Help]
+SUM = "Sum."
+[Metric.Type]
+SUM = "summary"
+[Metric.Quantiles.SUM]
+P50 = "0.5"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validateMetricDesc(decodeMetric(t, tt.definition))
+			if tt.wantErr != (len(problems) > 0) {
+				t.Errorf("validateMetricDesc() = %v, want problems: %v", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMetricDescInfoWithoutLabels(t *testing.T) {
+	metric := decodeMetric(t, componentInfoMetric)
+	metric.Labels = nil
+	if problems := validateMetricDesc(metric); len(problems) == 0 {
+		t.Error("validateMetricDesc() of an info metric without labels = no problems, want one")
+	}
+}
+
+func TestValidateMetricDescLabelRenameCollisions(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      []string
+		labelRename map[string]string
+		wantErr     bool
+	}{
+		{name: "distinct names", labels: []string{"CC_ALIAS", "CG_ALIAS"}, labelRename: map[string]string{"CC_ALIAS": "component"}},
+		{name: "two sources renamed to the same name", labels: []string{"CC_ALIAS", "CG_ALIAS"}, labelRename: map[string]string{"CC_ALIAS": "name", "CG_ALIAS": "name"}, wantErr: true},
+		{name: "rename to another column's name", labels: []string{"CC_ALIAS", "CG_ALIAS"}, labelRename: map[string]string{"CC_ALIAS": "cg_alias"}, wantErr: true},
+		{name: "names equal after cleaning", labels: []string{"CC_ALIAS", "CG_ALIAS"}, labelRename: map[string]string{"CC_ALIAS": "Component", "CG_ALIAS": "component"}, wantErr: true},
+		{name: "swapped names", labels: []string{"CC_ALIAS", "CG_ALIAS"}, labelRename: map[string]string{"CC_ALIAS": "cg_alias", "CG_ALIAS": "cc_alias"}},
+		{name: "rename of a column that is not a label", labels: []string{"CC_ALIAS"}, labelRename: map[string]string{"CG_ALIAS": "group"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{
+				Command:     "list comp show CC_ALIAS, CG_ALIAS, CP_NUM_RUN_TASKS",
+				Subsystem:   "list_comp",
+				Labels:      tt.labels,
+				LabelRename: tt.labelRename,
+				Help:        map[string]string{"CP_NUM_RUN_TASKS": "Number of running tasks."},
+			}
+			problems := validateMetricDesc(metric)
+			if tt.wantErr != (len(problems) > 0) {
+				t.Errorf("validateMetricDesc() = %v, want problems: %v", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConflictingLabel(t *testing.T) {
+	targetLabels := prometheus.Labels{"server": "SRV01"}
+
+	tests := []struct {
+		name        string
+		labels      []string
+		labelRename map[string]string
+		want        string
+	}{
+		{name: "no conflict", labels: []string{"CC_ALIAS"}},
+		{name: "column named like a target label", labels: []string{"SERVER"}, want: "server"},
+		{name: "renamed to a target label", labels: []string{"SV_NAME"}, labelRename: map[string]string{"SV_NAME": "server"}, want: "server"},
+		{name: "renamed away from a target label", labels: []string{"SERVER"}, labelRename: map[string]string{"SERVER": "siebel_server"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{Labels: tt.labels, LabelRename: tt.labelRename}
+			if got := conflictingLabel(metric, targetLabels); got != tt.want {
+				t.Errorf("conflictingLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMetricDescValueExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{name: "one capture group", pattern: `\((\d+)\)`},
+		{name: "non-capturing groups are allowed", pattern: `(?:Running|Online) \((\d+)\)`},
+		{name: "invalid regex", pattern: `\((\d+`, wantErr: true},
+		{name: "no capture group", pattern: `\d+`, wantErr: true},
+		{name: "two capture groups", pattern: `(\w+) \((\d+)\)`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{
+				Command:      "list procs show CC_ALIAS, TK_STATUS",
+				Subsystem:    "list_procs",
+				Help:         map[string]string{"TK_STATUS": "Process ID of the component."},
+				ValueExtract: map[string]string{"TK_STATUS": tt.pattern},
+			}
+			problems := validateMetricDesc(metric)
+			if tt.wantErr != (len(problems) > 0) {
+				t.Errorf("validateMetricDesc() = %v, want problems: %v", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExporterTimeZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeZone string
+		want     string
+	}{
+		{name: "default", want: "UTC"},
+		{name: "IANA name", timeZone: "Europe/Berlin", want: "Europe/Berlin"},
+		{name: "unknown zone falls back to UTC", timeZone: "Mars/Olympus_Mons", want: "UTC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewDefaultExporterConfig()
+			if tt.timeZone != "" {
+				config.TimeZone = tt.timeZone
+			}
+			e := newExporter(nil, config)
+			if got := e.location.String(); got != tt.want {
+				t.Errorf("location = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMetricDescEmptyValue(t *testing.T) {
+	for _, tt := range []struct {
+		emptyValue string
+		wantErr    bool
+	}{
+		{emptyValue: ""},
+		{emptyValue: "zero"},
+		{emptyValue: "skip"},
+		{emptyValue: "nan"},
+		{emptyValue: "SKIP"},
+		{emptyValue: "null", wantErr: true},
+	} {
+		t.Run(tt.emptyValue, func(t *testing.T) {
+			metric := Metric{
+				Command:    "list comp show CC_ALIAS, CP_NUM_RUN_TASKS",
+				Subsystem:  "list_comp",
+				Help:       map[string]string{"CP_NUM_RUN_TASKS": "Number of running tasks."},
+				EmptyValue: tt.emptyValue,
+			}
+			problems := validateMetricDesc(metric)
+			if tt.wantErr != (len(problems) > 0) {
+				t.Errorf("validateMetricDesc() = %v, want problems: %v", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+const taskCountMetric = `
+[[Metric]]
+Command = "list tasks show CC_ALIAS, TK_COUNT"
+Subsystem = "tasks"
+Labels = [ "CC_ALIAS" ]
+[Metric.Help]
+TK_COUNT = "Number of tasks."
+`
+
+func TestScrapeEmptyResults(t *testing.T) {
+	tests := []struct {
+		name           string
+		lines          []string
+		wantRows       float64
+		wantErrors     float64
+		wantUp         float64
+		wantTaskSeries bool
+	}{
+		{
+			name:     "0 rows returned",
+			lines:    srvrmgrtest.Table([]string{"CC_ALIAS", "TK_COUNT"}),
+			wantRows: 0, wantErrors: 0, wantUp: 1,
+		},
+		{
+			name:     "rows returned",
+			lines:    srvrmgrtest.Table([]string{"CC_ALIAS", "TK_COUNT"}, []string{"EAIObjMgr_enu", "4"}),
+			wantRows: 1, wantErrors: 0, wantUp: 1, wantTaskSeries: true,
+		},
+		{
+			name:     "malformed output",
+			lines:    []string{"", "SBL-ADM-02071: The specified component was not found.", "", "0 rows returned."},
+			wantRows: 0, wantErrors: 1, wantUp: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond("list tasks show CC_ALIAS, TK_COUNT", srvrmgrtest.Response{Lines: tt.lines})
+			e := newTestExporter(t, fake, taskCountMetric, nil)
+
+			families := gather(t, e)
+			if got, _ := sampleValue(families, "siebel_exporter_metric_rows_returned", map[string]string{"subsystem": "tasks"}); got != tt.wantRows {
+				t.Errorf("siebel_exporter_metric_rows_returned = %v, want %v", got, tt.wantRows)
+			}
+			if got, _ := sampleValue(families, "siebel_exporter_scrape_errors_total", nil); got != tt.wantErrors {
+				t.Errorf("siebel_exporter_scrape_errors_total = %v, want %v", got, tt.wantErrors)
+			}
+			if got, _ := sampleValue(families, "siebel_up", nil); got != tt.wantUp {
+				t.Errorf("siebel_up = %v, want %v", got, tt.wantUp)
+			}
+			if _, found := sampleValue(families, "siebel_tasks_tk_count", nil); found != tt.wantTaskSeries {
+				t.Errorf("siebel_tasks_tk_count found = %v, want %v", found, tt.wantTaskSeries)
+			}
+		})
+	}
+}
+
+func TestScrapeMetricTimeout(t *testing.T) {
+	const metrics = `
+[[Metric]]
+Command = "list slow"
+Subsystem = "slow"
+Labels = [ "NAME" ]
+Timeout = "2s"
+[Metric.Help]
+VALUE = "Value of a command answering late."
+
+[[Metric]]
+Command = "list fast"
+Subsystem = "fast"
+Labels = [ "NAME" ]
+Timeout = "5s"
+[Metric.Help]
+VALUE = "Value of a command answering in time."
+`
+
+	tests := []struct {
+		name       string
+		delay      time.Duration
+		wantSlow   bool
+		wantErrors float64
+		wantUp     float64
+	}{
+		{name: "command within its timeout", delay: 100 * time.Millisecond, wantSlow: true, wantErrors: 0, wantUp: 1},
+		{name: "command exceeding its timeout", delay: 3 * time.Second, wantSlow: false, wantErrors: 1, wantUp: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond("list slow", srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table([]string{"NAME", "VALUE"}, []string{"Slow", "1"}),
+				Delay: tt.delay,
+			})
+			fake.Respond("list fast", srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table([]string{"NAME", "VALUE"}, []string{"Fast", "2"}),
+			})
+			e := newTestExporter(t, fake, metrics, nil)
+
+			families := gather(t, e)
+			if _, found := sampleValue(families, "siebel_slow_value", nil); found != tt.wantSlow {
+				t.Errorf("siebel_slow_value found = %v, want %v", found, tt.wantSlow)
+			}
+			if got, found := sampleValue(families, "siebel_fast_value", nil); !found || got != 2 {
+				t.Errorf("siebel_fast_value = %v (found %v), want 2", got, found)
+			}
+			if got, _ := sampleValue(families, "siebel_exporter_scrape_errors_total", nil); got != tt.wantErrors {
+				t.Errorf("siebel_exporter_scrape_errors_total = %v, want %v", got, tt.wantErrors)
+			}
+			if got, _ := sampleValue(families, "siebel_up", nil); got != tt.wantUp {
+				t.Errorf("siebel_up = %v, want %v", got, tt.wantUp)
+			}
+		})
+	}
+}
+
+func TestValidateMetricDescTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "unset", timeout: 0},
+		{name: "positive", timeout: 10 * time.Second},
+		{name: "negative", timeout: -time.Second, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{
+				Command:   "list comp show CC_ALIAS, CP_NUM_RUN_TASKS",
+				Subsystem: "list_comp",
+				Help:      map[string]string{"CP_NUM_RUN_TASKS": "Number of running tasks."},
+				Timeout:   tt.timeout,
+			}
+			problems := validateMetricDesc(metric)
+			if tt.wantErr != (len(problems) > 0) {
+				t.Errorf("validateMetricDesc() = %v, want problems: %v", problems, tt.wantErr)
+			}
+			if tt.wantErr && len(problems) > 0 && !strings.Contains(problems[0].Error(), "must not be negative") {
+				t.Errorf("validateMetricDesc() = %v, want the timeout to be reported as negative", problems)
+			}
+		})
+	}
+}
+
+// Commands the exporter sends to check that the gateway and application server are up
+const (
+	gatewayPing     = "list ent param MaxThreads show PA_VALUE"
+	applicationPing = "list state values show STATEVAL_NAME"
+)
+
+func TestUpTruthTable(t *testing.T) {
+	const metrics = `
+[[Metric]]
+Command = "list values"
+Subsystem = "values"
+Labels = [ "NAME" ]
+[Metric.Help]
+VALUE = "Value of a command."
+`
+
+	valuesTable := srvrmgrtest.Table([]string{"NAME", "VALUE"}, []string{"First", "1"})
+	malformed := []string{"", "SBL-ADM-02071: The specified component was not found.", "", "0 rows returned."}
+	lost := srvrmgrtest.Response{Lines: []string{"", "0 rows returned."}, ExitAfterReply: true}
+
+	tests := []struct {
+		name            string
+		responses       map[string]srvrmgrtest.Response
+		sendFirst       string // command sent before the scrape
+		wantGateway     float64
+		wantApplication float64
+		wantUp          float64
+	}{
+		{
+			name:        "both servers up and scrape succeeded",
+			responses:   map[string]srvrmgrtest.Response{"list values": {Lines: valuesTable}},
+			wantGateway: 1, wantApplication: 1, wantUp: 1,
+		},
+		{
+			name: "gateway server down",
+			responses: map[string]srvrmgrtest.Response{
+				"list servers": lost,
+				"list values":  {Lines: valuesTable},
+			},
+			sendFirst:   "list servers",
+			wantGateway: 0, wantApplication: 0, wantUp: 0,
+		},
+		{
+			name: "application server down",
+			responses: map[string]srvrmgrtest.Response{
+				gatewayPing:   lost,
+				"list values": {Lines: valuesTable},
+			},
+			wantGateway: 1, wantApplication: 0, wantUp: 0,
+		},
+		{
+			name:        "both servers up and scrape failed",
+			responses:   map[string]srvrmgrtest.Response{"list values": {Lines: malformed}},
+			wantGateway: 1, wantApplication: 1, wantUp: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			for command, response := range tt.responses {
+				fake.Respond(command, response)
+			}
+			e := newTestExporter(t, fake, metrics, nil)
+			if tt.sendFirst != "" {
+				if _, err := e.ServerManagers()["SRV01"].SendCommand(tt.sendFirst); err != nil {
+					t.Fatalf("SendCommand(%q) error = %v", tt.sendFirst, err)
+				}
+			}
+
+			families := gather(t, e)
+			if got, _ := sampleValue(families, "siebel_gateway_server_up", nil); got != tt.wantGateway {
+				t.Errorf("siebel_gateway_server_up = %v, want %v", got, tt.wantGateway)
+			}
+			if got, _ := sampleValue(families, "siebel_application_server_up", nil); got != tt.wantApplication {
+				t.Errorf("siebel_application_server_up = %v, want %v", got, tt.wantApplication)
+			}
+			if got, found := sampleValue(families, "siebel_up", nil); !found || got != tt.wantUp {
+				t.Errorf("siebel_up = %v (found %v), want %v", got, found, tt.wantUp)
+			}
+		})
+	}
+}
+
+func TestServerDownIsScrapeError(t *testing.T) {
+	lost := srvrmgrtest.Response{Lines: []string{"", "0 rows returned."}, ExitAfterReply: true}
+
+	tests := []struct {
+		name            string
+		scrapeError     bool
+		lostAfter       string // srvrmgr exits after replying to this command
+		sendFirst       bool   // send lostAfter before the scrape
+		wantGateway     float64
+		wantApplication float64
+		wantErrors      float64
+	}{
+		{name: "gateway down as scrape error", scrapeError: true, lostAfter: "list servers", sendFirst: true, wantGateway: 0, wantApplication: 0, wantErrors: 1},
+		{name: "gateway down as clean scrape", scrapeError: false, lostAfter: "list servers", sendFirst: true, wantGateway: 0, wantApplication: 0, wantErrors: 0},
+		{name: "application server down as scrape error", scrapeError: true, lostAfter: gatewayPing, wantGateway: 1, wantApplication: 0, wantErrors: 1},
+		{name: "application server down as clean scrape", scrapeError: false, lostAfter: gatewayPing, wantGateway: 1, wantApplication: 0, wantErrors: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond(tt.lostAfter, lost)
+			e := newTestExporter(t, fake, taskCountMetric, func(config *ExporterConfig) {
+				config.ServerDownIsScrapeError = tt.scrapeError
+			})
+			if tt.sendFirst {
+				if _, err := e.ServerManagers()["SRV01"].SendCommand(tt.lostAfter); err != nil {
+					t.Fatalf("SendCommand(%q) error = %v", tt.lostAfter, err)
+				}
+			}
+
+			families := gather(t, e)
+			if got, _ := sampleValue(families, "siebel_gateway_server_up", nil); got != tt.wantGateway {
+				t.Errorf("siebel_gateway_server_up = %v, want %v", got, tt.wantGateway)
+			}
+			if got, _ := sampleValue(families, "siebel_application_server_up", nil); got != tt.wantApplication {
+				t.Errorf("siebel_application_server_up = %v, want %v", got, tt.wantApplication)
+			}
+			if got, _ := sampleValue(families, "siebel_up", nil); got != 0 {
+				t.Errorf("siebel_up = %v, want 0", got)
+			}
+			if got, _ := sampleValue(families, "siebel_exporter_scrape_errors_total", nil); got != tt.wantErrors {
+				t.Errorf("siebel_exporter_scrape_errors_total = %v, want %v", got, tt.wantErrors)
+			}
+			if got, _ := sampleValue(families, "siebel_exporter_last_scrape_error", nil); got != tt.wantErrors {
+				t.Errorf("siebel_exporter_last_scrape_error = %v, want %v", got, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+)
+
+// logStreamCollector exports the load of the /logs/stream fan-out
+type logStreamCollector struct {
+	subscribers prometheus.GaugeFunc
+	dropped     prometheus.CounterFunc
+}
+
+// NewLogStreamCollector returns a collector of the number of active log stream
+// subscribers and the log messages dropped for subscribers that did not keep up
+func NewLogStreamCollector(config *ExporterConfig) prometheus.Collector {
+	namespace := metricNamespace(config)
+	return &logStreamCollector{
+		subscribers: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "log_subscribers",
+			Help:      "Number of clients currently streaming the logs.",
+		}, func() float64 { return float64(logger.LogSubscribers()) }),
+		dropped: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "log_messages_dropped_total",
+			Help:      "Total number of log messages not streamed to a client because its buffer was full.",
+		}, func() float64 { return float64(logger.LogMessagesDropped()) }),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *logStreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.subscribers.Describe(ch)
+	c.dropped.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *logStreamCollector) Collect(ch chan<- prometheus.Metric) {
+	c.subscribers.Collect(ch)
+	c.dropped.Collect(ch)
+}
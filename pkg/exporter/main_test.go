@@ -0,0 +1,15 @@
+package exporter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+func TestMain(m *testing.M) {
+	srvrmgrtest.Main()
+	logger.Init(logger.ErrorLevel)
+	os.Exit(m.Run())
+}
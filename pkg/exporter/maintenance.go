@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a recurring daily time range during which scraping is paused.
+// A window whose end is before its start runs past midnight into the next day.
+type MaintenanceWindow struct {
+	days  [7]bool       // Weekdays on which the window starts, indexed by time.Weekday
+	start time.Duration // Offset of the start from midnight
+	end   time.Duration // Offset of the end from midnight
+	spec  string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseMaintenanceWindows parses a comma-separated list of windows of the form
+// "[DAY[-DAY] ]HH:MM-HH:MM", e.g. "22:00-02:00" or "Sat-Sun 01:00-05:00".
+// Windows without days apply to every day.
+func ParseMaintenanceWindows(spec string) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		window, err := parseMaintenanceWindow(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", item, err)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func parseMaintenanceWindow(spec string) (MaintenanceWindow, error) {
+	window := MaintenanceWindow{spec: spec}
+
+	fields := strings.Fields(spec)
+	var timeRange string
+	switch len(fields) {
+	case 1:
+		timeRange = fields[0]
+		for i := range window.days {
+			window.days[i] = true
+		}
+	case 2:
+		timeRange = fields[1]
+		if err := parseDays(fields[0], &window.days); err != nil {
+			return window, err
+		}
+	default:
+		return window, fmt.Errorf("expected \"[DAY[-DAY] ]HH:MM-HH:MM\"")
+	}
+
+	start, end, found := strings.Cut(timeRange, "-")
+	if !found {
+		return window, fmt.Errorf("expected a time range like 22:00-02:00")
+	}
+
+	var err error
+	if window.start, err = parseTimeOfDay(start); err != nil {
+		return window, err
+	}
+	if window.end, err = parseTimeOfDay(end); err != nil {
+		return window, err
+	}
+	if window.start == window.end {
+		return window, fmt.Errorf("start and end are equal")
+	}
+
+	return window, nil
+}
+
+// parseDays parses a single day or a range of days like "Mon-Fri", which may wrap around the week
+func parseDays(spec string, days *[7]bool) error {
+	first, last, isRange := strings.Cut(strings.ToLower(spec), "-")
+	if !isRange {
+		last = first
+	}
+
+	from, ok := weekdays[first]
+	if !ok {
+		return fmt.Errorf("unknown day %q", first)
+	}
+	to, ok := weekdays[last]
+	if !ok {
+		return fmt.Errorf("unknown day %q", last)
+	}
+
+	for day := from; ; day = (day + 1) % 7 {
+		days[day] = true
+		if day == to {
+			break
+		}
+	}
+	return nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t is inside the window, in the location of t
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	weekday := t.Weekday()
+
+	if w.start < w.end {
+		return w.days[weekday] && offset >= w.start && offset < w.end
+	}
+
+	// The window runs past midnight, so it may have started the day before
+	yesterday := (weekday + 6) % 7
+	return (w.days[weekday] && offset >= w.start) || (w.days[yesterday] && offset < w.end)
+}
+
+// String returns the window as it was configured
+func (w MaintenanceWindow) String() string {
+	return w.spec
+}
@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descNamePattern extracts the metric name from the string of a prometheus.Desc,
+// which has no accessor for it
+var descNamePattern = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// metricNames keeps the sorted names of the metrics sent by the last collection,
+// so they can be listed without running the srvrmgr commands again
+type metricNames struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// record forwards the metrics sent to the returned channel to ch and remembers
+// their names. The returned function must be called once collecting is done.
+func (n *metricNames) record(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	seen := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	names := make(map[string]bool)
+
+	go func() {
+		defer close(done)
+		for m := range seen {
+			if match := descNamePattern.FindStringSubmatch(m.Desc().String()); match != nil {
+				names[match[1]] = true
+			}
+			ch <- m
+		}
+	}()
+
+	return seen, func() {
+		close(seen)
+		<-done
+
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+
+		n.mu.Lock()
+		n.names = sorted
+		n.mu.Unlock()
+	}
+}
+
+// MetricNames returns the sorted names of the metrics of the last scrape, empty
+// before the first scrape
+func (e *Exporter) MetricNames() []string {
+	e.metricNames.mu.Lock()
+	defer e.metricNames.mu.Unlock()
+	return append([]string(nil), e.metricNames.names...)
+}
@@ -0,0 +1,43 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolDescs are the descriptors of the srvrmgr session pool metrics, exported
+// for targets scraped with a scrape concurrency above 1
+type poolDescs struct {
+	maxSize, size, idle, inUse, waits, waitSeconds, idleClosed *prometheus.Desc
+}
+
+func newPoolDescs(namespace string, labelNames []string) poolDescs {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "srvrmgr", name), help, labelNames, nil)
+	}
+	return poolDescs{
+		maxSize:     desc("pool_max_size", "Maximum number of srvrmgr sessions of the pool."),
+		size:        desc("pool_size", "Number of open srvrmgr sessions of the pool, idle or in use."),
+		idle:        desc("pool_idle", "Number of open srvrmgr sessions of the pool not in use."),
+		inUse:       desc("pool_in_use", "Number of srvrmgr sessions of the pool running a command."),
+		waits:       desc("pool_wait_total", "Total number of times a command waited for a free srvrmgr session of the pool."),
+		waitSeconds: desc("pool_wait_seconds_total", "Total time commands waited for a free srvrmgr session of the pool."),
+		idleClosed:  desc("pool_idle_closed_total", "Total number of srvrmgr sessions of the pool closed for being idle longer than the idle timeout."),
+	}
+}
+
+// collectPools sends the usage of the session pools of all targets
+func (e *Exporter) collectPools(ch chan<- prometheus.Metric) {
+	for _, t := range e.targets {
+		if t.pool == nil {
+			continue
+		}
+		stats := t.pool.Stats()
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.maxSize, prometheus.GaugeValue, float64(stats.MaxOpen), t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.size, prometheus.GaugeValue, float64(stats.Open), t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.idle, prometheus.GaugeValue, float64(stats.Idle), t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.inUse, prometheus.GaugeValue, float64(stats.InUse), t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.waits, prometheus.CounterValue, float64(stats.WaitCount), t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.waitSeconds, prometheus.CounterValue, stats.WaitDuration.Seconds(), t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(e.poolDescs.idleClosed, prometheus.CounterValue, float64(stats.IdleClosed), t.labelValues...)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -17,29 +19,85 @@ import (
 )
 
 // Process in chunks to avoid memory issues with large datasets
-const chunkSize = 1000 // Process results in chunks of 1000 rows
+const defaultChunkSize = 1000 // Process results in chunks of 1000 rows unless configured otherwise
 
-// generic method for retrieving metrics.
-func scrapeGenericValues(namespace string, dateFormat string, disableEmptyMetricsOverride bool, smgr *servermanager.ServerManager, ch *chan<- prometheus.Metric, metric Metric) error {
+// Rough per-row and per-cell overhead of the parsed dataset, used to estimate its size
+const (
+	rowOverheadBytes  = 48
+	cellOverheadBytes = 32
+)
+
+// errScrapeMemoryExceeded is returned when the parsed dataset of a command exceeds MaxScrapeMemory
+var errScrapeMemoryExceeded = errors.New("scrape dataset exceeds the configured memory limit")
+
+// generic method for retrieving metrics. It returns the number of rows the command returned.
+func scrapeGenericValues(namespace string, config *ExporterConfig, location *time.Location, smgr *servermanager.ServerManager, cache *resultCache, resets *counterTracker, constLabels prometheus.Labels, ch *chan<- prometheus.Metric, metric Metric) (int, error) {
 	logger.Debug("Scraping generic values",
 		zap.String("command", metric.Command),
 		zap.String("subsystem", metric.Subsystem))
 
 	startTime := time.Now()
-	siebelData, err := getSiebelData(smgr, metric.Command, dateFormat, disableEmptyMetricsOverride)
+
+	// Columns without explicit type get the configured default type
+	if strings.EqualFold(config.DefaultMetricType, "counter") {
+		metric.defaultType = prometheus.CounterValue
+	}
+	metric.collapseLabelWhitespace = config.CollapseLabelWhitespace
+	metric.disableEmptyMetricsOverride = config.DisableEmptyMetricsOverride
+
+	// Reuse the output of rarely changing commands while it is fresh
+	var lines []string
+	var err error
+	cached := false
+	if metric.CacheTTL > 0 && cache != nil {
+		lines, cached = cache.get(metric.Command)
+	}
+	if cached {
+		logger.Debug("Using cached command result",
+			zap.String("command", metric.Command),
+			zap.Duration("cacheTTL", metric.CacheTTL))
+	} else {
+		lines, err = fetchCommandOutput(smgr, metric)
+		if err == nil && metric.CacheTTL > 0 && cache != nil {
+			cache.put(metric.Command, lines, metric.CacheTTL)
+		}
+	}
+
+	// Parse after the cache lookup, the empty value and date settings are per metric
+	var siebelData []map[string]string
+	if err == nil {
+		siebelData, err = parseSiebelData(lines, metric, config.DateFormats, location, config.DisableEmptyMetricsOverride, config.MaxScrapeMemory)
+	}
 	dataFetchTime := time.Since(startTime)
 
 	logger.Debug("Data fetched from Siebel",
 		zap.Duration("fetchTime", dataFetchTime),
 		zap.Int("rowCount", len(siebelData)),
+		zap.Bool("cached", cached),
 		zap.Bool("hasError", err != nil))
 
 	if err != nil {
-		return err
+		return len(siebelData), err
+	}
+
+	// Help authors of metrics files discover columns they could use
+	if config.DebugUnmappedColumns && len(siebelData) > 0 {
+		debugColumnDesc := prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "debug", "column"),
+			"Column returned by the command of this subsystem but not used by its metric definition.",
+			[]string{"subsystem", "column"}, constLabels)
+		for _, column := range unusedColumns(siebelData[0], metric) {
+			*ch <- prometheus.MustNewConstMetric(debugColumnDesc, prometheus.GaugeValue, 1, metric.Subsystem, column)
+		}
 	}
 
 	processingStart := time.Now()
-	metricsCount, err := generatePrometheusMetrics(siebelData, namespace, ch, metric)
+	detailRows, taskSeries := siebelData, 0
+	if metric.Tasks {
+		detailRows, taskSeries = exportTaskCounts(siebelData, namespace, constLabels, ch, metric)
+	}
+	metricsCount, err := generatePrometheusMetrics(detailRows, namespace, constLabels, resets, ch, metric, config.ChunkSize, config.ForceGCBetweenChunks)
+	metricsCount += taskSeries
 	processingTime := time.Since(processingStart)
 
 	logger.Debug("Metrics processed",
@@ -48,14 +106,41 @@ func scrapeGenericValues(namespace string, dateFormat string, disableEmptyMetric
 		zap.Duration("processingTime", processingTime))
 
 	if err != nil {
-		return err
+		return len(siebelData), err
+	}
+
+	// Fewer rows than expected point to a problem even if the command succeeded
+	if len(siebelData) < metric.MinRows {
+		logger.Warn("Command returned fewer rows than expected",
+			zap.String("command", metric.Command),
+			zap.String("subsystem", metric.Subsystem),
+			zap.Int("rows", len(siebelData)),
+			zap.Int("minRows", metric.MinRows))
+		return len(siebelData), fmt.Errorf("command returned %d rows, expected at least %d", len(siebelData), metric.MinRows)
 	}
 
-	if metricsCount == 0 && !metric.IgnoreZeroResult {
-		logger.Warn("No metrics found while parsing",
+	// Commands legitimately return no rows, e.g. when no task is running
+	if len(siebelData) == 0 {
+		logger.Debug("Command returned no rows",
 			zap.String("command", metric.Command),
 			zap.String("subsystem", metric.Subsystem))
-		return fmt.Errorf("no metrics found while parsing (metrics count: %d)", metricsCount)
+		return 0, nil
+	}
+
+	// Rows without any metric usually mean the definition does not match the output
+	if metricsCount == 0 {
+		if metric.IgnoreZeroResult || config.IgnoreZeroResult {
+			logger.Debug("Command rows yielded no metrics, ignored as configured",
+				zap.String("command", metric.Command),
+				zap.String("subsystem", metric.Subsystem),
+				zap.Int("rows", len(siebelData)))
+		} else {
+			logger.Warn("Command returned rows but none could be parsed to metrics, check the metric definition",
+				zap.String("command", metric.Command),
+				zap.String("subsystem", metric.Subsystem),
+				zap.Int("rows", len(siebelData)))
+			return len(siebelData), fmt.Errorf("command returned %d rows but none could be parsed to metrics", len(siebelData))
+		}
 	}
 
 	totalTime := time.Since(startTime)
@@ -65,17 +150,31 @@ func scrapeGenericValues(namespace string, dateFormat string, disableEmptyMetric
 		zap.Duration("processingTime", processingTime),
 		zap.Int("metricCount", metricsCount))
 
-	return nil
+	return len(siebelData), nil
 }
 
-func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat string, disableEmptyMetricsOverride bool) ([]map[string]string, error) {
-	siebelData := []map[string]string{}
+// getSiebelData runs the command of a metric and parses its output
+func getSiebelData(smgr *servermanager.ServerManager, metric Metric, dateFormats []string, location *time.Location, disableEmptyMetricsOverride bool, maxDataSize int64) ([]map[string]string, error) {
+	lines, err := fetchCommandOutput(smgr, metric)
+	if err != nil {
+		return nil, err
+	}
+	return parseSiebelData(lines, metric, dateFormats, location, disableEmptyMetricsOverride, maxDataSize)
+}
+
+// fetchCommandOutput runs the command of a metric and returns the raw output lines
+func fetchCommandOutput(smgr *servermanager.ServerManager, metric Metric) ([]string, error) {
+	command := metric.Command
 
 	logger.Debug("Sending command to Siebel Server Manager", zap.String("command", command))
 	startTime := time.Now()
 
-	// Use smgr directly, it's already a pointer
-	lines, err := smgr.SendCommand(command)
+	// A hung command must not stall the whole scrape for longer than its timeout
+	timeout := servermanager.DefaultTimeout
+	if metric.Timeout > 0 {
+		timeout = metric.Timeout
+	}
+	lines, err := smgr.SendCommandWithTimeout(command, timeout)
 
 	commandTime := time.Since(startTime)
 	logger.Debug("Command completed",
@@ -89,10 +188,20 @@ func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat
 			zap.Error(err))
 		return nil, err
 	}
+	return lines, nil
+}
+
+// parseSiebelData parses the srvrmgr output table of a command into rows keyed by
+// column name. Empty cells and date columns are converted with the settings of
+// the metric, so the same output parses differently for different metrics.
+func parseSiebelData(lines []string, metric Metric, dateFormats []string, location *time.Location, disableEmptyMetricsOverride bool, maxDataSize int64) ([]map[string]string, error) {
+	siebelData := []map[string]string{}
+	command := metric.Command
 
-	// Check and parse srvrmgr output...
-	if len(lines) < 3 {
-		logger.Error("Command output too short to be valid",
+	// Check and parse srvrmgr output. A header and a separator row without data
+	// rows is a valid empty result.
+	if len(lines) < 2 || !isSeparatorRow(lines[1]) {
+		logger.Error("Command output is not a valid table",
 			zap.String("command", command),
 			zap.Int("lines", len(lines)))
 		return nil, errors.New("command output is not valid")
@@ -124,9 +233,21 @@ func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat
 			zap.Any("lengths", lengths))
 	}
 
+	// Decide per column whether empty cells are replaced by "0" and which date layouts apply
+	overrideEmpty := make(map[string]bool, len(columnsNames))
+	columnDateFormats := make(map[string][]string, len(columnsNames))
+	for _, colName := range columnsNames {
+		// Empty value cells of PresentMetric columns are kept, so that converting the
+		// row can tell them from a genuine 0
+		keepEmpty := metric.PresentMetric && !slices.Contains(metric.Labels, colName)
+		overrideEmpty[colName] = emptyOverride(colName, metric, disableEmptyMetricsOverride) && !keepEmpty
+		columnDateFormats[colName] = getDateFormats(colName, metric, dateFormats)
+	}
+
 	// Parse data-rows
 	parseStart := time.Now()
 	validRows := 0
+	var dataSize int64
 	logger.Debug("Parsing rows with data", zap.Int("rowCount", len(rawDataRows)))
 
 	for i, rawRow := range rawDataRows {
@@ -141,6 +262,7 @@ func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat
 		}
 
 		parsedRow := make(map[string]string)
+		rowSize := int64(rowOverheadBytes)
 		rowLen := len(rawRow)
 		for colIndex, colName := range columnsNames {
 			if colIndex >= len(lengths) {
@@ -163,16 +285,17 @@ func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat
 			colValue := strings.TrimSpace(rawRow[:colMaxLen])
 
 			// If value is empty then set it to default "0"
-			if len(colValue) == 0 && !disableEmptyMetricsOverride {
+			if len(colValue) == 0 && overrideEmpty[colName] {
 				colValue = "0"
 			}
 
-			// Try to convert date-string to Unix timestamp
-			if len(colValue) == len(dateFormat) {
-				colValue = convertDateStringToTimestamp(colValue, dateFormat)
+			// Convert date-strings of date columns to Unix timestamps
+			if colDateFormats := columnDateFormats[colName]; len(colDateFormats) > 0 {
+				colValue = convertDateStringToTimestamp(colValue, colDateFormats, location)
 			}
 
 			parsedRow[colName] = colValue
+			rowSize += int64(len(colName) + len(colValue) + cellOverheadBytes)
 
 			// Cut off used value from row
 			if rowLen > colMaxLen {
@@ -184,6 +307,18 @@ func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat
 			}
 		}
 
+		// Stop before a huge result exhausts memory
+		dataSize += rowSize
+		if maxDataSize > 0 && dataSize > maxDataSize {
+			logger.Error("Parsed dataset exceeds memory limit, aborting",
+				zap.String("command", command),
+				zap.Int("rowsParsed", validRows),
+				zap.Int("totalRows", len(rawDataRows)),
+				zap.Int64("estimatedBytes", dataSize),
+				zap.Int64("maxBytes", maxDataSize))
+			return nil, fmt.Errorf("%w: more than %d bytes after %d of %d rows", errScrapeMemoryExceeded, maxDataSize, validRows, len(rawDataRows))
+		}
+
 		siebelData = append(siebelData, parsedRow)
 		validRows++
 	}
@@ -193,13 +328,14 @@ func getSiebelData(smgr *servermanager.ServerManager, command string, dateFormat
 		zap.Int("rowsParsed", validRows),
 		zap.Int("totalRows", len(rawDataRows)),
 		zap.Int("skippedRows", len(rawDataRows)-validRows),
+		zap.Int64("estimatedBytes", dataSize),
 		zap.Duration("parseTime", parseTime))
 
 	return siebelData, nil
 }
 
 // Convert a single row to metrics
-func convertRowToMetrics(row map[string]string, namespace string, metric Metric, seenMetrics map[string]bool) ([]prometheus.Metric, error) {
+func convertRowToMetrics(row map[string]string, namespace string, constLabels prometheus.Labels, resets *counterTracker, metric Metric, seenMetrics map[string]bool) ([]prometheus.Metric, error) {
 	metrics := []prometheus.Metric{}
 
 	// Skip processing completely if the required field to append is empty
@@ -221,14 +357,17 @@ func convertRowToMetrics(row map[string]string, namespace string, metric Metric,
 				zap.String("label", label))
 			labelValue = "unknown"
 		}
+		if metric.collapseLabelWhitespace {
+			labelValue = collapseWhitespace(labelValue)
+		}
 
-		labelsNamesCleaned = append(labelsNamesCleaned, cleanName(label))
+		labelsNamesCleaned = append(labelsNamesCleaned, labelName(label, metric.LabelRename))
 		labelsValues = append(labelsValues, labelValue)
 	}
 
 	// Construct Prometheus values
 	for metricName, metricHelp := range metric.Help {
-		metricType := getMetricType(metricName, metric.Type)
+		metricType := getMetricType(metricName, metric.Type, metric.defaultType)
 		metricNameCleaned := cleanName(metricName)
 
 		// Handle field to append for the metric name
@@ -273,7 +412,39 @@ func convertRowToMetrics(row map[string]string, namespace string, metric Metric,
 
 		metricValue := row[metricName]
 
+		// Whether the cell had a value, before any substitution of empty cells
+		present := strings.TrimSpace(metricValue) != ""
+		if metric.PresentMetric && !present && emptyOverride(metricName, metric, metric.disableEmptyMetricsOverride) {
+			metricValue = "0"
+		}
+
+		// Pick the numeric part out of columns that pack several fields, e.g. "Running (12345)"
+		if pattern, exists := metric.ValueExtract[metricName]; exists {
+			metricValue = extractValue(metricValue, pattern)
+		}
+
+		// Info metrics carry their data in labels only and always have the value 1
+		isInfo := strings.EqualFold(metric.Type[metricName], "info")
+		if isInfo {
+			metricValue = "1"
+		}
+
 		// Skip completely empty values (after trimming)
+		if strings.TrimSpace(metricValue) == "" && strings.EqualFold(metric.EmptyValue, "skip") {
+			logger.Debug("Skipping empty value as configured",
+				zap.String("metricName", metricName))
+			continue
+		}
+		if strings.TrimSpace(metricValue) == "" && strings.EqualFold(metric.EmptyValue, "nan") {
+			logger.Debug("Using NaN for empty value as configured",
+				zap.String("metricName", metricName))
+			metricValue = "NaN"
+		}
+		if strings.TrimSpace(metricValue) == "" && strings.EqualFold(metric.EmptyValue, "zero") {
+			logger.Debug("Using 0 for empty value as configured",
+				zap.String("metricName", metricName))
+			metricValue = "0"
+		}
 		if strings.TrimSpace(metricValue) == "" {
 			// For time-related fields, special handling: log at debug level and skip
 			if strings.Contains(strings.ToLower(metricName), "time") ||
@@ -299,8 +470,11 @@ func convertRowToMetrics(row map[string]string, namespace string, metric Metric,
 		}
 
 		// Value mapping
-		if metricMap, exists1 := metric.ValueMap[metricName]; exists1 {
+		rawValue := ""
+		if metricMap, exists1 := metric.ValueMap[metricName]; exists1 && !isInfo {
 			if len(metricMap) > 0 {
+				rawValue = metricValue
+
 				// First log the original value
 				logger.Debug("Processing value mapping",
 					zap.String("metricName", metricName),
@@ -365,30 +539,69 @@ func convertRowToMetrics(row map[string]string, namespace string, metric Metric,
 		// Mark as seen for future checks
 		seenMetrics[metricKey] = true
 
-		promMetricDesc := prometheus.NewDesc(prometheus.BuildFQName(namespace, metric.Subsystem, metricNameCleaned), metricHelp, labelsNamesCleaned, nil)
+		promMetricDesc := prometheus.NewDesc(prometheus.BuildFQName(namespace, metric.Subsystem, metricNameCleaned), metricHelp, labelsNamesCleaned, constLabels)
 
 		if metricType == prometheus.GaugeValue || metricType == prometheus.CounterValue {
 			logger.Debug("Creating gauge/counter metric",
 				zap.String("name", metricNameCleaned),
 				zap.Float64("value", metricValueParsed),
 				zap.Strings("labels", labelsValues))
+			if metricType == prometheus.CounterValue && metric.DetectCounterResets && resets != nil {
+				resets.observe(metricKey, prometheus.BuildFQName(namespace, metric.Subsystem, metricNameCleaned), metricValueParsed)
+			}
 			metrics = append(metrics, prometheus.MustNewConstMetric(promMetricDesc, metricType, metricValueParsed, labelsValues...))
-		} else {
-			// For histograms, verify we have a "count" field
-			countValue, ok := row["count"]
-			if !ok || strings.TrimSpace(countValue) == "" {
-				logger.Error("Missing count field for histogram",
-					zap.String("metricName", metricName))
+			if metric.RawValueMetric && rawValue != "" {
+				metrics = append(metrics, rawValueMetric(namespace, metric.Subsystem, metricNameCleaned, rawValue, labelsNamesCleaned, labelsValues, constLabels))
+			}
+			if metric.PresentMetric && !isInfo {
+				metrics = append(metrics, presentMetric(namespace, metric.Subsystem, metricNameCleaned, present, labelsNamesCleaned, labelsValues, constLabels))
+			}
+		} else if strings.EqualFold(metric.Type[metricName], "summary") {
+			count, ok := getCount(row, metricName, metricHelp)
+			if !ok {
 				continue
 			}
 
-			count, err := strconv.ParseUint(strings.TrimSpace(countValue), 10, 64)
-			if err != nil {
-				logger.Error("Unable to convert count value to int",
-					zap.String("metricName", metricName),
-					zap.String("count", countValue),
-					zap.String("help", metricHelp),
-					zap.Error(err))
+			quantiles := make(map[float64]float64)
+			for field, q := range metric.Quantiles[metricName] {
+				quantile, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+				if err != nil {
+					logger.Error("Unable to convert quantile to float",
+						zap.String("metricName", metricName),
+						zap.String("quantile", q),
+						zap.String("help", metricHelp),
+						zap.Error(err))
+					continue
+				}
+
+				// Get the quantile value, use 0 if empty
+				quantileValue := row[field]
+				if strings.TrimSpace(quantileValue) == "" {
+					quantileValue = "0"
+				}
+
+				value, err := strconv.ParseFloat(strings.TrimSpace(quantileValue), 64)
+				if err != nil {
+					logger.Error("Unable to convert field value to float",
+						zap.String("metricName", metricName),
+						zap.String("field", field),
+						zap.String("value", quantileValue),
+						zap.String("help", metricHelp),
+						zap.Error(err))
+					continue
+				}
+				quantiles[quantile] = value
+			}
+			logger.Debug("Creating summary metric",
+				zap.String("name", metricNameCleaned),
+				zap.Float64("sum", metricValueParsed),
+				zap.Uint64("count", count),
+				zap.Any("quantiles", quantiles))
+			metrics = append(metrics, prometheus.MustNewConstSummary(promMetricDesc, count, metricValueParsed, quantiles, labelsValues...))
+		} else {
+			// For histograms, verify we have a "count" field
+			count, ok := getCount(row, metricName, metricHelp)
+			if !ok {
 				continue
 			}
 			buckets := make(map[float64]uint64)
@@ -430,14 +643,64 @@ func convertRowToMetrics(row map[string]string, namespace string, metric Metric,
 		}
 	}
 
+	// Samples "as of" a Siebel reported time carry that time instead of the scrape time
+	if metric.TimestampField != "" {
+		if timestamp, ok := rowTimestamp(row, metric.TimestampField); ok {
+			for i := range metrics {
+				metrics[i] = prometheus.NewMetricWithTimestamp(timestamp, metrics[i])
+			}
+		}
+	}
+
 	return metrics, nil
 }
 
+// rowTimestamp returns the time held by the timestamp column of a row, which date
+// conversion turned into Unix seconds. Invalid and zero values fall back to scrape time.
+func rowTimestamp(row map[string]string, field string) (time.Time, bool) {
+	value := strings.TrimSpace(row[field])
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		logger.Debug("Invalid timestamp, using scrape time",
+			zap.String("timestampField", field),
+			zap.String("value", value))
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// getCount returns the observation count of a histogram or summary from the "count" column of a row
+func getCount(row map[string]string, metricName string, metricHelp string) (uint64, bool) {
+	countValue, ok := row["count"]
+	if !ok || strings.TrimSpace(countValue) == "" {
+		logger.Error("Missing count field for histogram or summary",
+			zap.String("metricName", metricName))
+		return 0, false
+	}
+
+	count, err := strconv.ParseUint(strings.TrimSpace(countValue), 10, 64)
+	if err != nil {
+		logger.Error("Unable to convert count value to int",
+			zap.String("metricName", metricName),
+			zap.String("count", countValue),
+			zap.String("help", metricHelp),
+			zap.Error(err))
+		return 0, false
+	}
+
+	return count, true
+}
+
 // Parse srvrmgr result and call parsing function to each row
-func generatePrometheusMetrics(data []map[string]string, namespace string, ch *chan<- prometheus.Metric, metric Metric) (int, error) {
+func generatePrometheusMetrics(data []map[string]string, namespace string, constLabels prometheus.Labels, resets *counterTracker, ch *chan<- prometheus.Metric, metric Metric, chunkSize int, forceGC bool) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
 	totalRows := len(data)
 	logger.Debug("Generating Prometheus metrics",
 		zap.Int("totalRows", totalRows),
+		zap.Int("chunkSize", chunkSize),
 		zap.String("subsystem", metric.Subsystem))
 
 	metricsCount := 0
@@ -460,7 +723,7 @@ func generatePrometheusMetrics(data []map[string]string, namespace string, ch *c
 
 		// Process this chunk of data
 		chunkStart := time.Now()
-		chunkCount, err := processDataChunk(currentChunk, namespace, ch, metric, seenMetrics)
+		chunkCount, err := processDataChunk(currentChunk, namespace, constLabels, resets, ch, metric, seenMetrics)
 		chunkTime := time.Since(chunkStart)
 
 		if err != nil {
@@ -479,8 +742,8 @@ func generatePrometheusMetrics(data []map[string]string, namespace string, ch *c
 
 		metricsCount += chunkCount
 
-		// Allow some time for GC to run between chunks if we have a large dataset
-		if totalRows > chunkSize*2 {
+		// Force a GC between chunks of a large dataset if asked to, this trades scrape time for a lower peak heap
+		if forceGC && totalRows > chunkSize*2 {
 			logger.Debug("Running garbage collection between chunks")
 			runtime.GC()
 		}
@@ -493,8 +756,48 @@ func generatePrometheusMetrics(data []map[string]string, namespace string, ch *c
 	return metricsCount, nil
 }
 
+// unusedColumns returns the sorted columns of row that the metric definition does
+// not use as value, label, help, bucket, quantile, name suffix or timestamp
+func unusedColumns(row map[string]string, metric Metric) []string {
+	used := map[string]bool{
+		metric.FieldToAppend:  true,
+		metric.TimestampField: true,
+	}
+	for column := range metric.Help {
+		used[column] = true
+	}
+	for _, column := range metric.HelpField {
+		used[column] = true
+	}
+	for _, column := range metric.Labels {
+		used[column] = true
+	}
+	for _, buckets := range metric.Buckets {
+		for column := range buckets {
+			used[column] = true
+		}
+	}
+	for _, quantiles := range metric.Quantiles {
+		for column := range quantiles {
+			used[column] = true
+		}
+	}
+	if len(metric.Buckets) > 0 || len(metric.Quantiles) > 0 {
+		used["count"] = true
+	}
+
+	var columns []string
+	for column := range row {
+		if !used[column] {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
 // Process a chunk of data rows
-func processDataChunk(chunk []map[string]string, namespace string, ch *chan<- prometheus.Metric, metric Metric, seenMetrics map[string]bool) (int, error) {
+func processDataChunk(chunk []map[string]string, namespace string, constLabels prometheus.Labels, resets *counterTracker, ch *chan<- prometheus.Metric, metric Metric, seenMetrics map[string]bool) (int, error) {
 	chunkMetricsCount := 0
 
 	for rowIndex, row := range chunk {
@@ -507,7 +810,7 @@ func processDataChunk(chunk []map[string]string, namespace string, ch *chan<- pr
 
 		// Process each row and convert to metrics
 		rowStart := time.Now()
-		rowMetrics, err := convertRowToMetrics(row, namespace, metric, seenMetrics)
+		rowMetrics, err := convertRowToMetrics(row, namespace, constLabels, resets, metric, seenMetrics)
 
 		if err != nil {
 			logger.Error("Error converting row to metrics",
@@ -536,21 +839,66 @@ func processDataChunk(chunk []map[string]string, namespace string, ch *chan<- pr
 	return chunkMetricsCount, nil
 }
 
+// extractValueRegexps caches compiled ValueExtract patterns
+var extractValueRegexps sync.Map
+
+// extractValue returns the first capture group of pattern in value. It returns an
+// empty string if the pattern does not match, so the empty value handling applies.
+func extractValue(value string, pattern string) string {
+	var re *regexp.Regexp
+	if cached, ok := extractValueRegexps.Load(pattern); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Error("Invalid value extract pattern",
+				zap.String("pattern", pattern),
+				zap.Error(err))
+			return ""
+		}
+		extractValueRegexps.Store(pattern, compiled)
+		re = compiled
+	}
+
+	match := re.FindStringSubmatch(value)
+	if len(match) < 2 {
+		logger.Debug("Value extract pattern did not match",
+			zap.String("pattern", pattern),
+			zap.String("value", value))
+		return ""
+	}
+	return match[1]
+}
+
+// labelName returns the output label name of a label column, renamed if configured
+func labelName(column string, labelRename map[string]string) string {
+	if renamed, exists := labelRename[column]; exists && renamed != "" {
+		return cleanName(renamed)
+	}
+	return cleanName(column)
+}
+
 // createMetricKey creates a unique key for a metric based on its name and labels
 func createMetricKey(namespace, subsystem, name string, labelValues []string) string {
 	fqName := prometheus.BuildFQName(namespace, subsystem, name)
 	return fmt.Sprintf("%s{%s}", fqName, strings.Join(labelValues, ","))
 }
 
-func getMetricType(metricName string, metricsTypes map[string]string) prometheus.ValueType {
+func getMetricType(metricName string, metricsTypes map[string]string, defaultType prometheus.ValueType) prometheus.ValueType {
+	if defaultType == 0 {
+		defaultType = prometheus.GaugeValue
+	}
+
 	var strToPromType = map[string]prometheus.ValueType{
 		"gauge":     prometheus.GaugeValue,
 		"counter":   prometheus.CounterValue,
 		"histogram": prometheus.UntypedValue,
+		"summary":   prometheus.UntypedValue,
+		"info":      prometheus.GaugeValue,
 	}
 	strType, exists := metricsTypes[metricName]
 	if !exists {
-		return prometheus.GaugeValue
+		return defaultType
 	}
 	strType = strings.ToLower(strType)
 	valueType, exists := strToPromType[strType]
@@ -561,10 +909,22 @@ func getMetricType(metricName string, metricsTypes map[string]string) prometheus
 	return valueType
 }
 
+// collapseWhitespace replaces runs of whitespace by a single space, e.g. in
+// component descriptions containing double spaces
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 func trimHeadRow(s string) string {
 	return regexp.MustCompile(`\s+`).ReplaceAllString(strings.Trim(s, " \n	"), " ")
 }
 
+// isSeparatorRow reports whether s is the row of dashes below the column headers
+func isSeparatorRow(s string) bool {
+	row := strings.TrimSpace(s)
+	return row != "" && strings.Trim(row, "- \t") == ""
+}
+
 func getSpacerLength(s string) int {
 	result := 0
 	logger.Debug("Determining spacer length", zap.String("input", s))
@@ -578,15 +938,50 @@ func getSpacerLength(s string) int {
 	return result
 }
 
-func convertDateStringToTimestamp(s string, dateFormat string) string {
+// emptyOverride reports whether empty cells of a column are replaced by "0". The
+// per-metric settings for label and value columns take precedence over the global one.
+func emptyOverride(colName string, metric Metric, disableEmptyMetricsOverride bool) bool {
+	if slices.Contains(metric.Labels, colName) {
+		if metric.EmptyLabelOverride != nil {
+			return *metric.EmptyLabelOverride
+		}
+	} else if metric.EmptyValue != "" {
+		return false
+	} else if metric.EmptyValueOverride != nil {
+		return *metric.EmptyValueOverride
+	}
+	return !disableEmptyMetricsOverride
+}
+
+// getDateFormats returns the date layouts to try for a column, or nil if the column
+// is not a date column. Only columns listed in DateFields or FieldDateFormat are
+// dates. The per-field format of the metric is used first, then the metric-wide
+// format, then the global defaults.
+func getDateFormats(colName string, metric Metric, defaultDateFormats []string) []string {
+	if fieldDateFormat, exists := metric.FieldDateFormat[colName]; exists && fieldDateFormat != "" {
+		return []string{fieldDateFormat}
+	}
+	if !slices.Contains(metric.DateFields, colName) {
+		return nil
+	}
+	if metric.DateFormat != "" {
+		return []string{metric.DateFormat}
+	}
+	return defaultDateFormats
+}
+
+// convertDateStringToTimestamp converts s to a Unix timestamp using the first layout
+// that parses it. s is returned unchanged if none does.
+func convertDateStringToTimestamp(s string, dateFormats []string, location *time.Location) string {
 	if s == "0000-00-00 00:00:00" {
 		return "0"
 	}
-	t, err := time.Parse(dateFormat, s)
-	if err != nil {
-		return s
+	for _, dateFormat := range dateFormats {
+		if t, err := time.ParseInLocation(dateFormat, s, location); err == nil {
+			return fmt.Sprint(t.Unix())
+		}
 	}
-	return fmt.Sprint(t.Unix())
+	return s
 }
 
 // If Siebel gives us some ugly names back, this function cleans it up for Prometheus.
@@ -598,3 +993,25 @@ func cleanName(s string) string {
 	s = strings.ToLower(s)                                          // Switch case to lower
 	return s
 }
+
+// rawValueMetric creates the <name>_raw info metric carrying the original string
+// of a value-mapped column in its value label
+func rawValueMetric(namespace, subsystem, name, rawValue string, labelNames, labelValues []string, constLabels prometheus.Labels) prometheus.Metric {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name+"_raw"),
+		"Original value of "+name+" before value mapping.",
+		append(slices.Clone(labelNames), "value"), constLabels)
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, append(slices.Clone(labelValues), rawValue)...)
+}
+
+// presentMetric creates the <name>_present gauge telling whether the value of a
+// column was read from the cell (1) or substituted for an empty cell (0)
+func presentMetric(namespace, subsystem, name string, present bool, labelNames, labelValues []string, constLabels prometheus.Labels) prometheus.Metric {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name+"_present"),
+		"Whether "+name+" had a value (1) or was empty and substituted (0).",
+		labelNames, constLabels)
+	value := 0.0
+	if present {
+		value = 1
+	}
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+}
@@ -0,0 +1,764 @@
+package exporter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/BurntSushi/toml"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+const testDateFormat = "2006-01-02 15:04:05"
+
+// mustLoadLocation loads a time zone or fails the test
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("loading time zone %s: %v", name, err)
+	}
+	return location
+}
+
+// unixString returns the Unix timestamp of a UTC datetime as converted values are
+func unixString(t *testing.T, utc string) string {
+	t.Helper()
+	parsed, err := time.Parse(testDateFormat, utc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strconv.FormatInt(parsed.Unix(), 10)
+}
+
+func TestConvertDateStringToTimestampAcrossDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name     string
+		value    string
+		location *time.Location
+		wantUTC  string
+	}{
+		{name: "UTC", value: "2024-03-31 01:30:00", location: time.UTC, wantUTC: "2024-03-31 01:30:00"},
+		{name: "Berlin before spring forward", value: "2024-03-31 01:30:00", location: berlin, wantUTC: "2024-03-31 00:30:00"},
+		{name: "Berlin after spring forward", value: "2024-03-31 03:30:00", location: berlin, wantUTC: "2024-03-31 01:30:00"},
+		{name: "Berlin summer time", value: "2024-07-01 12:00:00", location: berlin, wantUTC: "2024-07-01 10:00:00"},
+		{name: "Berlin before fall back", value: "2024-10-27 01:30:00", location: berlin, wantUTC: "2024-10-26 23:30:00"},
+		{name: "Berlin after fall back", value: "2024-10-27 03:30:00", location: berlin, wantUTC: "2024-10-27 02:30:00"},
+		{name: "New York before spring forward", value: "2024-03-10 01:30:00", location: newYork, wantUTC: "2024-03-10 06:30:00"},
+		{name: "New York after spring forward", value: "2024-03-10 03:30:00", location: newYork, wantUTC: "2024-03-10 07:30:00"},
+		{name: "New York after fall back", value: "2024-11-03 02:30:00", location: newYork, wantUTC: "2024-11-03 07:30:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertDateStringToTimestamp(tt.value, []string{testDateFormat}, tt.location)
+			if want := unixString(t, tt.wantUTC); got != want {
+				t.Errorf("convertDateStringToTimestamp(%q) in %s = %s, want %s (%s UTC)",
+					tt.value, tt.location, got, want, tt.wantUTC)
+			}
+		})
+	}
+}
+
+func TestConvertDateStringToTimestampHourSpanningDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	// Wall clock 01:00 to 04:00 on the spring forward day is only two real hours
+	start, _ := strconv.ParseInt(convertDateStringToTimestamp("2024-03-31 01:00:00", []string{testDateFormat}, berlin), 10, 64)
+	end, _ := strconv.ParseInt(convertDateStringToTimestamp("2024-03-31 04:00:00", []string{testDateFormat}, berlin), 10, 64)
+	if got := time.Duration(end-start) * time.Second; got != 2*time.Hour {
+		t.Errorf("elapsed time across spring forward = %s, want 2h", got)
+	}
+}
+
+// componentRows returns n rows of a "list comp" result with a unique alias each
+func componentRows(n int) []map[string]string {
+	rows := make([]map[string]string, n)
+	for i := range rows {
+		rows[i] = map[string]string{
+			"CC_ALIAS":         "Comp" + strconv.Itoa(i),
+			"CP_NUM_RUN_TASKS": strconv.Itoa(i % 100),
+			"CP_MAX_TASKS":     "100",
+		}
+	}
+	return rows
+}
+
+var componentMetric = Metric{
+	Command:   "list comp show CC_ALIAS, CP_NUM_RUN_TASKS, CP_MAX_TASKS",
+	Subsystem: "list_comp",
+	Labels:    []string{"CC_ALIAS"},
+	Help: map[string]string{
+		"CP_NUM_RUN_TASKS": "Number of running tasks.",
+		"CP_MAX_TASKS":     "Maximum number of tasks.",
+	},
+}
+
+// generateMetrics runs generatePrometheusMetrics, discarding the metrics
+func generateMetrics(rows []map[string]string, chunkSize int, forceGC bool) (int, error) {
+	metrics := make(chan prometheus.Metric, 1024)
+	done := make(chan struct{})
+	go func() {
+		for range metrics {
+		}
+		close(done)
+	}()
+
+	var ch chan<- prometheus.Metric = metrics
+	count, err := generatePrometheusMetrics(rows, "siebel", nil, nil, &ch, componentMetric, chunkSize, forceGC)
+	close(metrics)
+	<-done
+	return count, err
+}
+
+func TestGeneratePrometheusMetricsChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      int
+		chunkSize int
+		forceGC   bool
+	}{
+		{name: "single chunk", rows: 10, chunkSize: 1000},
+		{name: "one row per chunk", rows: 10, chunkSize: 1},
+		{name: "partial last chunk", rows: 10, chunkSize: 3},
+		{name: "default chunk size", rows: 10, chunkSize: 0},
+		{name: "GC between chunks", rows: 10, chunkSize: 3, forceGC: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := generateMetrics(componentRows(tt.rows), tt.chunkSize, tt.forceGC)
+			if err != nil {
+				t.Fatalf("generatePrometheusMetrics() error = %v", err)
+			}
+			if want := tt.rows * 2; count != want {
+				t.Errorf("generatePrometheusMetrics() = %d metrics, want %d", count, want)
+			}
+		})
+	}
+}
+
+func BenchmarkGeneratePrometheusMetrics50kRows(b *testing.B) {
+	rows := componentRows(50000)
+
+	for _, bm := range []struct {
+		name    string
+		forceGC bool
+	}{
+		{name: "without GC", forceGC: false},
+		{name: "with GC between chunks", forceGC: true},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := generateMetrics(rows, defaultChunkSize, bm.forceGC); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// decodeMetric decodes a metrics file holding a single metric definition
+func decodeMetric(t *testing.T, definition string) Metric {
+	t.Helper()
+	var metrics Metrics
+	if _, err := toml.Decode(definition, &metrics); err != nil {
+		t.Fatalf("decoding metric definition: %v", err)
+	}
+	if len(metrics.Metric) != 1 {
+		t.Fatalf("got %d metric definitions, want 1", len(metrics.Metric))
+	}
+	return metrics.Metric[0]
+}
+
+// convertRow converts a row and returns the written metrics by name
+func convertRow(t *testing.T, row map[string]string, metric Metric) map[string][]*dto.Metric {
+	t.Helper()
+	metrics, err := convertRowToMetrics(row, "siebel", nil, nil, metric, make(map[string]bool))
+	if err != nil {
+		t.Fatalf("convertRowToMetrics() error = %v", err)
+	}
+
+	byName := make(map[string][]*dto.Metric)
+	for _, m := range metrics {
+		written := &dto.Metric{}
+		if err := m.Write(written); err != nil {
+			t.Fatalf("writing metric %s: %v", m.Desc(), err)
+		}
+		name := descNamePattern.FindStringSubmatch(m.Desc().String())[1]
+		byName[name] = append(byName[name], written)
+	}
+	return byName
+}
+
+// labelMap returns the labels of a written metric
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range m.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+const summaryMetric = `
+[[Metric]]
+Command = "list statistics for component MyComp show STAT_ALIAS, SUM, count, P50, P90, P99"
+Subsystem = "mycomp_response"
+Labels = [ "STAT_ALIAS" ]
+[Metric.Help]
+SUM = "Response time of MyComp requests in seconds."
+[Metric.Type]
+SUM = "summary"
+[Metric.Quantiles.SUM]
+P50 = "0.5"
+P90 = "0.9"
+P99 = "0.99"
+`
+
+func TestSummaryMetric(t *testing.T) {
+	metric := decodeMetric(t, summaryMetric)
+	if problems := validateMetricDesc(metric); len(problems) > 0 {
+		t.Fatalf("validateMetricDesc() = %v, want no problems", problems)
+	}
+
+	tests := []struct {
+		name          string
+		row           map[string]string
+		wantCount     uint64
+		wantSum       float64
+		wantQuantiles map[float64]float64
+		wantSkipped   bool
+	}{
+		{
+			name:          "all quantiles",
+			row:           map[string]string{"STAT_ALIAS": "Requests", "SUM": "12.5", "count": "10", "P50": "0.8", "P90": "2.1", "P99": "4"},
+			wantCount:     10,
+			wantSum:       12.5,
+			wantQuantiles: map[float64]float64{0.5: 0.8, 0.9: 2.1, 0.99: 4},
+		},
+		{
+			name:          "empty quantile is 0",
+			row:           map[string]string{"STAT_ALIAS": "Requests", "SUM": "1", "count": "1", "P50": "1", "P90": "1", "P99": ""},
+			wantCount:     1,
+			wantSum:       1,
+			wantQuantiles: map[float64]float64{0.5: 1, 0.9: 1, 0.99: 0},
+		},
+		{
+			name:        "missing count",
+			row:         map[string]string{"STAT_ALIAS": "Requests", "SUM": "1", "P50": "1", "P90": "1", "P99": "1"},
+			wantSkipped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaries := convertRow(t, tt.row, metric)["siebel_mycomp_response_sum"]
+			if tt.wantSkipped {
+				if len(summaries) != 0 {
+					t.Fatalf("got %d summaries, want none", len(summaries))
+				}
+				return
+			}
+			if len(summaries) != 1 || summaries[0].Summary == nil {
+				t.Fatalf("got %v, want one summary", summaries)
+			}
+
+			summary := summaries[0].GetSummary()
+			if summary.GetSampleCount() != tt.wantCount || summary.GetSampleSum() != tt.wantSum {
+				t.Errorf("count, sum = %d, %v, want %d, %v", summary.GetSampleCount(), summary.GetSampleSum(), tt.wantCount, tt.wantSum)
+			}
+			quantiles := make(map[float64]float64)
+			for _, q := range summary.GetQuantile() {
+				quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			if len(quantiles) != len(tt.wantQuantiles) {
+				t.Errorf("quantiles = %v, want %v", quantiles, tt.wantQuantiles)
+			}
+			for quantile, want := range tt.wantQuantiles {
+				if got, ok := quantiles[quantile]; !ok || got != want {
+					t.Errorf("quantile %v = %v, want %v", quantile, got, want)
+				}
+			}
+			if got := labelMap(summaries[0])["stat_alias"]; got != "Requests" {
+				t.Errorf("stat_alias label = %q, want Requests", got)
+			}
+		})
+	}
+}
+
+// commandOutput returns a srvrmgr result table as SendCommand returns it, without
+// the leading blank line and the "rows returned" line
+func commandOutput(columns []string, rows ...[]string) []string {
+	table := srvrmgrtest.Table(columns, rows...)
+	return table[1 : len(table)-1]
+}
+
+// parseRows parses command output with the default settings
+func parseRows(t *testing.T, lines []string, metric Metric) []map[string]string {
+	t.Helper()
+	rows, err := parseSiebelData(lines, metric, []string{testDateFormat}, time.UTC, false, 0)
+	if err != nil {
+		t.Fatalf("parseSiebelData() error = %v", err)
+	}
+	return rows
+}
+
+const componentInfoMetric = `
+[[Metric]]
+Command = "list comp show CC_ALIAS, CG_ALIAS, CC_RUNMODE"
+Subsystem = "component"
+Labels = ["CC_ALIAS", "CG_ALIAS", "CC_RUNMODE"]
+[Metric.Help]
+INFO = "Siebel component definition."
+[Metric.Type]
+INFO = "info"
+`
+
+func TestInfoMetricFromComponents(t *testing.T) {
+	metric := decodeMetric(t, componentInfoMetric)
+	if problems := validateMetricDesc(metric); len(problems) > 0 {
+		t.Fatalf("validateMetricDesc() = %v, want no problems", problems)
+	}
+
+	output := commandOutput([]string{"CC_ALIAS", "CG_ALIAS", "CC_RUNMODE"},
+		[]string{"EAIObjMgr_enu", "EAI", "Interactive"},
+		[]string{"WorkMon", "Workflow", "Batch"},
+		[]string{"SRBroker", "System", "Interactive"},
+	)
+
+	tests := []struct {
+		name       string
+		wantLabels map[string]string
+	}{
+		{name: "object manager", wantLabels: map[string]string{"cc_alias": "EAIObjMgr_enu", "cg_alias": "EAI", "cc_runmode": "Interactive"}},
+		{name: "batch component", wantLabels: map[string]string{"cc_alias": "WorkMon", "cg_alias": "Workflow", "cc_runmode": "Batch"}},
+		{name: "system component", wantLabels: map[string]string{"cc_alias": "SRBroker", "cg_alias": "System", "cc_runmode": "Interactive"}},
+	}
+
+	var infos []*dto.Metric
+	for _, row := range parseRows(t, output, metric) {
+		infos = append(infos, convertRow(t, row, metric)["siebel_component_info"]...)
+	}
+	if len(infos) != len(tests) {
+		t.Fatalf("got %d siebel_component_info series, want %d", len(infos), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := labelMap(infos[i])
+			for name, want := range tt.wantLabels {
+				if labels[name] != want {
+					t.Errorf("label %s = %q, want %q", name, labels[name], want)
+				}
+			}
+			if got := infos[i].GetGauge().GetValue(); got != 1 {
+				t.Errorf("value = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestLabelRename(t *testing.T) {
+	row := map[string]string{"CC_ALIAS": "EAIObjMgr_enu", "CG_ALIAS": "EAI", "CP_NUM_RUN_TASKS": "3"}
+
+	tests := []struct {
+		name        string
+		labelRename map[string]string
+		wantLabels  map[string]string
+	}{
+		{
+			name:       "no rename",
+			wantLabels: map[string]string{"cc_alias": "EAIObjMgr_enu", "cg_alias": "EAI"},
+		},
+		{
+			name:        "one label renamed",
+			labelRename: map[string]string{"CC_ALIAS": "component"},
+			wantLabels:  map[string]string{"component": "EAIObjMgr_enu", "cg_alias": "EAI"},
+		},
+		{
+			name:        "all labels renamed",
+			labelRename: map[string]string{"CC_ALIAS": "component", "CG_ALIAS": "group"},
+			wantLabels:  map[string]string{"component": "EAIObjMgr_enu", "group": "EAI"},
+		},
+		{
+			name:        "renamed label is cleaned",
+			labelRename: map[string]string{"CC_ALIAS": "Component Alias"},
+			wantLabels:  map[string]string{"component_alias": "EAIObjMgr_enu", "cg_alias": "EAI"},
+		},
+		{
+			name:        "empty rename keeps the column name",
+			labelRename: map[string]string{"CC_ALIAS": ""},
+			wantLabels:  map[string]string{"cc_alias": "EAIObjMgr_enu", "cg_alias": "EAI"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{
+				Command:     "list comp show CC_ALIAS, CG_ALIAS, CP_NUM_RUN_TASKS",
+				Subsystem:   "list_comp",
+				Labels:      []string{"CC_ALIAS", "CG_ALIAS"},
+				LabelRename: tt.labelRename,
+				Help:        map[string]string{"CP_NUM_RUN_TASKS": "Number of running tasks."},
+			}
+
+			series := convertRow(t, row, metric)["siebel_list_comp_cp_num_run_tasks"]
+			if len(series) != 1 {
+				t.Fatalf("got %d series, want 1", len(series))
+			}
+			labels := labelMap(series[0])
+			if len(labels) != len(tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", labels, tt.wantLabels)
+			}
+			for name, want := range tt.wantLabels {
+				if labels[name] != want {
+					t.Errorf("label %s = %q, want %q", name, labels[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValueExtract(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		pattern   string
+		want      float64
+		wantEmpty bool
+	}{
+		{name: "match", value: "Running (12345)", pattern: `\((\d+)\)`, want: 12345},
+		{name: "match with decimals", value: "Load 0.75 avg", pattern: `Load ([0-9.]+)`, want: 0.75},
+		{name: "no match falls back to 0", value: "Shutdown", pattern: `\((\d+)\)`, want: 0},
+		{name: "no match skipped with EmptyValue skip", value: "Shutdown", pattern: `\((\d+)\)`, wantEmpty: true},
+		{name: "invalid regex falls back to 0", value: "Running (12345)", pattern: `\((\d+`, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{
+				Command:      "list procs show CC_ALIAS, TK_STATUS",
+				Subsystem:    "list_procs",
+				Labels:       []string{"CC_ALIAS"},
+				Help:         map[string]string{"TK_STATUS": "Process ID of the component."},
+				ValueExtract: map[string]string{"TK_STATUS": tt.pattern},
+			}
+			if tt.wantEmpty {
+				metric.EmptyValue = "skip"
+			}
+
+			row := map[string]string{"CC_ALIAS": "EAIObjMgr_enu", "TK_STATUS": tt.value}
+			series := convertRow(t, row, metric)["siebel_list_procs_tk_status"]
+			if tt.wantEmpty {
+				if len(series) != 0 {
+					t.Errorf("got %d series, want none", len(series))
+				}
+				return
+			}
+			if len(series) != 1 {
+				t.Fatalf("got %d series, want 1", len(series))
+			}
+			if got := series[0].GetGauge().GetValue(); got != tt.want {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSiebelDataDateColumns(t *testing.T) {
+	// 19 characters, as long as the default date layout
+	const numeric = "1234567890123456789"
+
+	tests := []struct {
+		name        string
+		value       string
+		dateFields  []string
+		dateFormat  string
+		fieldFormat map[string]string
+		dateFormats []string
+		want        string
+	}{
+		{name: "number as long as the layout", value: numeric, want: numeric},
+		{name: "number as long as the layout in a date column", value: numeric, dateFields: []string{"VALUE"}, want: numeric},
+		{name: "datetime in a column that is not a date", value: "2024-03-31 01:30:00", want: "2024-03-31 01:30:00"},
+		{name: "datetime in a date column", value: "2024-03-31 01:30:00", dateFields: []string{"VALUE"}, want: "1711848600"},
+		{name: "zero datetime", value: "0000-00-00 00:00:00", dateFields: []string{"VALUE"}, want: "0"},
+		{
+			name:        "second of several layouts",
+			value:       "31.03.2024 01:30",
+			dateFields:  []string{"VALUE"},
+			dateFormats: []string{testDateFormat, "02.01.2006 15:04"},
+			want:        "1711848600",
+		},
+		{
+			name:       "metric date format replaces the global layouts",
+			value:      "2024-03-31 01:30:00",
+			dateFields: []string{"VALUE"},
+			dateFormat: "02.01.2006 15:04",
+			want:       "2024-03-31 01:30:00",
+		},
+		{
+			name:        "field date format marks the column as date",
+			value:       "03/31/2024 01:30:00",
+			fieldFormat: map[string]string{"VALUE": "01/02/2006 15:04:05"},
+			want:        "1711848600",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{
+				Command:         "list values show NAME, VALUE",
+				Subsystem:       "values",
+				Labels:          []string{"NAME"},
+				DateFields:      tt.dateFields,
+				DateFormat:      tt.dateFormat,
+				FieldDateFormat: tt.fieldFormat,
+			}
+			dateFormats := tt.dateFormats
+			if dateFormats == nil {
+				dateFormats = []string{testDateFormat}
+			}
+
+			output := commandOutput([]string{"NAME", "VALUE"}, []string{"Row", tt.value})
+			rows, err := parseSiebelData(output, metric, dateFormats, time.UTC, false, 0)
+			if err != nil {
+				t.Fatalf("parseSiebelData() error = %v", err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			if got := rows[0]["VALUE"]; got != tt.want {
+				t.Errorf("VALUE = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertDateStringToTimestampInTwoZones(t *testing.T) {
+	const value = "2024-07-01 12:00:00"
+
+	tests := []struct {
+		name       string
+		zone1      string
+		zone2      string
+		wantOffset time.Duration
+	}{
+		{name: "UTC and Berlin summer time", zone1: "UTC", zone2: "Europe/Berlin", wantOffset: 2 * time.Hour},
+		{name: "UTC and New York", zone1: "UTC", zone2: "America/New_York", wantOffset: -4 * time.Hour},
+		{name: "Tokyo and Kolkata", zone1: "Asia/Tokyo", zone2: "Asia/Kolkata", wantOffset: -3*time.Hour - 30*time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := convertDateStringToTimestamp(value, []string{testDateFormat}, mustLoadLocation(t, tt.zone1))
+			second := convertDateStringToTimestamp(value, []string{testDateFormat}, mustLoadLocation(t, tt.zone2))
+			if first == second {
+				t.Fatalf("%q is %s in both %s and %s", value, first, tt.zone1, tt.zone2)
+			}
+
+			firstUnix, _ := strconv.ParseInt(first, 10, 64)
+			secondUnix, _ := strconv.ParseInt(second, 10, 64)
+			if got := time.Duration(firstUnix-secondUnix) * time.Second; got != tt.wantOffset {
+				t.Errorf("%s minus %s = %s, want %s", tt.zone1, tt.zone2, got, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestEmptyValueModes(t *testing.T) {
+	tests := []struct {
+		name        string
+		emptyValue  string
+		column      string // empty column, CP_NUM_RUN_TASKS if not set
+		wantSkipped bool
+		wantNaN     bool
+	}{
+		{name: "default is zero"},
+		{name: "zero", emptyValue: "zero"},
+		{name: "skip", emptyValue: "skip", wantSkipped: true},
+		{name: "nan", emptyValue: "nan", wantNaN: true},
+		{name: "mode is case insensitive", emptyValue: "NaN", wantNaN: true},
+		{name: "zero for a time column", emptyValue: "zero", column: "CP_END_TIME"},
+		{name: "skip for a time column", emptyValue: "skip", column: "CP_END_TIME", wantSkipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			column := tt.column
+			if column == "" {
+				column = "CP_NUM_RUN_TASKS"
+			}
+			metric := Metric{
+				Command:    "list comp show CC_ALIAS, " + column + ", CP_MAX_TASKS",
+				Subsystem:  "list_comp",
+				Labels:     []string{"CC_ALIAS"},
+				Help:       map[string]string{column: "Value of " + column + ".", "CP_MAX_TASKS": "Maximum number of tasks."},
+				EmptyValue: tt.emptyValue,
+			}
+
+			output := commandOutput([]string{"CC_ALIAS", column, "CP_MAX_TASKS"},
+				[]string{"EAIObjMgr_enu", "", "100"})
+			rows := parseRows(t, output, metric)
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			metrics := convertRow(t, rows[0], metric)
+
+			// The filled column is exported in every mode
+			if got := metrics["siebel_list_comp_cp_max_tasks"]; len(got) != 1 || got[0].GetGauge().GetValue() != 100 {
+				t.Errorf("cp_max_tasks = %v, want 100", got)
+			}
+
+			series := metrics["siebel_list_comp_"+strings.ToLower(column)]
+			if tt.wantSkipped {
+				if len(series) != 0 {
+					t.Errorf("got %d series for the empty cell, want none", len(series))
+				}
+				return
+			}
+			if len(series) != 1 {
+				t.Fatalf("got %d series for the empty cell, want 1", len(series))
+			}
+			got := series[0].GetGauge().GetValue()
+			if tt.wantNaN {
+				if !math.IsNaN(got) {
+					t.Errorf("value = %v, want NaN", got)
+				}
+			} else if got != 0 {
+				t.Errorf("value = %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestGetSiebelDataKeepsIdenticalRows(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]string
+	}{
+		{name: "two identical rows", rows: [][]string{{"SRV01", "5"}, {"SRV01", "5"}}},
+		{name: "identical rows apart", rows: [][]string{{"SRV01", "5"}, {"SRV02", "3"}, {"SRV01", "5"}}},
+	}
+
+	fake := srvrmgrtest.New(t)
+	sm := connectTestServerManager(t, testServerManagerConfig(fake))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{Command: "list tasks show SV_NAME, TK_COUNT", Subsystem: "tasks"}
+			fake.Respond(metric.Command, srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table([]string{"SV_NAME", "TK_COUNT"}, tt.rows...),
+			})
+
+			rows, err := getSiebelData(sm, metric, []string{testDateFormat}, time.UTC, false, 0)
+			if err != nil {
+				t.Fatalf("getSiebelData() error = %v", err)
+			}
+			if len(rows) != len(tt.rows) {
+				t.Fatalf("got %d rows, want %d: %v", len(rows), len(tt.rows), rows)
+			}
+			for i, want := range tt.rows {
+				if rows[i]["SV_NAME"] != want[0] || rows[i]["TK_COUNT"] != want[1] {
+					t.Errorf("row %d = %v, want %v", i, rows[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSiebelDataEmptyResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		wantErr bool
+	}{
+		{name: "header and separator without rows", lines: commandOutput([]string{"CC_ALIAS", "TK_COUNT"})},
+		{name: "header and separator only", lines: []string{"CC_ALIAS  TK_COUNT", "--------  --------"}},
+		{name: "no output", lines: nil, wantErr: true},
+		{name: "header without separator", lines: []string{"CC_ALIAS  TK_COUNT", ""}, wantErr: true},
+		{name: "error message", lines: []string{"SBL-ADM-02071: The specified component was not found.", ""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{Command: "list tasks show CC_ALIAS, TK_COUNT", Subsystem: "tasks"}
+			rows, err := parseSiebelData(tt.lines, metric, []string{testDateFormat}, time.UTC, false, 0)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSiebelData() = %v, want error", rows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSiebelData() error = %v", err)
+			}
+			if len(rows) != 0 {
+				t.Errorf("got %d rows, want none", len(rows))
+			}
+		})
+	}
+}
+
+func TestGetSiebelDataAfterTimeout(t *testing.T) {
+	tests := []struct {
+		name                string
+		timeoutResyncWait   time.Duration
+		resyncBeforeCommand bool
+	}{
+		{name: "resync after timeout", timeoutResyncWait: 2 * time.Second},
+		{name: "resync before next command", resyncBeforeCommand: true},
+		{name: "skip prompts while reading"},
+	}
+
+	columns := []string{"SV_NAME", "TK_COUNT"}
+	slow := Metric{Command: "list slow tasks show SV_NAME, TK_COUNT", Subsystem: "tasks", Timeout: 2 * time.Second}
+	fast := Metric{Command: "list tasks show SV_NAME, TK_COUNT", Subsystem: "tasks"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond(slow.Command, srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table(columns, []string{"LATE01", "9"}, []string{"LATE02", "8"}),
+				Delay: 3 * time.Second,
+			})
+			fake.Respond(fast.Command, srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table(columns, []string{"SRV01", "5"}, []string{"SRV02", "3"}),
+			})
+
+			config := testServerManagerConfig(fake)
+			config.TimeoutResyncWait = tt.timeoutResyncWait
+			config.ResyncBeforeCommand = tt.resyncBeforeCommand
+			sm := connectTestServerManager(t, config)
+
+			if _, err := getSiebelData(sm, slow, []string{testDateFormat}, time.UTC, false, 0); err == nil {
+				t.Fatal("slow command did not time out")
+			}
+
+			rows, err := getSiebelData(sm, fast, []string{testDateFormat}, time.UTC, false, 0)
+			if err != nil {
+				t.Fatalf("getSiebelData() error = %v", err)
+			}
+			want := []map[string]string{
+				{"SV_NAME": "SRV01", "TK_COUNT": "5"},
+				{"SV_NAME": "SRV02", "TK_COUNT": "3"},
+			}
+			if len(rows) != len(want) {
+				t.Fatalf("got %d rows, want %d: %v", len(rows), len(want), rows)
+			}
+			for i := range want {
+				if rows[i]["SV_NAME"] != want[i]["SV_NAME"] || rows[i]["TK_COUNT"] != want[i]["TK_COUNT"] {
+					t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// PushConfig defines where and how the metrics are pushed to a Pushgateway
+type PushConfig struct {
+	URL        string
+	Job        string
+	Grouping   map[string]string // Grouping labels besides the job, e.g. instance
+	Retries    int               // Additional attempts after a failed push
+	RetryDelay time.Duration     // Wait between attempts
+}
+
+// PushSink pushes the metrics to a Pushgateway, for hosts that cannot be
+// scraped inbound. Every push replaces the metrics of its grouping key.
+type PushSink struct {
+	config PushConfig
+	errors prometheus.Counter
+}
+
+// NewPushSink creates a sink pushing to the Pushgateway of config. The sink is a
+// collector of its own error counter and should be registered with the gatherer.
+func NewPushSink(config PushConfig, namespace string) (*PushSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("missing Pushgateway URL")
+	}
+	if config.Job == "" {
+		return nil, fmt.Errorf("missing Pushgateway job")
+	}
+	if config.Retries < 0 {
+		return nil, fmt.Errorf("push retries must not be negative, got %d", config.Retries)
+	}
+	sink := &PushSink{
+		config: config,
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "push_errors_total",
+			Help:      "Total number of failed pushes to the Pushgateway, counting every attempt.",
+		}),
+	}
+	// Invalid grouping labels would fail every push
+	if err := sink.pusher(nil).Error(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Name implements Sink
+func (p *PushSink) Name() string {
+	return "pushgateway"
+}
+
+// Write implements Sink, retrying failed pushes
+func (p *PushSink) Write(g prometheus.Gatherer) error {
+	var err error
+	for attempt := 0; attempt <= p.config.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.config.RetryDelay)
+		}
+		if err = p.push(g); err == nil {
+			return nil
+		}
+		p.errors.Inc()
+		logger.Warn("Push to Pushgateway failed",
+			zap.String("url", p.config.URL),
+			zap.Int("attempt", attempt+1),
+			zap.Int("retries", p.config.Retries),
+			zap.Error(err))
+	}
+	return fmt.Errorf("push to %s failed after %d attempts: %w", p.config.URL, p.config.Retries+1, err)
+}
+
+func (p *PushSink) push(g prometheus.Gatherer) error {
+	return p.pusher(g).Push()
+}
+
+// pusher creates a pusher for one push, pushers accumulate gatherers and cannot be reused
+func (p *PushSink) pusher(g prometheus.Gatherer) *push.Pusher {
+	pusher := push.New(p.config.URL, p.config.Job)
+	if g != nil {
+		pusher = pusher.Gatherer(g)
+	}
+	for name, value := range p.config.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	return pusher
+}
+
+// Describe implements prometheus.Collector
+func (p *PushSink) Describe(ch chan<- *prometheus.Desc) {
+	p.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (p *PushSink) Collect(ch chan<- prometheus.Metric) {
+	p.errors.Collect(ch)
+}
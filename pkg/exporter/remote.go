@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RemoteMetricsConfig limits the fetching of metrics files given as http(s) URL,
+// so that a slow, huge or hostile endpoint cannot hang or exhaust the exporter
+type RemoteMetricsConfig struct {
+	Timeout    time.Duration // Maximum time of a single request
+	MaxSize    int64         // Maximum size of the file in bytes
+	Retries    int           // Additional attempts after a failed fetch when loading the metrics
+	RetryDelay time.Duration // Wait before the first retry, doubled after every further failure
+}
+
+// DefaultRemoteMetricsConfig are the limits used unless SetRemoteMetricsConfig is called
+var DefaultRemoteMetricsConfig = RemoteMetricsConfig{
+	Timeout:    10 * time.Second,
+	MaxSize:    10 * 1024 * 1024,
+	Retries:    3,
+	RetryDelay: time.Second,
+}
+
+// remoteMetrics holds the limits of remote metrics files, set once at startup
+var remoteMetrics = DefaultRemoteMetricsConfig
+
+// SetRemoteMetricsConfig sets the limits of remote metrics files. It must be called
+// before the metrics are loaded.
+func SetRemoteMetricsConfig(config RemoteMetricsConfig) {
+	remoteMetrics = config
+}
+
+// isRemoteMetricsFile reports whether a metrics file is given as http(s) URL
+func isRemoteMetricsFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// readMetricsFile returns the content of a local or remote metrics file. Remote
+// files are retried with backoff if retry is set, change checks during a scrape
+// try once to keep the scrape short.
+func readMetricsFile(name string, retry bool) ([]byte, error) {
+	if !isRemoteMetricsFile(name) {
+		return os.ReadFile(name)
+	}
+
+	attempts := 1
+	if retry {
+		attempts += remoteMetrics.Retries
+	}
+	delay := remoteMetrics.RetryDelay
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var data []byte
+		if data, err = fetchMetricsFile(name); err == nil {
+			return data, nil
+		}
+		if attempt == attempts {
+			break
+		}
+		logger.Warn("Fetching remote metrics file failed, retrying",
+			zap.String("url", name),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return nil, err
+}
+
+// fetchMetricsFile downloads a remote metrics file within the configured limits
+func fetchMetricsFile(url string) ([]byte, error) {
+	client := &http.Client{Timeout: remoteMetrics.Timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", url, resp.Status)
+	}
+	if remoteMetrics.MaxSize > 0 && resp.ContentLength > remoteMetrics.MaxSize {
+		return nil, fmt.Errorf("fetching %s: size %d exceeds the limit of %d bytes", url, resp.ContentLength, remoteMetrics.MaxSize)
+	}
+
+	body := io.Reader(resp.Body)
+	if remoteMetrics.MaxSize > 0 {
+		// Read one byte more than allowed to detect bodies without or with a wrong Content-Length
+		body = io.LimitReader(resp.Body, remoteMetrics.MaxSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	if remoteMetrics.MaxSize > 0 && int64(len(data)) > remoteMetrics.MaxSize {
+		return nil, fmt.Errorf("fetching %s: response exceeds the limit of %d bytes", url, remoteMetrics.MaxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Sink is an output mode that receives the collected metrics on the schedule
+// of a Scheduler instead of being scraped, e.g. a textfile for the node exporter
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Write gathers the metrics from g and delivers them
+	Write(g prometheus.Gatherer) error
+}
+
+// Scheduler collects the metrics of a gatherer on a fixed interval and feeds them to a sink
+type Scheduler struct {
+	gatherer prometheus.Gatherer
+	sink     Sink
+	interval time.Duration
+}
+
+// NewScheduler creates a scheduler writing the metrics of gatherer to sink every interval
+func NewScheduler(gatherer prometheus.Gatherer, sink Sink, interval time.Duration) (*Scheduler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("collection interval must be positive, got %s", interval)
+	}
+	return &Scheduler{
+		gatherer: gatherer,
+		sink:     sink,
+		interval: interval,
+	}, nil
+}
+
+// Run collects once immediately and then on every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	logger.Info("Starting scheduled collection",
+		zap.String("sink", s.sink.Name()),
+		zap.Duration("interval", s.interval))
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.collect()
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopped scheduled collection", zap.String("sink", s.sink.Name()))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect runs a single collection and writes it to the sink
+func (s *Scheduler) collect() {
+	start := time.Now()
+	if err := s.sink.Write(s.gatherer); err != nil {
+		logger.Error("Error writing scheduled collection",
+			zap.String("sink", s.sink.Name()),
+			zap.Error(err))
+		return
+	}
+	logger.Debug("Wrote scheduled collection",
+		zap.String("sink", s.sink.Name()),
+		zap.Duration("duration", time.Since(start)))
+}
+
+// TextfileSink writes the metrics to a file in the text exposition format, e.g.
+// for the textfile collector of the node exporter. The file is replaced atomically.
+type TextfileSink struct {
+	path string
+}
+
+// NewTextfileSink creates a sink writing to path, which should end in .prom
+func NewTextfileSink(path string) *TextfileSink {
+	return &TextfileSink{path: path}
+}
+
+// Name implements Sink
+func (t *TextfileSink) Name() string {
+	return "textfile"
+}
+
+// Write implements Sink
+func (t *TextfileSink) Write(g prometheus.Gatherer) error {
+	return prometheus.WriteToTextfile(t.path, g)
+}
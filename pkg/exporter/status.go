@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+)
+
+// ExporterStatus is a snapshot of the health of the exporter
+type ExporterStatus struct {
+	Paused        bool
+	InMaintenance bool
+	Scraping      bool
+
+	// Result of the last completed scrape, zero before the first one
+	TotalScrapes       uint64
+	LastScrapeTime     time.Time
+	LastScrapeDuration time.Duration
+	LastScrapeError    string
+
+	Targets []TargetStatus
+	Metrics []MetricStatus
+}
+
+// TargetStatus is the connection state of a scraped server
+type TargetStatus struct {
+	Server            string
+	Status            servermanager.Status
+	Connected         bool
+	ReconnectAttempts int
+	ReconnectDelay    time.Duration
+	WatchdogRestarts  uint64
+}
+
+// MetricStatus is the result of the last scrape of a metric command on a server
+type MetricStatus struct {
+	Server         string
+	Subsystem      string
+	Command        string
+	LastScrapeTime time.Time
+	Duration       time.Duration
+	Rows           int
+	Error          string
+}
+
+// scrapeStatus holds the state reported by Status that is not kept elsewhere
+type scrapeStatus struct {
+	mu                 sync.Mutex
+	totalScrapes       uint64
+	lastScrapeTime     time.Time
+	lastScrapeDuration time.Duration
+	lastScrapeError    string
+	metrics            map[string]MetricStatus
+}
+
+// recordScrape remembers the result of a scrape
+func (s *scrapeStatus) recordScrape(start time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totalScrapes++
+	s.lastScrapeTime = start
+	s.lastScrapeDuration = time.Since(start)
+	s.lastScrapeError = errorString(err)
+}
+
+// recordMetric remembers the result of scraping a metric command on a server
+func (s *scrapeStatus) recordMetric(server string, metric Metric, start time.Time, rows int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.metrics == nil {
+		s.metrics = make(map[string]MetricStatus)
+	}
+	s.metrics[server+"\x00"+metric.Subsystem+"\x00"+metric.Command] = MetricStatus{
+		Server:         server,
+		Subsystem:      metric.Subsystem,
+		Command:        metric.Command,
+		LastScrapeTime: start,
+		Duration:       time.Since(start),
+		Rows:           rows,
+		Error:          errorString(err),
+	}
+}
+
+// Status returns the current health of the exporter, e.g. for the web dashboard and health checks
+func (e *Exporter) Status() ExporterStatus {
+	e.status.mu.Lock()
+	status := ExporterStatus{
+		Paused:             e.IsPaused(),
+		InMaintenance:      e.activeMaintenanceWindow(time.Now()) != nil,
+		Scraping:           e.ScrapeInProgress(),
+		TotalScrapes:       e.status.totalScrapes,
+		LastScrapeTime:     e.status.lastScrapeTime,
+		LastScrapeDuration: e.status.lastScrapeDuration,
+		LastScrapeError:    e.status.lastScrapeError,
+	}
+	for _, metric := range e.status.metrics {
+		status.Metrics = append(status.Metrics, metric)
+	}
+	e.status.mu.Unlock()
+
+	sort.Slice(status.Metrics, func(i, j int) bool {
+		a, b := status.Metrics[i], status.Metrics[j]
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		if a.Subsystem != b.Subsystem {
+			return a.Subsystem < b.Subsystem
+		}
+		return a.Command < b.Command
+	})
+
+	for _, t := range e.targets {
+		attempts, delay := t.srvrmgr.ReconnectState()
+		smStatus := t.srvrmgr.GetStatus()
+		status.Targets = append(status.Targets, TargetStatus{
+			Server:            t.name,
+			Status:            smStatus,
+			Connected:         smStatus == servermanager.Connected,
+			ReconnectAttempts: attempts,
+			ReconnectDelay:    delay,
+			WatchdogRestarts:  t.srvrmgr.WatchdogRestarts(),
+		})
+	}
+
+	return status
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
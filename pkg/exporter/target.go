@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+)
+
+// target is a single Siebel application server scraped by the exporter
+type target struct {
+	name       string
+	enterprise string
+	srvrmgr    *servermanager.ServerManager
+
+	// Sessions used to run metric commands in parallel, nil when scraping sequentially
+	pool *servermanager.Pool
+
+	// Raw srvrmgr output of the commands of metrics with a CacheTTL, parsed per metric
+	// as the parsing depends on its settings
+	cache *resultCache
+
+	// Previous values of counters of metrics with DetectCounterResets
+	resets *counterTracker
+
+	// Metrics failing in a row, to log repeated scrape errors once
+	errors *metricErrorLog
+
+	// Constant labels added to every metric scraped from this target, and their
+	// values in the order of the target label names
+	labels      prometheus.Labels
+	labelValues []string
+
+	// Whether the last scrape of this target succeeded, guarded by scrapeMu
+	up bool
+}
+
+// newTargets creates a target for every ServerManager. When more than one server
+// is scraped, each target is identified by a "server" label on all of its metrics.
+// With enterpriseLabel, or when the servers belong to several enterprises, the
+// enterprise is added as "enterprise" label and is part of the target name.
+func newTargets(srvrmgrs []*servermanager.ServerManager, enterpriseLabel bool) ([]*target, []string) {
+	multipleEnterprises := len(targetEnterprises(srvrmgrs)) > 1
+
+	labelNames := []string{}
+	if len(srvrmgrs) > 1 {
+		labelNames = append(labelNames, "server")
+	}
+	if enterpriseLabel || multipleEnterprises {
+		labelNames = append(labelNames, "enterprise")
+	}
+
+	targets := make([]*target, 0, len(srvrmgrs))
+	for _, smgr := range srvrmgrs {
+		config := smgr.GetConfig()
+		labels := prometheus.Labels{}
+		labelValues := []string{}
+		for _, labelName := range labelNames {
+			value := config.Server
+			if labelName == "enterprise" {
+				value = config.Enterprise
+			}
+			labels[labelName] = value
+			labelValues = append(labelValues, value)
+		}
+
+		// The same server name may be scraped in several enterprises
+		name := config.Server
+		if multipleEnterprises {
+			name = config.Enterprise + "/" + config.Server
+		}
+
+		targets = append(targets, &target{
+			name:        name,
+			enterprise:  config.Enterprise,
+			srvrmgr:     smgr,
+			labels:      labels,
+			labelValues: labelValues,
+		})
+	}
+
+	return targets, labelNames
+}
+
+// targetEnterprises returns the distinct enterprises of the ServerManagers in order
+func targetEnterprises(srvrmgrs []*servermanager.ServerManager) []string {
+	var enterprises []string
+	for _, smgr := range srvrmgrs {
+		if enterprise := smgr.GetConfig().Enterprise; !slices.Contains(enterprises, enterprise) {
+			enterprises = append(enterprises, enterprise)
+		}
+	}
+	return enterprises
+}
+
+// conflictingLabel returns the first label of a metric that is also a target label,
+// which would make the metric invalid
+func conflictingLabel(metric Metric, targetLabels prometheus.Labels) string {
+	for _, label := range slices.Concat(metric.Labels, metric.TaskGroupBy) {
+		if _, exists := targetLabels[labelName(label, metric.LabelRename)]; exists {
+			return labelName(label, metric.LabelRename)
+		}
+	}
+	return ""
+}
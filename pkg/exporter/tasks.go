@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// DefaultTaskDetailLimit is the number of rows exported per scrape with TaskDetail
+// unless TaskDetailLimit is set
+const DefaultTaskDetailLimit = 100
+
+// taskCount is the number of rows with the same label values
+type taskCount struct {
+	labelValues []string
+	count       int
+}
+
+// taskGroupBy returns the columns the tasks of a metric are counted by
+func taskGroupBy(metric Metric) []string {
+	if len(metric.TaskGroupBy) > 0 {
+		return metric.TaskGroupBy
+	}
+	return metric.Labels
+}
+
+// exportTaskCounts sends the number of rows per combination of the TaskGroupBy
+// columns as <subsystem>_tasks. It returns the rows to convert to per-row metrics,
+// none unless TaskDetail is set, and the number of series sent.
+func exportTaskCounts(rows []map[string]string, namespace string, constLabels prometheus.Labels, ch *chan<- prometheus.Metric, metric Metric) ([]map[string]string, int) {
+	groupBy := taskGroupBy(metric)
+	labelNames := make([]string, 0, len(groupBy))
+	for _, column := range groupBy {
+		labelNames = append(labelNames, labelName(column, metric.LabelRename))
+	}
+
+	counts := make(map[string]*taskCount)
+	var order []string
+	for _, row := range rows {
+		labelValues := make([]string, 0, len(groupBy))
+		for _, column := range groupBy {
+			labelValue := row[column]
+			if strings.TrimSpace(labelValue) == "" {
+				labelValue = "unknown"
+			}
+			if metric.collapseLabelWhitespace {
+				labelValue = collapseWhitespace(labelValue)
+			}
+			labelValues = append(labelValues, labelValue)
+		}
+
+		key := strings.Join(labelValues, "\x00")
+		if c, exists := counts[key]; exists {
+			c.count++
+			continue
+		}
+		counts[key] = &taskCount{labelValues: labelValues, count: 1}
+		order = append(order, key)
+	}
+
+	help := "Number of tasks returned by the command of this subsystem."
+	if len(labelNames) > 0 {
+		help = "Number of tasks returned by the command of this subsystem, by " + strings.Join(labelNames, ", ") + "."
+	}
+	tasksDesc := prometheus.NewDesc(prometheus.BuildFQName(namespace, metric.Subsystem, "tasks"), help, labelNames, constLabels)
+	for _, key := range order {
+		c := counts[key]
+		*ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(c.count), c.labelValues...)
+	}
+	series := len(order)
+
+	logger.Debug("Aggregated tasks",
+		zap.String("subsystem", metric.Subsystem),
+		zap.Int("tasks", len(rows)),
+		zap.Int("series", series))
+
+	if !metric.TaskDetail {
+		return nil, series
+	}
+
+	// Per-task series are limited, a busy server runs thousands of tasks
+	limit := metric.TaskDetailLimit
+	if limit <= 0 {
+		limit = DefaultTaskDetailLimit
+	}
+	detailRows := rows
+	if len(detailRows) > limit {
+		logger.Debug("Limiting task details",
+			zap.String("subsystem", metric.Subsystem),
+			zap.Int("tasks", len(rows)),
+			zap.Int("limit", limit))
+		detailRows = detailRows[:limit]
+	}
+
+	droppedDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, metric.Subsystem, "task_details_dropped"),
+		"Number of tasks of this subsystem not exported in detail because of the task detail limit.",
+		nil, constLabels)
+	*ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.GaugeValue, float64(len(rows)-len(detailRows)))
+
+	return detailRows, series + 1
+}
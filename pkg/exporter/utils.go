@@ -3,69 +3,211 @@ package exporter
 import (
 	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
-	"hash"
-	"io"
-	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/BurntSushi/toml"
 	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
 	"go.uber.org/zap"
 )
 
-func hashFile(h hash.Hash, fn string) error {
-	f, err := os.Open(fn)
+// metricsMu guards defaultMetrics and metricsHashMap, which are read by scrapes
+// and replaced by reloads
+var metricsMu sync.RWMutex
+
+// metricsStale is set while the last reload failed and the previous metrics are in use
+var metricsStale atomic.Bool
+
+// errStaleMetrics fails scrapes with stale metrics if StaleMetricsIsScrapeError is set
+var errStaleMetrics = errors.New("last reload of the metrics files failed, using the previous metric definitions")
+
+// reloadMetricsIfItChanged reloads the metrics files if the content of any of them
+// changed since the last load and reports whether they were reloaded. If a file
+// is invalid or cannot be fetched, the current metrics are kept and the error returned.
+func reloadMetricsIfItChanged(defaultMetricsFile string, customMetricsFiles []string) (bool, error) {
+	files := metricsFiles(defaultMetricsFile, customMetricsFiles)
+	contents, changed := readChangedMetricsFiles(files)
+	if !changed {
+		return false, nil
+	}
+	logger.Info("Metrics files changed, reloading...",
+		zap.String("defaultFile", defaultMetricsFile),
+		zap.Strings("customFiles", customMetricsFiles))
+	if err := applyMetricsFiles(files, contents); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// metricsFiles returns all metrics files, the default file comes first.
+// The position of a file is its key in metricsHashMap.
+func metricsFiles(defaultMetricsFile string, customMetricsFiles []string) []string {
+	return append([]string{defaultMetricsFile}, customMetricsFiles...)
+}
+
+// readChangedMetricsFiles reads the metrics files once, without retrying remote
+// ones to keep the scrape short, and reports whether any content differs from the
+// last successful load. The content of a file that cannot be read is nil. The
+// files are read without holding metricsMu, so a slow remote file does not block
+// the scrapes reading the metric definitions.
+func readChangedMetricsFiles(files []string) ([][]byte, bool) {
+	contents := make([][]byte, len(files))
+	hashes := make([][]byte, len(files))
+	for i, metricsFile := range files {
+		logger.Debug("Checking if metrics file has changed", zap.String("file", metricsFile))
+
+		data, err := readMetricsFile(metricsFile, false)
+		if err != nil {
+			logger.Error("Unable to read metrics file", zap.Error(err), zap.String("file", metricsFile))
+			continue
+		}
+		contents[i] = data
+		hash := sha256.Sum256(data)
+		hashes[i] = hash[:]
+	}
+
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+
+	changed := false
+	for i, metricsFile := range files {
+		// Check if file has been changed
+		if hashes[i] != nil && !bytes.Equal(metricsHashMap[i], hashes[i]) {
+			logger.Info("File has changed, will reload metrics", zap.String("file", metricsFile))
+			changed = true
+		}
+	}
+
+	if !changed {
+		logger.Debug("No changes detected in metrics files")
+	}
+	return contents, changed
+}
+
+// loadMetrics loads metrics from the default and custom files and panics if a file is invalid
+func loadMetrics(defaultMetricsFile string, customMetricsFiles []string) {
+	if err := reloadMetrics(defaultMetricsFile, customMetricsFiles); err != nil {
+		panic(err)
+	}
+}
+
+// reloadMetrics loads metrics from the default file, then appends the metrics of the
+// custom files. A custom metric with the same subsystem and command as an already
+// loaded one replaces it. The current metrics are kept if any file is invalid.
+func reloadMetrics(defaultMetricsFile string, customMetricsFiles []string) error {
+	files := metricsFiles(defaultMetricsFile, customMetricsFiles)
+	return applyMetricsFiles(files, make([][]byte, len(files)))
+}
+
+// applyMetricsFiles decodes the metrics files, reading those without content, and
+// replaces the current metrics. The hashes of the files are only stored on success,
+// so a failed reload is tried again on the next scrape.
+func applyMetricsFiles(files []string, contents [][]byte) error {
+	metrics, err := decodeMetricsFiles(files, contents)
 	if err != nil {
+		metricsStale.Store(true)
 		return err
 	}
-	defer f.Close()
-	if _, err := io.Copy(h, f); err != nil {
-		return err
+
+	metricsMu.Lock()
+	defaultMetrics = metrics
+	for i, data := range contents {
+		hash := sha256.Sum256(data)
+		metricsHashMap[i] = hash[:]
 	}
+	metricsMu.Unlock()
+	metricsStale.Store(false)
+
+	logger.Info("Metrics ready", zap.Int("count", len(metrics.Metric)))
 	return nil
 }
 
-func reloadMetricsIfItChanged(metricsFile string) {
-	if checkIfMetricsChanged(metricsFile) {
-		logger.Info("Metrics file changed, reloading...", zap.String("file", metricsFile))
-		loadMetrics(metricsFile)
-	}
+// readMetrics decodes and merges the metrics files
+func readMetrics(defaultMetricsFile string, customMetricsFiles []string) (Metrics, error) {
+	files := metricsFiles(defaultMetricsFile, customMetricsFiles)
+	return decodeMetricsFiles(files, make([][]byte, len(files)))
 }
 
-func checkIfMetricsChanged(metricsFile string) bool {
-	logger.Debug("Checking if metrics file has changed", zap.String("file", metricsFile))
+// decodeMetricsFiles decodes and merges the metrics files. Files without content
+// are read first, and their content is stored in contents.
+func decodeMetricsFiles(files []string, contents [][]byte) (Metrics, error) {
+	var metrics Metrics
+
+	for i, metricsFile := range files {
+		var fileMetrics Metrics
 
-	h := sha256.New()
-	if err := hashFile(h, metricsFile); err != nil {
-		logger.Error("Unable to get file hash", zap.Error(err), zap.String("file", metricsFile))
-		return false
+		// Load metrics from file
+		var err error
+		if contents[i] == nil {
+			contents[i], err = readMetricsFile(metricsFile, true)
+		}
+		if err == nil {
+			_, err = toml.Decode(string(contents[i]), &fileMetrics)
+		}
+		if err != nil {
+			logger.Error("Failed to load metrics file",
+				zap.Error(err),
+				zap.String("file", metricsFile))
+			return Metrics{}, fmt.Errorf("error while loading %s: %w", metricsFile, err)
+		}
+
+		metrics.Metric = mergeMetrics(metrics.Metric, fileMetrics.Metric)
+
+		logger.Info("Successfully loaded metrics",
+			zap.String("file", metricsFile),
+			zap.Int("count", len(fileMetrics.Metric)))
 	}
 
-	// Check if file has been changed
-	currentHash := h.Sum(nil)
-	if !bytes.Equal(metricsHashMap[0], currentHash) {
-		logger.Info("File has changed, will reload metrics", zap.String("file", metricsFile))
-		metricsHashMap[0] = currentHash
-		return true
+	return metrics, nil
+}
+
+// ValidateMetrics loads the metrics files without using them and checks every metric
+// definition. It returns the number of metrics and all problems found, each naming its metric.
+func ValidateMetrics(defaultMetricsFile string, customMetricsFiles []string) (int, []error) {
+	metrics, err := readMetrics(defaultMetricsFile, customMetricsFiles)
+	if err != nil {
+		return 0, []error{err}
 	}
 
-	logger.Debug("No changes detected in metrics file")
-	return false
+	var problems []error
+	for i, metric := range metrics.Metric {
+		for _, problem := range validateMetricDesc(metric) {
+			problems = append(problems, fmt.Errorf("metric #%d (subsystem %q, command %q): %w",
+				i+1, metric.Subsystem, metric.Command, problem))
+		}
+	}
+	return len(metrics.Metric), problems
 }
 
-func loadMetrics(metricsFile string) {
-	// Clear existing metrics
-	defaultMetrics.Metric = []Metric{}
+// mergeMetrics appends custom metrics to base, replacing base metrics with the
+// same subsystem and command
+func mergeMetrics(base []Metric, custom []Metric) []Metric {
+	index := make(map[string]int, len(base))
+	for i, metric := range base {
+		index[metric.Subsystem+"\x00"+metric.Command] = i
+	}
 
-	// Load metrics from file
-	if _, err := toml.DecodeFile(metricsFile, &defaultMetrics); err != nil {
-		logger.Error("Failed to load metrics file",
-			zap.Error(err),
-			zap.String("file", metricsFile))
-		panic(fmt.Errorf("error while loading %s: %w", metricsFile, err))
+	for _, metric := range custom {
+		key := metric.Subsystem + "\x00" + metric.Command
+		if i, exists := index[key]; exists {
+			logger.Debug("Custom metric overrides existing definition",
+				zap.String("subsystem", metric.Subsystem),
+				zap.String("command", metric.Command))
+			base[i] = metric
+			continue
+		}
+		index[key] = len(base)
+		base = append(base, metric)
 	}
 
-	logger.Info("Successfully loaded metrics",
-		zap.String("file", metricsFile),
-		zap.Int("count", len(defaultMetrics.Metric)))
+	return base
+}
+
+// currentMetrics returns the currently loaded metric definitions
+func currentMetrics() []Metric {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return defaultMetrics.Metric
 }
@@ -0,0 +1,211 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// writeMetricsFile writes a metrics file to the test's temporary directory
+func writeMetricsFile(t testing.TB, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testDefaultMetrics = `
+[[Metric]]
+Command = "list comp show CC_ALIAS, CP_NUM_RUN_TASKS"
+Subsystem = "list_comp"
+Labels = [ "CC_ALIAS" ]
+[Metric.Help]
+CP_NUM_RUN_TASKS = "Default help."
+
+[[Metric]]
+Command = "list server show SBLSRVR_STATE"
+Subsystem = "list_server"
+[Metric.Help]
+SBLSRVR_STATE = "State of the server."
+`
+
+func TestReadMetricsWithCustomFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		custom      string
+		wantCommand []string
+		wantHelp    string
+		wantErr     bool
+	}{
+		{
+			name: "custom metric is appended",
+			custom: `
+[[Metric]]
+Command = "list tasks show TK_TASKID"
+Subsystem = "list_tasks"
+`,
+			wantCommand: []string{
+				"list comp show CC_ALIAS, CP_NUM_RUN_TASKS",
+				"list server show SBLSRVR_STATE",
+				"list tasks show TK_TASKID",
+			},
+			wantHelp: "Default help.",
+		},
+		{
+			name: "same subsystem and command overrides the default",
+			custom: `
+[[Metric]]
+Command = "list comp show CC_ALIAS, CP_NUM_RUN_TASKS"
+Subsystem = "list_comp"
+Labels = [ "CC_ALIAS" ]
+[Metric.Help]
+CP_NUM_RUN_TASKS = "Custom help."
+`,
+			wantCommand: []string{
+				"list comp show CC_ALIAS, CP_NUM_RUN_TASKS",
+				"list server show SBLSRVR_STATE",
+			},
+			wantHelp: "Custom help.",
+		},
+		{
+			name: "same command in another subsystem is appended",
+			custom: `
+[[Metric]]
+Command = "list comp show CC_ALIAS, CP_NUM_RUN_TASKS"
+Subsystem = "list_comp_tasks"
+`,
+			wantCommand: []string{
+				"list comp show CC_ALIAS, CP_NUM_RUN_TASKS",
+				"list server show SBLSRVR_STATE",
+				"list comp show CC_ALIAS, CP_NUM_RUN_TASKS",
+			},
+			wantHelp: "Default help.",
+		},
+		{
+			name:    "invalid custom file",
+			custom:  `[[Metric]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultFile := writeMetricsFile(t, "default.toml", testDefaultMetrics)
+			customFile := writeMetricsFile(t, "custom.toml", tt.custom)
+
+			metrics, err := readMetrics(defaultFile, []string{customFile})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readMetrics() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readMetrics() error = %v", err)
+			}
+
+			if len(metrics.Metric) != len(tt.wantCommand) {
+				t.Fatalf("got %d metrics, want %d", len(metrics.Metric), len(tt.wantCommand))
+			}
+			for i, metric := range metrics.Metric {
+				if metric.Command != tt.wantCommand[i] {
+					t.Errorf("metric %d command = %q, want %q", i, metric.Command, tt.wantCommand[i])
+				}
+			}
+			if got := metrics.Metric[0].Help["CP_NUM_RUN_TASKS"]; got != tt.wantHelp {
+				t.Errorf("help of the first metric = %q, want %q", got, tt.wantHelp)
+			}
+		})
+	}
+}
+
+func TestReadMetricsMissingCustomFile(t *testing.T) {
+	defaultFile := writeMetricsFile(t, "default.toml", testDefaultMetrics)
+	missing := filepath.Join(t.TempDir(), "missing.toml")
+
+	if _, err := readMetrics(defaultFile, []string{missing}); err == nil {
+		t.Error("readMetrics() with a missing custom file error = nil, want error")
+	}
+}
+
+func TestReloadRemoteMetricsIfItChanged(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		content string
+		fetches int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		fmt.Fprint(w, content)
+	}))
+	t.Cleanup(server.Close)
+	setContent := func(c string) {
+		mu.Lock()
+		defer mu.Unlock()
+		content = c
+	}
+
+	metricsMu.Lock()
+	previousMetrics, previousHashes := defaultMetrics, metricsHashMap
+	metricsHashMap = make(map[int][]byte)
+	metricsMu.Unlock()
+	t.Cleanup(func() {
+		metricsMu.Lock()
+		defaultMetrics, metricsHashMap = previousMetrics, previousHashes
+		metricsMu.Unlock()
+		metricsStale.Store(false)
+	})
+
+	setContent(testDefaultMetrics)
+	if err := reloadMetrics(server.URL, nil); err != nil {
+		t.Fatalf("reloadMetrics() error = %v", err)
+	}
+
+	// The steps run in order, each starting from the metrics the previous one left
+	steps := []struct {
+		name         string
+		content      string
+		wantReloaded bool
+		wantErr      bool
+		wantMetrics  int
+	}{
+		{name: "unchanged", content: testDefaultMetrics, wantMetrics: 2},
+		{name: "invalid content", content: `[[Metric]`, wantErr: true, wantMetrics: 2},
+		{name: "failed reload is retried", content: `[[Metric]`, wantErr: true, wantMetrics: 2},
+		{name: "fixed content", content: testDefaultMetrics + "\n[[Metric]]\nCommand = \"list tasks\"\nSubsystem = \"list_tasks\"\n", wantReloaded: true, wantMetrics: 3},
+		{name: "unchanged after reload", content: testDefaultMetrics + "\n[[Metric]]\nCommand = \"list tasks\"\nSubsystem = \"list_tasks\"\n", wantMetrics: 3},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			setContent(step.content)
+			mu.Lock()
+			fetches = 0
+			mu.Unlock()
+
+			reloaded, err := reloadMetricsIfItChanged(server.URL, nil)
+			if reloaded != step.wantReloaded || (err != nil) != step.wantErr {
+				t.Errorf("reloadMetricsIfItChanged() = %v, %v, want %v, error: %v", reloaded, err, step.wantReloaded, step.wantErr)
+			}
+			if got := len(currentMetrics()); got != step.wantMetrics {
+				t.Errorf("got %d metrics, want %d", got, step.wantMetrics)
+			}
+			if got := metricsStale.Load(); got != step.wantErr {
+				t.Errorf("metricsStale = %v, want %v", got, step.wantErr)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if fetches != 1 {
+				t.Errorf("metrics file fetched %d times, want 1", fetches)
+			}
+		})
+	}
+}
@@ -3,22 +3,33 @@ package logger
 import (
 	"container/ring"
 	"fmt"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 // LogEntry represents a single log entry with timestamp and message
 type LogEntry struct {
-	Timestamp time.Time
-	Level     string
-	Message   string
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Component string    `json:"component,omitempty"` // package that logged the entry, e.g. "servermanager"
+	Message   string    `json:"message"`
 }
 
 // String returns a formatted log entry
 func (e LogEntry) String() string {
-	return fmt.Sprintf("[%s] %s: %s",
+	if e.Component == "" {
+		return fmt.Sprintf("[%s] %s: %s",
+			e.Timestamp.Format("2006-01-02 15:04:05.000"),
+			e.Level,
+			e.Message)
+	}
+	return fmt.Sprintf("[%s] %s [%s]: %s",
 		e.Timestamp.Format("2006-01-02 15:04:05.000"),
 		e.Level,
+		e.Component,
 		e.Message)
 }
 
@@ -84,18 +95,63 @@ func (rb *RingBuffer) GetAll() []LogEntry {
 	return entries
 }
 
-// AddLogEntry adds a log entry to the global log buffer
+// AddLogEntry adds a log entry to the global log buffer, attributed to the package of the caller
 func AddLogEntry(level, message string) {
+	addLogEntry(level, message, 3)
+}
+
+// addLogEntry adds a log entry attributed to the package of the function skip
+// frames up the stack
+func addLogEntry(level, message string, skip int) {
 	// Skip if logs are disabled
 	if disableLogs {
 		return
 	}
 
-	logBuffer.Add(LogEntry{
+	entry := LogEntry{
 		Timestamp: time.Now(),
 		Level:     level,
+		Component: callerComponent(skip),
 		Message:   message,
-	})
+	}
+	logBuffer.Add(entry)
+	publish(entry)
+}
+
+// callerComponent returns the package name of the function skip frames up the
+// stack, e.g. "servermanager" for github.com/.../pkg/servermanager.(*ServerManager).connect
+func callerComponent(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// GetLogComponents returns the sorted components of all log entries in the global log buffer
+func GetLogComponents() []string {
+	seen := map[string]bool{}
+	var components []string
+	for _, entry := range logBuffer.GetAll() {
+		if entry.Component != "" && !seen[entry.Component] {
+			seen[entry.Component] = true
+			components = append(components, entry.Component)
+		}
+	}
+	sort.Strings(components)
+	return components
 }
 
 // GetLogEntries returns all log entries from the global log buffer
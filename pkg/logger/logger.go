@@ -2,7 +2,6 @@ package logger
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 
@@ -19,6 +18,9 @@ var (
 
 	// Initialize once
 	once sync.Once
+
+	// atomicLevel is the level of all outputs, changeable at runtime with SetLevel
+	atomicLevel = zap.NewAtomicLevel()
 )
 
 // Level represents the logging level
@@ -34,105 +36,153 @@ const (
 	FatalLevel Level = "fatal"
 )
 
-// Init initializes the logger with the specified level
+// Config defines the level and the outputs of the logger
+type Config struct {
+	Level Level
+
+	// Outputs lists where logs are written to at the same time: "stdout", "stderr",
+	// "syslog" or a file path. Defaults to stdout.
+	Outputs []string
+
+	// Rotation of file outputs
+	Rotation RotationConfig
+
+	// Format of the log lines: "console" (default) or "json" for log shippers
+	// like Loki or Elasticsearch. The /logs buffer is the same in both formats.
+	Format string
+
+	// Syslog settings, used when Outputs contains "syslog". An empty address logs
+	// to the local syslog daemon, otherwise it has the form "udp://host:514".
+	SyslogAddress string
+	SyslogTag     string
+}
+
+// Init initializes the logger with the specified level, writing to stdout
 // This function should be called early in your application's lifecycle
 func Init(level Level) {
+	if err := InitWithConfig(Config{Level: level}); err != nil {
+		fmt.Printf("Logger initialization error: %v\n", err)
+	}
+}
+
+// InitWithConfig initializes the logger with the specified level and outputs.
+// If an output cannot be opened, the remaining outputs are used and the error is returned.
+func InitWithConfig(config Config) error {
+	var initErr error
+
 	once.Do(func() {
+		level := config.Level
+
 		// Parse log level
 		var zapLevel zapcore.Level
 
 		// Add more explicit logging about the requested level
 		fmt.Printf("Initializing logger with requested level: %s\n", string(level))
 
-		switch strings.ToLower(string(level)) {
-		case "debug":
-			zapLevel = zapcore.DebugLevel
-		case "info":
-			zapLevel = zapcore.InfoLevel
-		case "warn":
-			zapLevel = zapcore.WarnLevel
-		case "error":
-			zapLevel = zapcore.ErrorLevel
-		case "panic":
-			zapLevel = zapcore.PanicLevel
-		case "fatal":
-			zapLevel = zapcore.FatalLevel
-		default:
+		zapLevel, ok := parseLevel(level)
+		if !ok {
 			fmt.Printf("Unknown log level: '%s', defaulting to info\n", string(level))
 			zapLevel = zapcore.InfoLevel
 		}
 
 		fmt.Printf("Logger will use zapcore level: %s\n", zapLevel.String())
+		atomicLevel.SetLevel(zapLevel)
 
-		// Create encoder configuration
-		encoderConfig := zapcore.EncoderConfig{
-			TimeKey:        "ts",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller",
-			FunctionKey:    zapcore.OmitKey,
-			MessageKey:     "msg",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalColorLevelEncoder,
-			EncodeTime:     zapcore.ISO8601TimeEncoder,
-			EncodeDuration: zapcore.StringDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		}
-
-		// Create core
-		core := zapcore.NewCore(
-			zapcore.NewConsoleEncoder(encoderConfig),
-			zapcore.AddSync(os.Stdout),
-			zapLevel,
-		)
+		// Create one core per kind of output
+		var core zapcore.Core
+		core, initErr = newCore(config, atomicLevel)
 
 		// Create logger
 		Log = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 		Sugar = Log.Sugar()
 
-		// Log the initialization at the level that was set
+		// Log the initialization at the level that was set. The field is not named
+		// "level", which would duplicate the level key in JSON lines.
+		initLog := Log.WithOptions(zap.AddCallerSkip(-1))
 		if zapLevel == zapcore.DebugLevel {
-			Log.Debug("Logger initialized with debug level")
+			initLog.Debug("Logger initialized with debug level", zap.Strings("outputs", config.Outputs))
 		} else {
-			Log.Info("Logger initialized", zap.String("level", zapLevel.String()))
+			initLog.Info("Logger initialized",
+				zap.String("logLevel", zapLevel.String()),
+				zap.Strings("outputs", config.Outputs))
 		}
 	})
+
+	return initErr
+}
+
+// parseLevel converts a level name to the zapcore level
+func parseLevel(level Level) (zapcore.Level, bool) {
+	switch strings.ToLower(string(level)) {
+	case "debug":
+		return zapcore.DebugLevel, true
+	case "info":
+		return zapcore.InfoLevel, true
+	case "warn":
+		return zapcore.WarnLevel, true
+	case "error":
+		return zapcore.ErrorLevel, true
+	case "panic":
+		return zapcore.PanicLevel, true
+	case "fatal":
+		return zapcore.FatalLevel, true
+	default:
+		return zapcore.InfoLevel, false
+	}
+}
+
+// SetLevel changes the level of all outputs at runtime, e.g. to debug a live incident
+func SetLevel(level Level) error {
+	zapLevel, ok := parseLevel(level)
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	previous := atomicLevel.Level()
+	atomicLevel.SetLevel(zapLevel)
+	Info("Log level changed",
+		zap.String("previous", previous.String()),
+		zap.String("current", zapLevel.String()))
+	return nil
+}
+
+// GetLevel returns the current log level
+func GetLevel() Level {
+	return Level(atomicLevel.Level().String())
 }
 
 // Debug logs a message at debug level
 func Debug(msg string, fields ...zap.Field) {
 	ensureLogger()
 	Log.Debug(msg, fields...)
-	AddLogEntry("DEBUG", formatLogMessage(msg, fields))
+	addLogEntry("DEBUG", formatLogMessage(msg, fields), 3)
 }
 
 // Info logs a message at info level
 func Info(msg string, fields ...zap.Field) {
 	ensureLogger()
 	Log.Info(msg, fields...)
-	AddLogEntry("INFO", formatLogMessage(msg, fields))
+	addLogEntry("INFO", formatLogMessage(msg, fields), 3)
 }
 
 // Warn logs a message at warn level
 func Warn(msg string, fields ...zap.Field) {
 	ensureLogger()
 	Log.Warn(msg, fields...)
-	AddLogEntry("WARN", formatLogMessage(msg, fields))
+	addLogEntry("WARN", formatLogMessage(msg, fields), 3)
 }
 
 // Error logs a message at error level
 func Error(msg string, fields ...zap.Field) {
 	ensureLogger()
 	Log.Error(msg, fields...)
-	AddLogEntry("ERROR", formatLogMessage(msg, fields))
+	addLogEntry("ERROR", formatLogMessage(msg, fields), 3)
 }
 
 // Fatal logs a message at fatal level and then calls os.Exit(1)
 func Fatal(msg string, fields ...zap.Field) {
 	ensureLogger()
 	Log.Fatal(msg, fields...)
-	AddLogEntry("FATAL", formatLogMessage(msg, fields))
+	addLogEntry("FATAL", formatLogMessage(msg, fields), 3)
 }
 
 // Debugf logs a formatted message at debug level
@@ -140,7 +190,7 @@ func Debugf(format string, args ...interface{}) {
 	ensureLogger()
 	Sugar.Debugf(format, args...)
 	formattedMsg := fmt.Sprintf(format, args...)
-	AddLogEntry("DEBUG", formattedMsg)
+	addLogEntry("DEBUG", formattedMsg, 3)
 }
 
 // Infof logs a formatted message at info level
@@ -148,7 +198,7 @@ func Infof(format string, args ...interface{}) {
 	ensureLogger()
 	Sugar.Infof(format, args...)
 	formattedMsg := fmt.Sprintf(format, args...)
-	AddLogEntry("INFO", formattedMsg)
+	addLogEntry("INFO", formattedMsg, 3)
 }
 
 // Warnf logs a formatted message at warn level
@@ -156,7 +206,7 @@ func Warnf(format string, args ...interface{}) {
 	ensureLogger()
 	Sugar.Warnf(format, args...)
 	formattedMsg := fmt.Sprintf(format, args...)
-	AddLogEntry("WARN", formattedMsg)
+	addLogEntry("WARN", formattedMsg, 3)
 }
 
 // Errorf logs a formatted message at error level
@@ -164,7 +214,7 @@ func Errorf(format string, args ...interface{}) {
 	ensureLogger()
 	Sugar.Errorf(format, args...)
 	formattedMsg := fmt.Sprintf(format, args...)
-	AddLogEntry("ERROR", formattedMsg)
+	addLogEntry("ERROR", formattedMsg, 3)
 }
 
 // Fatalf logs a formatted message at fatal level and then calls os.Exit(1)
@@ -172,7 +222,7 @@ func Fatalf(format string, args ...interface{}) {
 	ensureLogger()
 	Sugar.Fatalf(format, args...)
 	formattedMsg := fmt.Sprintf(format, args...)
-	AddLogEntry("FATAL", formattedMsg)
+	addLogEntry("FATAL", formattedMsg, 3)
 }
 
 // With creates a child logger with the given fields added to it
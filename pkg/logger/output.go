@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// newEncoderConfig returns the encoder configuration of the logger. Colored levels
+// are only used for terminal outputs.
+func newEncoderConfig(color bool) zapcore.EncoderConfig {
+	encodeLevel := zapcore.CapitalLevelEncoder
+	if color {
+		encodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+
+	return zapcore.EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    encodeLevel,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+}
+
+// newEncoder returns a JSON encoder for the "json" format and a console encoder
+// otherwise. JSON output is never colored.
+func newEncoder(format string, encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+	if format == "json" {
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		return zapcore.NewJSONEncoder(encoderConfig)
+	}
+	return zapcore.NewConsoleEncoder(encoderConfig)
+}
+
+// newCore tees the configured outputs into a single core. Outputs that cannot be
+// opened are skipped and reported in the returned error; stdout is used if no
+// output is left.
+func newCore(config Config, level zapcore.LevelEnabler) (zapcore.Core, error) {
+	outputs := config.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{"stdout"}
+	}
+
+	var (
+		terminals []zapcore.WriteSyncer
+		files     []zapcore.WriteSyncer
+		cores     []zapcore.Core
+		errs      []error
+	)
+
+	for _, output := range outputs {
+		switch output {
+		case "stdout":
+			terminals = append(terminals, zapcore.Lock(os.Stdout))
+		case "stderr":
+			terminals = append(terminals, zapcore.Lock(os.Stderr))
+		case "syslog":
+			// Syslog adds its own timestamp and severity
+			encoderConfig := newEncoderConfig(false)
+			encoderConfig.TimeKey = zapcore.OmitKey
+			encoderConfig.LevelKey = zapcore.OmitKey
+
+			core, err := newSyslogCore(config.SyslogAddress, config.SyslogTag, newEncoder(config.Format, encoderConfig), level)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("syslog output: %w", err))
+				continue
+			}
+			cores = append(cores, core)
+		default:
+			f, err := openRotatingFile(output, config.Rotation)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("file output: %w", err))
+				continue
+			}
+			files = append(files, f)
+		}
+	}
+
+	if len(terminals) > 0 {
+		cores = append(cores, zapcore.NewCore(
+			newEncoder(config.Format, newEncoderConfig(true)),
+			zapcore.NewMultiWriteSyncer(terminals...),
+			level,
+		))
+	}
+
+	if len(files) > 0 {
+		cores = append(cores, zapcore.NewCore(
+			newEncoder(config.Format, newEncoderConfig(false)),
+			zapcore.NewMultiWriteSyncer(files...),
+			level,
+		))
+	}
+
+	if len(cores) == 0 {
+		cores = append(cores, zapcore.NewCore(
+			newEncoder(config.Format, newEncoderConfig(true)),
+			zapcore.Lock(os.Stdout),
+			level,
+		))
+	}
+
+	return zapcore.NewTee(cores...), errors.Join(errs...)
+}
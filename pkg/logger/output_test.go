@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// readLines returns the lines of a log file
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening log file: %v", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	return lines
+}
+
+func TestJSONFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    func(l *zap.Logger)
+		level  string
+		msg    string
+		fields map[string]interface{}
+	}{
+		{
+			name:  "message",
+			log:   func(l *zap.Logger) { l.Info("Scrape completed") },
+			level: "INFO",
+			msg:   "Scrape completed",
+		},
+		{
+			name:  "quotes and line breaks",
+			log:   func(l *zap.Logger) { l.Warn("Unexpected output \"SBL-ADM-60070\"\nfrom srvrmgr") },
+			level: "WARN",
+			msg:   "Unexpected output \"SBL-ADM-60070\"\nfrom srvrmgr",
+		},
+		{
+			name: "fields",
+			log: func(l *zap.Logger) {
+				l.Error("Command failed",
+					zap.String("command", "list comp"),
+					zap.Int("attempt", 2),
+					zap.Duration("timeout", 10*time.Second),
+					zap.Error(errors.New("timeout: waiting for prompt")))
+			},
+			level: "ERROR",
+			msg:   "Command failed",
+			fields: map[string]interface{}{
+				"command": "list comp",
+				"attempt": float64(2),
+				"timeout": "10s",
+				"error":   "timeout: waiting for prompt",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "exporter.log")
+			core, err := newCore(Config{Outputs: []string{path}, Format: "json"}, zapcore.DebugLevel)
+			if err != nil {
+				t.Fatalf("newCore() error = %v", err)
+			}
+			l := zap.New(core, zap.AddCaller())
+			tt.log(l)
+			l.Sync()
+
+			lines := readLines(t, path)
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, want 1: %q", len(lines), lines)
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatalf("line is not valid JSON: %v\n%s", err, lines[0])
+			}
+			for _, key := range []string{"ts", "caller"} {
+				if _, exists := entry[key]; !exists {
+					t.Errorf("line has no %q key: %s", key, lines[0])
+				}
+			}
+			if got := entry["level"]; got != tt.level {
+				t.Errorf("level = %v, want %s", got, tt.level)
+			}
+			if got := entry["msg"]; got != tt.msg {
+				t.Errorf("msg = %q, want %q", got, tt.msg)
+			}
+			for key, want := range tt.fields {
+				if got := entry[key]; got != want {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string // content of the log file before logging
+		missing   bool   // the directory of the log file does not exist
+		wantLines int
+		wantErr   bool
+	}{
+		{name: "new file", wantLines: 1},
+		{name: "appended to existing file", existing: "previous line\n", wantLines: 2},
+		{name: "missing directory", missing: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.missing {
+				dir = filepath.Join(dir, "missing")
+			}
+			path := filepath.Join(dir, "exporter.log")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			core, err := newCore(Config{Outputs: []string{path}}, zapcore.InfoLevel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if core == nil {
+				t.Fatal("newCore() returned no core")
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("log file exists after failed open: %v", err)
+				}
+				return
+			}
+
+			l := zap.New(core)
+			l.Info("Logger initialized")
+			l.Debug("Not written below the level")
+			l.Sync()
+
+			lines := readLines(t, path)
+			if len(lines) != tt.wantLines {
+				t.Fatalf("got %d lines, want %d: %q", len(lines), tt.wantLines, lines)
+			}
+			if last := lines[len(lines)-1]; !strings.Contains(last, "Logger initialized") {
+				t.Errorf("last line = %q, want the logged message", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layout of the timestamp in the names of rotated log files, sorts chronologically
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotationConfig defines when log files are rotated and how many old files are kept
+type RotationConfig struct {
+	MaxSize    int           // Size in megabytes at which a file is rotated, 0 disables rotation
+	MaxBackups int           // Number of rotated files to keep, 0 keeps all
+	MaxAge     time.Duration // Rotated files older than this are removed, 0 keeps them
+}
+
+// rotatingFile is a log file that is renamed to <name>-<timestamp><ext> and
+// started anew once it would exceed the configured size
+type rotatingFile struct {
+	mu     sync.Mutex
+	path   string
+	config RotationConfig
+	file   *os.File
+	size   int64
+}
+
+// openRotatingFile opens path for appending, creating it if needed
+func openRotatingFile(path string, config RotationConfig) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, config: config}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write implements zapcore.WriteSyncer
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maxSize := int64(r.config.MaxSize) * 1024 * 1024
+	if maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > maxSize {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than losing messages
+			fmt.Fprintf(os.Stderr, "Log rotation of %s failed: %v\n", r.path, err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Sync implements zapcore.WriteSyncer
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Sync()
+}
+
+// rotate renames the current file to a backup, opens a new one and removes
+// backups beyond the configured count and age
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(r.path, ext) + "-"
+	backup := prefix + time.Now().Format(backupTimeFormat) + ext
+	if err := os.Rename(r.path, backup); err != nil {
+		// Continue appending to the file that could not be renamed
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.removeOldBackups(prefix, ext)
+	return nil
+}
+
+// removeOldBackups deletes rotated files beyond MaxBackups and older than MaxAge
+func (r *rotatingFile) removeOldBackups(prefix, ext string) {
+	if r.config.MaxBackups <= 0 && r.config.MaxAge <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+	// Leave files alone that only look like backups, e.g. exporter-old.log
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	// Newest first, the timestamps in the names sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	now := time.Now()
+	for i, backup := range backups {
+		expired := false
+		if r.config.MaxBackups > 0 && i >= r.config.MaxBackups {
+			expired = true
+		}
+		if r.config.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && now.Sub(info.ModTime()) > r.config.MaxAge {
+				expired = true
+			}
+		}
+		if expired {
+			os.Remove(backup)
+		}
+	}
+}
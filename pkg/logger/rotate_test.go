@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	const megabyte = 1024 * 1024
+
+	tests := []struct {
+		name        string
+		config      RotationConfig
+		writes      int
+		wantBackups int
+	}{
+		{name: "rotation disabled", config: RotationConfig{}, writes: 4, wantBackups: 0},
+		{name: "rotated at max size", config: RotationConfig{MaxSize: 1}, writes: 4, wantBackups: 3},
+		{name: "old backups removed", config: RotationConfig{MaxSize: 1, MaxBackups: 1}, writes: 4, wantBackups: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "exporter.log")
+			r, err := openRotatingFile(path, tt.config)
+			if err != nil {
+				t.Fatalf("openRotatingFile() error = %v", err)
+			}
+			t.Cleanup(func() { r.file.Close() })
+
+			// Each write fills more than half of the maximum size, so every further write rotates
+			line := append(bytes.Repeat([]byte("x"), 600*1024), '\n')
+			for i := 0; i < tt.writes; i++ {
+				if _, err := r.Write(line); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				// Backup names have millisecond resolution
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			backups, err := filepath.Glob(filepath.Join(dir, "exporter-*.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Errorf("got %d backups, want %d: %q", len(backups), tt.wantBackups, backups)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("log file: %v", err)
+			}
+			if tt.config.MaxSize > 0 && info.Size() > int64(tt.config.MaxSize)*megabyte {
+				t.Errorf("log file size = %d, want at most %d", info.Size(), tt.config.MaxSize*megabyte)
+			}
+		})
+	}
+}
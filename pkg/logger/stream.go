@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// logSubscribers receive every log entry added to the global log buffer, e.g. for
+// streaming the logs to a browser
+var logSubscribers = struct {
+	mu   sync.Mutex
+	subs map[chan LogEntry]struct{}
+}{subs: make(map[chan LogEntry]struct{})}
+
+// Entries not delivered because the buffer of a subscriber was full
+var logMessagesDropped atomic.Uint64
+
+// Subscribe registers a subscriber for new log entries. Entries that do not fit
+// into the buffer of bufferSize entries are dropped rather than blocking logging.
+// The returned function unsubscribes and must be called when done.
+func Subscribe(bufferSize int) (<-chan LogEntry, func()) {
+	ch := make(chan LogEntry, bufferSize)
+
+	logSubscribers.mu.Lock()
+	logSubscribers.subs[ch] = struct{}{}
+	logSubscribers.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			logSubscribers.mu.Lock()
+			delete(logSubscribers.subs, ch)
+			logSubscribers.mu.Unlock()
+		})
+	}
+}
+
+// publish hands an entry to all subscribers without waiting for slow ones
+func publish(entry LogEntry) {
+	logSubscribers.mu.Lock()
+	defer logSubscribers.mu.Unlock()
+
+	for ch := range logSubscribers.subs {
+		select {
+		case ch <- entry:
+		default:
+			logMessagesDropped.Add(1)
+		}
+	}
+}
+
+// LogSubscribers returns the number of active log subscribers
+func LogSubscribers() int {
+	logSubscribers.mu.Lock()
+	defer logSubscribers.mu.Unlock()
+	return len(logSubscribers.subs)
+}
+
+// LogMessagesDropped returns the number of log entries dropped for slow subscribers
+func LogMessagesDropped() uint64 {
+	return logMessagesDropped.Load()
+}
@@ -2,6 +2,7 @@ package servermanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -21,6 +22,47 @@ func (sm *ServerManager) SendCommandWithTimeout(command string, timeout time.Dur
 		zap.String("command", command),
 		zap.Duration("timeout", timeout))
 
+	// Stray whitespace or a trailing semicolon can confuse srvrmgr prompt detection
+	if sm.GetConfig().NormalizeCommands {
+		if normalized := normalizeCommand(command); normalized != command {
+			logger.Debug("Command normalized before sending",
+				zap.String("original", command),
+				zap.String("normalized", normalized))
+			command = normalized
+		}
+	}
+
+	result, err := sm.sendCommandOnce(command, timeout)
+
+	// Smooth over the reconnect window: wait for the session to be re-established and
+	// retry, as often as configured while the connection keeps getting lost
+	config := sm.GetConfig()
+	var lost *connectionLostError
+	for attempt := 1; attempt <= config.CommandRetries && errors.As(err, &lost) && config.AutoReconnect && config.CommandRetryWait > 0; attempt++ {
+		logger.Info("Waiting for reconnection to retry command",
+			zap.String("command", command),
+			zap.Int("attempt", attempt),
+			zap.Int("retries", config.CommandRetries),
+			zap.Duration("maxWait", config.CommandRetryWait))
+
+		if !sm.waitForReconnect(lost.generation, config.CommandRetryWait) {
+			logger.Warn("Connection not restored in time, giving up on command",
+				zap.String("command", command))
+			break
+		}
+		logger.Info("Connection restored, retrying command",
+			zap.String("command", command),
+			zap.Int("attempt", attempt))
+		result, err = sm.sendCommandOnce(command, timeout)
+	}
+
+	return result, err
+}
+
+// sendCommandOnce sends a command a single time. Lost connections are reported as *connectionLostError.
+func (sm *ServerManager) sendCommandOnce(command string, timeout time.Duration) ([]string, error) {
+	generation := sm.Generation()
+
 	// Check connection status before attempting command
 	if status := sm.GetStatus(); status != Connected {
 		// If we're reconnecting, wait a moment and try again
@@ -31,6 +73,7 @@ func (sm *ServerManager) SendCommandWithTimeout(command string, timeout time.Dur
 			// Wait briefly for reconnection to complete
 			time.Sleep(500 * time.Millisecond)
 
+			generation = sm.Generation()
 			if sm.GetStatus() == Connected {
 				// Reconnected successfully, continue with command
 				logger.Info("Connection restored, proceeding with command")
@@ -82,12 +125,12 @@ func (sm *ServerManager) SendCommandWithTimeout(command string, timeout time.Dur
 				if currentStatus == Connected {
 					// Command failed with pipe error but we thought we were connected
 					logger.Warn("Pipe error detected while connected, initiating reconnection")
-					go sm.tryReconnect()
+					sm.startReconnect()
 				}
 			}
 
 			// Convert the error to a more user-friendly message
-			return nil, fmt.Errorf("connection to srvrmgr lost: %v", err)
+			return nil, &connectionLostError{generation: generation, err: err}
 		}
 	}
 
@@ -111,16 +154,63 @@ func (sm *ServerManager) SendCommandWithTimeout(command string, timeout time.Dur
 	return result, err
 }
 
+// connectionLostError is returned when a command failed because the srvrmgr pipes broke.
+// It records the session generation the command was sent on, so a retry waits for a newer session.
+type connectionLostError struct {
+	generation uint64
+	err        error
+}
+
+func (e *connectionLostError) Error() string {
+	return fmt.Sprintf("connection to srvrmgr lost: %v", e.err)
+}
+
+func (e *connectionLostError) Unwrap() error {
+	return e.err
+}
+
+// waitForReconnect waits up to maxWait for a session newer than generation to be connected
+func (sm *ServerManager) waitForReconnect(generation uint64, maxWait time.Duration) bool {
+	deadline := time.Now().Add(maxWait)
+	for time.Now().Before(deadline) {
+		if sm.GetStatus() == Connected && sm.Generation() > generation {
+			return true
+		}
+		if !sm.IsReconnecting() && sm.GetStatus() == ConnectionError {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
 // sendCommandWithContext sends a command to srvrmgr with context for timeout/cancellation
 func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command string) ([]string, error) {
+	return sm.sendCommandInStatus(ctx, command, Connected)
+}
+
+// sendCommandInStatus sends a command to srvrmgr if the session has the required
+// status, which is Connecting for the startup commands and Connected otherwise
+func (sm *ServerManager) sendCommandInStatus(ctx context.Context, command string, required Status) ([]string, error) {
 	logger.Debug("Sending command with context",
 		zap.String("command", command),
 		zap.Duration("timeout", getRemainingTimeout(ctx)))
 
+	// Wait for the prompts of timed-out commands instead of skipping their output
+	// while reading the result of this one
+	if sm.GetConfig().ResyncBeforeCommand && !sm.discardUntilPrompt(ctx) {
+		logger.Warn("srvrmgr is still busy with a timed-out command, not sending command",
+			zap.String("command", command))
+		return nil, fmt.Errorf("timeout: srvrmgr still busy with a previous command")
+	}
+
+	// Make sure output of previous commands does not end up in the result of this one
+	sm.drainOutput(ctx, sm.GetConfig().DrainQuietPeriod)
+
 	sm.mu.Lock()
 
 	// Check if we're connected before sending
-	if sm.status != Connected {
+	if sm.status != required {
 		status := sm.status
 		sm.mu.Unlock()
 		logger.Warn("Cannot send command with context: not connected",
@@ -131,11 +221,22 @@ func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command str
 	// Update last activity time
 	sm.lastActivity = time.Now()
 
+	// Start a new epoch; prompts of earlier timed-out commands are skipped below
+	sm.epoch++
+	epoch := sm.epoch
+	promptsToSkip := sm.stalePrompts
+
 	// Clear previous output
 	sm.stdoutOutput = []string{}
 	sm.stderrOutput = []string{}
 	sm.mu.Unlock()
 
+	if promptsToSkip > 0 {
+		logger.Debug("Output of timed-out commands is still pending",
+			zap.Uint64("epoch", epoch),
+			zap.Int("stalePrompts", promptsToSkip))
+	}
+
 	// Write the command to stdin
 	sm.mu.Lock()
 	logger.Debug("Writing command to stdin")
@@ -172,12 +273,22 @@ func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command str
 	for {
 		select {
 		case <-ctx.Done():
+			// If our prompt has not shown up yet, the output of this command will arrive
+			// later; remember it so the next command can discard it
+			if skipInitialOutput {
+				sm.mu.Lock()
+				sm.stalePrompts++
+				sm.mu.Unlock()
+			}
+
 			duration := time.Since(pollStartTime)
 			logger.Warn("Command timed out waiting for prompt",
 				zap.String("command", command),
+				zap.Uint64("epoch", epoch),
 				zap.Duration("pollDuration", duration),
 				zap.Int("pollCount", pollCount),
 				zap.Int("currentOutputLines", len(output)))
+			sm.resyncAfterTimeout(command)
 			return output, fmt.Errorf("timeout: waiting for prompt from srvrmgr")
 		case <-time.After(100 * time.Millisecond):
 			pollCount++
@@ -202,6 +313,16 @@ func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command str
 				if skipInitialOutput {
 					// If we find the prompt, stop skipping
 					if sm.promptStartedPattern.MatchString(line) {
+						// The prompt belongs to a timed-out command, keep skipping its output
+						if promptsToSkip > 0 {
+							promptsToSkip--
+							sm.stalePrompts--
+							logger.Debug("Discarding late output of a timed-out command",
+								zap.Uint64("epoch", epoch),
+								zap.String("line", line))
+							sm.mu.Unlock()
+							continue
+						}
 						logger.Debug("Found initial prompt marker, starting to collect output")
 						skipInitialOutput = false
 						sm.mu.Unlock()
@@ -225,15 +346,15 @@ func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command str
 						zap.Duration("duration", duration),
 						zap.Int("pollCount", pollCount))
 
-					// Remove duplicates and return
-					uniqueOutput := removeDuplicates(output)
-					if len(uniqueOutput) != len(output) {
-						logger.Debug("Removed duplicate lines from output",
+					// Remove command echoes and surplus blank lines; data rows are kept as is
+					cleanOutput := removeNoise(output, command)
+					if len(cleanOutput) != len(output) {
+						logger.Debug("Removed noise lines from output",
 							zap.Int("before", len(output)),
-							zap.Int("after", len(uniqueOutput)))
+							zap.Int("after", len(cleanOutput)))
 					}
 
-					return uniqueOutput, nil
+					return cleanOutput, nil
 				}
 
 				// Add the line to the output
@@ -247,12 +368,17 @@ func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command str
 				line := sm.stderrOutput[0]
 				sm.stderrOutput = sm.stderrOutput[1:]
 
-				// Trim whitespace before appending to output
 				line = strings.TrimSpace(line)
 
-				// Append stderr lines to output
-				output = append(output, line)
-				logger.Warn("Received stderr output", zap.String("line", line))
+				// Stderr is kept out of the output unless explicitly requested,
+				// so error text is never parsed as data rows
+				if sm.config.MergeStderr && !skipInitialOutput {
+					output = append(output, line)
+				}
+				logger.Warn("Received stderr output",
+					zap.String("command", command),
+					zap.String("line", line),
+					zap.Bool("merged", sm.config.MergeStderr && !skipInitialOutput))
 				sm.mu.Unlock()
 				continue
 			}
@@ -262,6 +388,119 @@ func (sm *ServerManager) sendCommandWithContext(ctx context.Context, command str
 	}
 }
 
+// drainOutput discards buffered output until srvrmgr has been silent for quietPeriod
+// or the context is done. Prompts of timed-out commands found in the discarded
+// output are no longer waited for.
+func (sm *ServerManager) drainOutput(ctx context.Context, quietPeriod time.Duration) {
+	if quietPeriod <= 0 {
+		return
+	}
+
+	discarded := 0
+	for {
+		sm.mu.Lock()
+		lines := append(sm.stdoutOutput, sm.stderrOutput...)
+		sm.stdoutOutput = []string{}
+		sm.stderrOutput = []string{}
+		for _, line := range lines {
+			if sm.stalePrompts > 0 && sm.promptStartedPattern.MatchString(strings.TrimSpace(line)) {
+				sm.stalePrompts--
+			}
+		}
+		silentFor := time.Since(sm.lastActivity)
+		sm.mu.Unlock()
+
+		discarded += len(lines)
+
+		// Nothing is buffered and no line was read for the quiet period
+		if len(lines) == 0 && silentFor >= quietPeriod {
+			break
+		}
+
+		wait := quietPeriod
+		if len(lines) == 0 {
+			wait = quietPeriod - silentFor
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Warn("Context done while draining stale output",
+				zap.Int("discardedLines", discarded))
+			return
+		case <-time.After(wait):
+		}
+	}
+
+	if discarded > 0 {
+		logger.Debug("Discarded stale output before sending command",
+			zap.Int("discardedLines", discarded))
+	}
+}
+
+// discardUntilPrompt reads and discards output until the prompts of all timed-out
+// commands have been seen. It returns false if ctx is done before.
+func (sm *ServerManager) discardUntilPrompt(ctx context.Context) bool {
+	for {
+		sm.mu.Lock()
+		lines := append(sm.stdoutOutput, sm.stderrOutput...)
+		sm.stdoutOutput = []string{}
+		sm.stderrOutput = []string{}
+		for _, line := range lines {
+			if sm.stalePrompts > 0 && sm.promptStartedPattern.MatchString(strings.TrimSpace(line)) {
+				sm.stalePrompts--
+			}
+		}
+		stalePrompts := sm.stalePrompts
+		sm.mu.Unlock()
+
+		if stalePrompts == 0 {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// resyncAfterTimeout waits for the late prompt of timed-out commands, discarding
+// their output, so that the next command starts on a quiet session. If srvrmgr
+// stays busy for longer than TimeoutResyncWait, the session is reconnected.
+func (sm *ServerManager) resyncAfterTimeout(command string) {
+	config := sm.GetConfig()
+	if config.TimeoutResyncWait <= 0 {
+		// Late output is skipped by the next command instead
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.TimeoutResyncWait)
+	defer cancel()
+	if sm.discardUntilPrompt(ctx) {
+		logger.Debug("Resynchronized to the srvrmgr prompt after timeout",
+			zap.String("command", command))
+		return
+	}
+
+	if !config.AutoReconnect {
+		logger.Warn("srvrmgr is still busy with a timed-out command, its output is skipped by the next command",
+			zap.String("command", command),
+			zap.Duration("resyncWait", config.TimeoutResyncWait))
+		return
+	}
+
+	logger.Warn("srvrmgr is still busy with a timed-out command, reconnecting",
+		zap.String("command", command),
+		zap.Duration("resyncWait", config.TimeoutResyncWait))
+	sm.startReconnect()
+}
+
+// normalizeCommand trims surrounding whitespace and trailing semicolons from a command
+func normalizeCommand(command string) string {
+	return strings.TrimRight(strings.TrimSpace(command), "; \t")
+}
+
 // getRemainingTimeout gets the remaining time before the context deadline
 func getRemainingTimeout(ctx context.Context) time.Duration {
 	deadline, ok := ctx.Deadline()
@@ -271,19 +510,20 @@ func getRemainingTimeout(ctx context.Context) time.Duration {
 	return time.Until(deadline)
 }
 
-// removeDuplicates removes duplicate strings from a slice
-func removeDuplicates(input []string) []string {
-	// Create a map to track unique strings
-	seen := make(map[string]struct{})
+// removeNoise drops echoes of the command as well as leading and repeated blank
+// lines. Other lines are never de-duplicated, as identical data rows are legitimate.
+func removeNoise(input []string, command string) []string {
+	command = strings.TrimSpace(command)
 	var result []string
 
-	// Iterate through the input slice
-	for _, str := range input {
-		if _, exists := seen[str]; !exists {
-			// If the string is not in the map, add it to result and mark it as seen
-			result = append(result, str)
-			seen[str] = struct{}{}
+	for _, line := range input {
+		if line == command {
+			continue
+		}
+		if line == "" && (len(result) == 0 || result[len(result)-1] == "") {
+			continue
 		}
+		result = append(result, line)
 	}
 
 	return result
@@ -0,0 +1,200 @@
+package servermanager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+func TestLateOutputIsNotAttributedToNextCommand(t *testing.T) {
+	tests := []struct {
+		name                string
+		timeoutResyncWait   time.Duration
+		resyncBeforeCommand bool
+	}{
+		{name: "resync after timeout", timeoutResyncWait: 2 * time.Second},
+		{name: "resync before next command", resyncBeforeCommand: true},
+		{name: "skip prompts while reading"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond("list slow", srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table([]string{"CC_ALIAS"}, []string{"LateComp"}),
+				Delay: time.Second,
+			})
+			fake.Respond("list comp", srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table([]string{"CC_ALIAS"}, []string{"SCCObjMgr_enu"}),
+			})
+
+			config := newTestConfig(fake)
+			config.TimeoutResyncWait = tt.timeoutResyncWait
+			config.ResyncBeforeCommand = tt.resyncBeforeCommand
+			sm := connectTestServerManager(t, config)
+
+			if _, err := sm.SendCommandWithTimeout("list slow", 300*time.Millisecond); err == nil {
+				t.Fatal("slow command did not time out")
+			}
+
+			got, err := sm.SendCommandWithTimeout("list comp", 5*time.Second)
+			if err != nil {
+				t.Fatalf("next command error = %v", err)
+			}
+			output := strings.Join(got, "\n")
+			if strings.Contains(output, "LateComp") {
+				t.Errorf("late output of the timed-out command in the result:\n%s", output)
+			}
+			if !strings.Contains(output, "SCCObjMgr_enu") {
+				t.Errorf("result of the next command is missing:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestRemoveNoise(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{
+			name:  "identical data rows are kept",
+			input: []string{"SV_NAME  TK_COUNT", "-------  --------", "SRV01    5", "SRV01    5"},
+			want:  []string{"SV_NAME  TK_COUNT", "-------  --------", "SRV01    5", "SRV01    5"},
+		},
+		{
+			name:  "command echo is dropped",
+			input: []string{"list tasks", "SV_NAME", "-------", "SRV01"},
+			want:  []string{"SV_NAME", "-------", "SRV01"},
+		},
+		{
+			name:  "leading and repeated blank lines are dropped",
+			input: []string{"", "SV_NAME", "-------", "SRV01", "", "", "SRV02"},
+			want:  []string{"SV_NAME", "-------", "SRV01", "", "SRV02"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeNoise(tt.input, "list tasks"); !slices.Equal(got, tt.want) {
+				t.Errorf("removeNoise() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// frenchTable returns a result table of a French srvrmgr
+func frenchTable(columns []string, rows ...[]string) []string {
+	table := srvrmgrtest.Table(columns, rows...)
+	return append(table[:len(table)-1], fmt.Sprintf("%d lignes retournées.", len(rows)))
+}
+
+func TestLocalizedPromptEndedPattern(t *testing.T) {
+	const frenchEnding = `.*\ ligne(|s)\ retournée(|s)\.`
+
+	tests := []struct {
+		name               string
+		promptEndedPattern string
+		lines              []string
+		wantErr            bool
+	}{
+		{name: "French output with French pattern", promptEndedPattern: frenchEnding, lines: frenchTable([]string{"CC_ALIAS"}, []string{"SCCObjMgr_fra"}, []string{"EAIObjMgr_fra"})},
+		{name: "English output with default pattern", lines: srvrmgrtest.Table([]string{"CC_ALIAS"}, []string{"SCCObjMgr_fra"}, []string{"EAIObjMgr_fra"})},
+		{name: "French output with default pattern", lines: frenchTable([]string{"CC_ALIAS"}, []string{"SCCObjMgr_fra"}, []string{"EAIObjMgr_fra"}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond("list comp", srvrmgrtest.Response{Lines: tt.lines})
+
+			config := newTestConfig(fake)
+			config.PromptEndedPattern = tt.promptEndedPattern
+			sm := connectTestServerManager(t, config)
+
+			got, err := sm.SendCommandWithTimeout("list comp", 2*time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("SendCommandWithTimeout() = %q, want timeout", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendCommandWithTimeout() error = %v", err)
+			}
+			want := []string{"CC_ALIAS", "-------------", "SCCObjMgr_fra", "EAIObjMgr_fra", ""}
+			if !slices.Equal(got, want) {
+				t.Errorf("SendCommandWithTimeout() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLeftoverOutputBeforeCommand(t *testing.T) {
+	columns := []string{"CC_ALIAS", "CP_NUM_RUN_TASKS"}
+	want := srvrmgrtest.Table(columns, []string{"SCCObjMgr_enu", "3"})
+
+	tests := []struct {
+		name                string
+		drainQuietPeriod    time.Duration
+		resyncBeforeCommand bool
+		stalePrompts        int
+		wantLeftover        bool
+	}{
+		{name: "drained before command", drainQuietPeriod: 1500 * time.Millisecond},
+		{name: "discarded until prompt before command", resyncBeforeCommand: true, stalePrompts: 1},
+		{name: "prompt of leftover skipped while reading", stalePrompts: 1},
+		{name: "no drain", wantLeftover: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond("list slow", srvrmgrtest.Response{
+				Lines: srvrmgrtest.Table(columns, []string{"LateComp", "7"}),
+				Delay: time.Second,
+			})
+			fake.Respond("list comp", srvrmgrtest.Response{Lines: want})
+
+			config := newTestConfig(fake)
+			config.DrainQuietPeriod = tt.drainQuietPeriod
+			config.ResyncBeforeCommand = tt.resyncBeforeCommand
+			sm := connectTestServerManager(t, config)
+
+			// A command whose output arrives ahead of the next command's output
+			sm.mu.Lock()
+			sm.stdin.WriteString("list slow\n")
+			err := sm.stdin.Flush()
+			sm.stalePrompts = tt.stalePrompts
+			sm.mu.Unlock()
+			if err != nil {
+				t.Fatalf("writing command: %v", err)
+			}
+
+			got, err := sm.SendCommandWithTimeout("list comp", 5*time.Second)
+			if err != nil {
+				t.Fatalf("SendCommandWithTimeout() error = %v", err)
+			}
+			output := strings.Join(got, "\n")
+			if tt.wantLeftover {
+				if !strings.Contains(output, "LateComp") {
+					t.Errorf("leftover output not in the result without drain:\n%s", output)
+				}
+				return
+			}
+
+			var wantLines []string
+			for _, line := range want[1 : len(want)-1] {
+				wantLines = append(wantLines, strings.TrimSpace(line))
+			}
+			if !slices.Equal(got, wantLines) {
+				t.Errorf("output = %q, want %q", got, wantLines)
+			}
+		})
+	}
+}
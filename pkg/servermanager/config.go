@@ -1,6 +1,15 @@
 package servermanager
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
 
 // Status represents the connection status of the ServerManager
 type Status string
@@ -17,6 +26,37 @@ const (
 	// Default timeout duration
 	DefaultTimeout        = 60 * time.Second
 	DefaultReconnectDelay = 10 * time.Second
+
+	// Default wait for a reconnect before a command that lost its connection is retried
+	DefaultCommandRetryWait = 10 * time.Second
+
+	// Default number of times a command that lost its connection is retried after the reconnect
+	DefaultCommandRetries = 1
+
+	// Default time srvrmgr gets to exit after the exit command before it is killed
+	DefaultExitTimeout = 1 * time.Second
+
+	// Default time srvrmgr gets to finish a timed-out command before the session is reconnected
+	DefaultTimeoutResyncWait = 10 * time.Second
+
+	// Default time srvrmgr must stay silent before a command is sent
+	DefaultDrainQuietPeriod = 100 * time.Millisecond
+
+	// Default patterns of the srvrmgr prompt and of the line ending a result table
+	DefaultPromptPattern      = `srvrmgr(:.*|>)`
+	DefaultPromptEndedPattern = `.*\ row(|s)\ returned\.`
+)
+
+// ConnectionMode selects how srvrmgr connects and so the flags it is started with
+type ConnectionMode string
+
+const (
+	// GatewayMode connects through the Siebel Gateway with -g, -e, -s, -u and -p
+	GatewayMode ConnectionMode = "gateway"
+
+	// DirectMode connects to the application server at ServerAddress without a
+	// gateway. srvrmgr is given the server address with -g in place of the gateway.
+	DirectMode ConnectionMode = "direct"
 )
 
 // BackoffConfig defines the configuration for exponential backoff
@@ -26,6 +66,10 @@ type BackoffConfig struct {
 	Multiplier   float64
 	MaxRetries   int
 	JitterFactor float64
+
+	// Time after which a reconnection cycle gives up, however many retries are
+	// left. Zero means no limit.
+	MaxElapsedTime time.Duration
 }
 
 // Default backoff configuration
@@ -37,6 +81,40 @@ var DefaultBackoffConfig = BackoffConfig{
 	JitterFactor: 0.2,
 }
 
+// Validate reports settings that would make the backoff shrink or never wait
+func (b BackoffConfig) Validate() error {
+	switch {
+	case b.InitialDelay <= 0:
+		return fmt.Errorf("initial backoff delay must be positive, got %v", b.InitialDelay)
+	case b.MaxDelay <= 0:
+		return fmt.Errorf("maximum backoff delay must be positive, got %v", b.MaxDelay)
+	case b.MaxDelay < b.InitialDelay:
+		return fmt.Errorf("maximum backoff delay %v is below the initial delay %v", b.MaxDelay, b.InitialDelay)
+	case b.Multiplier < 1:
+		return fmt.Errorf("backoff multiplier must be at least 1, got %v", b.Multiplier)
+	case b.MaxRetries < 0:
+		return fmt.Errorf("backoff max retries must not be negative, got %d", b.MaxRetries)
+	case b.MaxElapsedTime < 0:
+		return fmt.Errorf("backoff max elapsed time must not be negative, got %v", b.MaxElapsedTime)
+	case b.JitterFactor < 0 || b.JitterFactor >= 1:
+		return fmt.Errorf("backoff jitter must be at least 0 and below 1, got %v", b.JitterFactor)
+	}
+	return nil
+}
+
+// WatchdogConfig defines when an unresponsive srvrmgr process is killed and started again
+type WatchdogConfig struct {
+	// Number of failed heartbeats in a row within Window that trigger a restart.
+	// Zero disables the watchdog.
+	Failures int
+	Window   time.Duration
+
+	// Number of failed restarts in a row after which the process exits with ExitCode,
+	// so the orchestrator can restart the exporter. Zero never exits.
+	MaxFailedRestarts int
+	ExitCode          int
+}
+
 // ServerManagerConfig contains all configuration parameters for ServerManager
 type ServerManagerConfig struct {
 	// Connection parameters
@@ -46,22 +124,272 @@ type ServerManagerConfig struct {
 	User       string
 	Password   string
 
+	// File to read the password from at connect time, takes precedence over Password
+	PasswordFile string
+
+	// How srvrmgr connects, empty means GatewayMode
+	ConnectionMode ConnectionMode
+
+	// Host and port of the application server to connect to in DirectMode
+	ServerAddress string
+
+	// Arguments appended as given after the connection flags, e.g. for the language
+	ExtraArgs []string
+
 	// Path to the srvrmgr executable
 	SrvrmgrPath string
 
+	// Trim surrounding whitespace and trailing semicolons from commands before sending
+	NormalizeCommands bool
+
+	// Discard pending output until srvrmgr stays silent this long before sending a command.
+	// Zero disables draining.
+	DrainQuietPeriod time.Duration
+
+	// Regular expressions matching the srvrmgr prompt and the line ending a result
+	// table, for localized or customized srvrmgr. Empty means the default pattern.
+	PromptPattern      string
+	PromptEndedPattern string
+
+	// How long srvrmgr gets to finish a timed-out command before the session is
+	// reconnected, so that its late output cannot end up in the next result.
+	// Zero leaves the late output to be skipped by the next command.
+	TimeoutResyncWait time.Duration
+
+	// Before each command, read and discard output until the prompts of timed-out
+	// commands have arrived, so the command starts on a clean slate
+	ResyncBeforeCommand bool
+
+	// Append stderr lines to the command output instead of only logging them.
+	// Merged lines end up in the table parsing path and can corrupt metrics.
+	MergeStderr bool
+
+	// How long srvrmgr gets to exit after the exit command before it is killed.
+	// Clean exits release the Siebel session properly. Zero always kills.
+	ExitTimeout time.Duration
+
+	// Commands run in order right after connecting, before the session is used,
+	// e.g. "set ColumnWidth true". A failing command aborts the connection.
+	StartupCommands []string
+
 	// Reconnection settings
 	AutoReconnect  bool
 	ReconnectDelay time.Duration
 
+	// How long a command that lost its connection waits for the reconnect before it
+	// is retried. Zero fails such commands immediately.
+	CommandRetryWait time.Duration
+
+	// How often such a command is retried, each time after a new reconnect. Zero
+	// disables the retry.
+	CommandRetries int
+
 	// Backoff configuration for reconnection attempts
 	BackoffConfig BackoffConfig
+
+	// Watchdog for srvrmgr processes that are alive but stopped responding
+	Watchdog WatchdogConfig
 }
 
 // NewConfig creates a new ServerManagerConfig with default values
 func NewConfig() ServerManagerConfig {
 	return ServerManagerConfig{
-		AutoReconnect:  false,
-		ReconnectDelay: DefaultReconnectDelay,
-		BackoffConfig:  DefaultBackoffConfig,
+		NormalizeCommands:   true,
+		DrainQuietPeriod:    DefaultDrainQuietPeriod,
+		TimeoutResyncWait:   DefaultTimeoutResyncWait,
+		ResyncBeforeCommand: true,
+		PromptPattern:       DefaultPromptPattern,
+		PromptEndedPattern:  DefaultPromptEndedPattern,
+		AutoReconnect:       false,
+		ReconnectDelay:      DefaultReconnectDelay,
+		CommandRetryWait:    DefaultCommandRetryWait,
+		CommandRetries:      DefaultCommandRetries,
+		ExitTimeout:         DefaultExitTimeout,
+		BackoffConfig:       DefaultBackoffConfig,
+	}
+}
+
+// resolvePassword returns the password to connect with. When PasswordFile is set
+// the file is read on every call, so rotated secrets are picked up on reconnect.
+func (c ServerManagerConfig) resolvePassword() (string, error) {
+	if c.PasswordFile == "" {
+		return c.Password, nil
+	}
+
+	data, err := os.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// mode returns the connection mode, GatewayMode if none is set
+func (c ServerManagerConfig) mode() ConnectionMode {
+	if c.ConnectionMode == "" {
+		return GatewayMode
+	}
+	return c.ConnectionMode
+}
+
+// ValidateConnection reports unknown connection modes and connection parameters
+// missing for the mode
+func (c ServerManagerConfig) ValidateConnection() error {
+	var missing []string
+	switch c.mode() {
+	case GatewayMode:
+		if c.ServerAddress != "" {
+			return fmt.Errorf("server address %q is only used in %s mode", c.ServerAddress, DirectMode)
+		}
+		if c.Gateway == "" {
+			missing = append(missing, "gateway")
+		}
+	case DirectMode:
+		if c.Gateway != "" {
+			return fmt.Errorf("gateway %q is not used in %s mode", c.Gateway, DirectMode)
+		}
+		if c.ServerAddress == "" {
+			missing = append(missing, "server address")
+		}
+	default:
+		return fmt.Errorf("unknown connection mode %q, must be %s or %s", c.ConnectionMode, GatewayMode, DirectMode)
+	}
+
+	if c.Enterprise == "" {
+		missing = append(missing, "enterprise")
+	}
+	if c.Server == "" {
+		missing = append(missing, "server")
+	}
+	if c.User == "" {
+		missing = append(missing, "user")
+	}
+	if c.Password == "" && c.PasswordFile == "" {
+		missing = append(missing, "password")
+	}
+	if c.SrvrmgrPath == "" {
+		missing = append(missing, "srvrmgr path")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s mode requires %s", c.mode(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// srvrmgrArgs returns the arguments srvrmgr is started with for the connection mode
+func (c ServerManagerConfig) srvrmgrArgs(password string) []string {
+	address := c.Gateway
+	if c.mode() == DirectMode {
+		address = c.ServerAddress
+	}
+	args := []string{
+		"-g", address,
+		"-e", c.Enterprise,
+		"-s", c.Server,
+		"-u", c.User,
+		"-p", password,
+	}
+	return append(args, c.ExtraArgs...)
+}
+
+// secretArgPattern matches srvrmgr flags whose value is a secret, e.g. -p or /p
+var secretArgPattern = regexp.MustCompile(`(?i)^(-{1,2}|/)(p|pwd|pass|password|secret|token)$`)
+
+// secretKeyPattern matches names of key=value arguments whose value is a secret
+var secretKeyPattern = regexp.MustCompile(`(?i)(pass|pwd|secret|token)`)
+
+// RedactArgs returns srvrmgr arguments safe for logging. The values of password
+// flags and of key=value arguments with a password-like key are masked.
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		redacted[i] = args[i]
+		if key, _, found := strings.Cut(args[i], "="); found && secretKeyPattern.MatchString(key) {
+			redacted[i] = key + "=***"
+			continue
+		}
+		if secretArgPattern.MatchString(args[i]) && i+1 < len(args) {
+			i++
+			redacted[i] = "***"
+		}
+	}
+	return redacted
+}
+
+// ValidatePromptPatterns reports whether the prompt patterns compile
+func (c ServerManagerConfig) ValidatePromptPatterns() error {
+	_, _, err := c.promptPatterns()
+	return err
+}
+
+// promptPatterns compiles the prompt patterns, using the defaults for empty ones
+func (c ServerManagerConfig) promptPatterns() (*regexp.Regexp, *regexp.Regexp, error) {
+	promptPattern := c.PromptPattern
+	if promptPattern == "" {
+		promptPattern = DefaultPromptPattern
+	}
+	promptEndedPattern := c.PromptEndedPattern
+	if promptEndedPattern == "" {
+		promptEndedPattern = DefaultPromptEndedPattern
+	}
+
+	prompt, err := regexp.Compile(promptPattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid prompt pattern %q: %v", promptPattern, err)
+	}
+	promptEnded, err := regexp.Compile(promptEndedPattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid prompt ended pattern %q: %v", promptEndedPattern, err)
+	}
+	return prompt, promptEnded, nil
+}
+
+// ResolveSrvrmgrPath returns the path of the srvrmgr executable. Bare command names
+// are looked up on PATH, paths must point to an executable file.
+func (c ServerManagerConfig) ResolveSrvrmgrPath() (string, error) {
+	if !strings.ContainsAny(c.SrvrmgrPath, "/"+string(os.PathSeparator)) {
+		path, err := exec.LookPath(c.SrvrmgrPath)
+		if err != nil {
+			return "", fmt.Errorf("srvrmgr %q not found on PATH: %v", c.SrvrmgrPath, err)
+		}
+		return path, nil
+	}
+
+	info, err := os.Stat(c.SrvrmgrPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("srvrmgr not found at %s", c.SrvrmgrPath)
+		}
+		return "", fmt.Errorf("srvrmgr at %s cannot be accessed: %v", c.SrvrmgrPath, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("srvrmgr at %s is a directory", c.SrvrmgrPath)
+	}
+	path, err := exec.LookPath(c.SrvrmgrPath)
+	if err != nil {
+		return "", fmt.Errorf("srvrmgr at %s is not executable: %v", c.SrvrmgrPath, err)
+	}
+	return path, nil
+}
+
+// ProbeSrvrmgr runs "srvrmgr /?" to confirm that the executable starts. Its usage
+// output and exit code are not checked, as they differ between Siebel versions,
+// only that it could be executed and exited within the timeout.
+func (c ServerManagerConfig) ProbeSrvrmgr(timeout time.Duration) error {
+	path, err := c.ResolveSrvrmgrPath()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = exec.CommandContext(ctx, path, "/?").Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("srvrmgr at %s did not exit within %v when probed", path, timeout)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("srvrmgr at %s cannot be run: %v", path, err)
 	}
+	return nil
 }
@@ -0,0 +1,258 @@
+package servermanager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolvePassword(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name         string
+		password     string
+		passwordFile string
+		want         string
+		wantErr      bool
+	}{
+		{name: "password without file", password: "secret", want: "secret"},
+		{name: "trailing newline", passwordFile: writeFile("lf", "secret\n"), want: "secret"},
+		{name: "trailing CRLF", passwordFile: writeFile("crlf", "secret\r\n"), want: "secret"},
+		{name: "several trailing newlines", passwordFile: writeFile("lflf", "secret\n\n"), want: "secret"},
+		{name: "spaces are part of the password", passwordFile: writeFile("spaces", " sec ret \n"), want: " sec ret "},
+		{name: "file takes precedence", password: "ignored", passwordFile: writeFile("precedence", "secret"), want: "secret"},
+		{name: "missing file", password: "ignored", passwordFile: filepath.Join(dir, "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ServerManagerConfig{Password: tt.password, PasswordFile: tt.passwordFile}
+			got, err := config.resolvePassword()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got password %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got password %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePasswordRereadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	config := ServerManagerConfig{PasswordFile: path}
+
+	for _, want := range []string{"first", "rotated"} {
+		if err := os.WriteFile(path, []byte(want+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := config.resolvePassword()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("got password %q, want %q", got, want)
+		}
+	}
+}
+
+func TestValidatePromptPatterns(t *testing.T) {
+	tests := []struct {
+		name               string
+		promptPattern      string
+		promptEndedPattern string
+		wantErr            bool
+	}{
+		{name: "defaults"},
+		{name: "French ending", promptEndedPattern: `.*\ ligne(|s)\ retournée(|s)\.`},
+		{name: "custom prompt", promptPattern: `^SIEBEL>`},
+		{name: "invalid prompt", promptPattern: `srvrmgr(:.*`, wantErr: true},
+		{name: "invalid ending", promptEndedPattern: `.*\ row(|s\ returned\.`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ServerManagerConfig{PromptPattern: tt.promptPattern, PromptEndedPattern: tt.promptEndedPattern}
+			if err := config.ValidatePromptPatterns(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePromptPatterns() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveSrvrmgrPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	executable := writeFile("srvrmgr", "#!/bin/sh\nexit 0\n", 0755)
+	notExecutable := writeFile("srvrmgr.txt", "#!/bin/sh\nexit 0\n", 0644)
+	t.Setenv("PATH", dir)
+
+	tests := []struct {
+		name        string
+		srvrmgrPath string
+		want        string
+		wantErr     string
+	}{
+		{name: "executable file", srvrmgrPath: executable, want: executable},
+		{name: "command on PATH", srvrmgrPath: "srvrmgr", want: executable},
+		{name: "missing path", srvrmgrPath: filepath.Join(dir, "missing", "srvrmgr"), wantErr: "srvrmgr not found at"},
+		{name: "command not on PATH", srvrmgrPath: "srvrmgr_missing", wantErr: "not found on PATH"},
+		{name: "non-executable file", srvrmgrPath: notExecutable, wantErr: "is not executable"},
+		{name: "directory", srvrmgrPath: dir, wantErr: "is a directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ServerManagerConfig{SrvrmgrPath: tt.srvrmgrPath}.ResolveSrvrmgrPath()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveSrvrmgrPath() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveSrvrmgrPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveSrvrmgrPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeSrvrmgr(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name        string
+		srvrmgrPath string
+		wantErr     string
+	}{
+		{name: "usage exits 0", srvrmgrPath: writeFile("ok", "#!/bin/sh\necho usage\n", 0755)},
+		{name: "usage exits non-zero", srvrmgrPath: writeFile("usage", "#!/bin/sh\necho usage\nexit 1\n", 0755)},
+		{name: "missing path", srvrmgrPath: filepath.Join(dir, "missing"), wantErr: "srvrmgr not found at"},
+		{name: "non-executable file", srvrmgrPath: writeFile("plain", "#!/bin/sh\n", 0644), wantErr: "is not executable"},
+		{name: "not a program", srvrmgrPath: writeFile("garbage", "\x7fELF garbage", 0755), wantErr: "cannot be run"},
+		{name: "hangs", srvrmgrPath: writeFile("hang", "#!/bin/sh\nexec sleep 10\n", 0755), wantErr: "did not exit within"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ServerManagerConfig{SrvrmgrPath: tt.srvrmgrPath}.ProbeSrvrmgr(500 * time.Millisecond)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ProbeSrvrmgr() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ProbeSrvrmgr() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConnection(t *testing.T) {
+	gateway := ServerManagerConfig{
+		Gateway:     "gateway:2320",
+		Enterprise:  "SBA_81",
+		Server:      "SRV01",
+		User:        "SADMIN",
+		Password:    "secret",
+		SrvrmgrPath: "/siebel/bin/srvrmgr",
+	}
+	direct := gateway
+	direct.ConnectionMode = DirectMode
+	direct.Gateway = ""
+	direct.ServerAddress = "srv01:2321"
+
+	tests := []struct {
+		name      string
+		config    ServerManagerConfig
+		configure func(config *ServerManagerConfig)
+		wantErr   string
+	}{
+		{name: "gateway mode", config: gateway},
+		{name: "gateway mode with password file", config: gateway, configure: func(c *ServerManagerConfig) { c.Password, c.PasswordFile = "", "/run/secrets/siebel" }},
+		{name: "gateway mode without gateway", config: gateway, configure: func(c *ServerManagerConfig) { c.Gateway = "" }, wantErr: "gateway mode requires gateway"},
+		{name: "gateway mode with server address", config: gateway, configure: func(c *ServerManagerConfig) { c.ServerAddress = "srv01:2321" }, wantErr: "only used in direct mode"},
+		{name: "gateway mode without credentials", config: gateway, configure: func(c *ServerManagerConfig) { c.User, c.Password = "", "" }, wantErr: "gateway mode requires user, password"},
+		{name: "direct mode", config: direct},
+		{name: "direct mode without server address", config: direct, configure: func(c *ServerManagerConfig) { c.ServerAddress = "" }, wantErr: "direct mode requires server address"},
+		{name: "direct mode with gateway", config: direct, configure: func(c *ServerManagerConfig) { c.Gateway = "gateway:2320" }, wantErr: "not used in direct mode"},
+		{name: "direct mode without enterprise and server", config: direct, configure: func(c *ServerManagerConfig) { c.Enterprise, c.Server = "", "" }, wantErr: "direct mode requires enterprise, server"},
+		{name: "unknown mode", config: gateway, configure: func(c *ServerManagerConfig) { c.ConnectionMode = "tunnel" }, wantErr: `unknown connection mode "tunnel"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if tt.configure != nil {
+				tt.configure(&config)
+			}
+			err := config.ValidateConnection()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateConnection() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateConnection() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "password flag", args: []string{"-u", "SADMIN", "-p", "secret"}, want: []string{"-u", "SADMIN", "-p", "***"}},
+		{name: "Windows style flag", args: []string{"/u", "SADMIN", "/P", "secret"}, want: []string{"/u", "SADMIN", "/P", "***"}},
+		{name: "long flag", args: []string{"--password", "secret", "-l", "enu"}, want: []string{"--password", "***", "-l", "enu"}},
+		{name: "key value", args: []string{"DBPassword=secret", "api_token=abc", "pwd=x", "Secret=y", "lang=enu"}, want: []string{"DBPassword=***", "api_token=***", "pwd=***", "Secret=***", "lang=enu"}},
+		{name: "password flag without value", args: []string{"-l", "enu", "-p"}, want: []string{"-l", "enu", "-p"}},
+		{name: "no secrets", args: []string{"-l", "enu", "-k", "|"}, want: []string{"-l", "enu", "-k", "|"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactArgs(tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("RedactArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
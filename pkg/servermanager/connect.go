@@ -2,9 +2,11 @@ package servermanager
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
+	"regexp"
 	"strings"
 	"time"
 
@@ -12,6 +14,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// killWait is how long the srvrmgr process and its output readers get to end
+// after the process was killed
+const killWait = 1 * time.Second
+
 // detectConnectionError analyzes error output to determine if it indicates a connection issue
 func detectConnectionError(errorLines []string) (bool, string) {
 	// Common error patterns that indicate connection failures
@@ -64,22 +70,43 @@ func (sm *ServerManager) connect() error {
 	sm.mu.Unlock()
 
 	logger.Info("Connecting to Siebel Server Manager",
+		zap.String("mode", string(config.mode())),
 		zap.String("gateway", config.Gateway),
+		zap.String("serverAddress", config.ServerAddress),
 		zap.String("enterprise", config.Enterprise),
 		zap.String("server", config.Server),
 		zap.String("user", config.User),
 		zap.String("srvrmgrPath", config.SrvrmgrPath))
 
-	args := []string{
-		"-g", config.Gateway,
-		"-e", config.Enterprise,
-		"-s", config.Server,
-		"-u", config.User,
-		"-p", config.Password,
+	if err := config.ValidateConnection(); err != nil {
+		logger.Error("Invalid connection parameters", zap.Error(err))
+		sm.setStatus(ConnectionError)
+		return err
+	}
+
+	srvrmgrPath, err := config.ResolveSrvrmgrPath()
+	if err != nil {
+		logger.Error("Cannot start srvrmgr", zap.Error(err))
+		sm.setStatus(ConnectionError)
+		return err
+	}
+
+	password, err := config.resolvePassword()
+	if err != nil {
+		logger.Error("Failed to read password file",
+			zap.String("passwordFile", config.PasswordFile),
+			zap.Error(err))
+		sm.setStatus(ConnectionError)
+		return fmt.Errorf("password file error: %v", err)
 	}
 
+	args := config.srvrmgrArgs(password)
+	logger.Debug("Starting srvrmgr",
+		zap.String("path", srvrmgrPath),
+		zap.Strings("args", RedactArgs(args)))
+
 	sm.mu.Lock()
-	sm.cmd = exec.Command(config.SrvrmgrPath, args...)
+	sm.cmd = exec.Command(srvrmgrPath, args...)
 	sm.mu.Unlock()
 
 	logger.Debug("Creating stdin pipe")
@@ -112,6 +139,7 @@ func (sm *ServerManager) connect() error {
 	sm.stderr = bufio.NewScanner(stderrPipe)
 	sm.stdoutOutput = []string{}
 	sm.stderrOutput = []string{}
+	sm.stalePrompts = 0
 	sm.mu.Unlock()
 
 	logger.Debug("Starting srvrmgr process")
@@ -155,6 +183,13 @@ func (sm *ServerManager) connect() error {
 		zap.Int("stderrLines", stderrLines),
 		zap.Int("stdoutLines", stdoutLines))
 
+	// The banner is printed on start, before the first prompt, and is refreshed on
+	// every connect as a reconnect may start a different srvrmgr
+	sm.version, sm.build = parseVersion(sm.stdoutOutput)
+	logger.Debug("Parsed srvrmgr version",
+		zap.String("version", sm.version),
+		zap.String("build", sm.build))
+
 	if stderrLines > 0 {
 		// Check stderr for connection errors using the new function
 		hasError, errorMsg := detectConnectionError(sm.stderrOutput)
@@ -168,8 +203,20 @@ func (sm *ServerManager) connect() error {
 		}
 	}
 
+	sm.mu.Unlock()
+
+	// Prepare the session before it is used, a failure leaves it unusable
+	if err := sm.runStartupCommands(config); err != nil {
+		logger.Error("Startup command failed, aborting connection", zap.Error(err))
+		sm.cleanupProcess()
+		sm.setStatus(ConnectionError)
+		return err
+	}
+
 	// Set status to Connected if no errors occurred
+	sm.mu.Lock()
 	sm.status = Connected
+	sm.generation++
 	sm.lastActivity = time.Now()
 	sm.mu.Unlock()
 
@@ -183,6 +230,39 @@ func (sm *ServerManager) connect() error {
 	return nil
 }
 
+// startupCommandErrorPattern matches srvrmgr error output, e.g. "SBL-ADM-60070: Error reported on server"
+var startupCommandErrorPattern = regexp.MustCompile(`(?i)SBL-[A-Z]+-\d+|^invalid|^error|\bnot recognized\b`)
+
+// runStartupCommands sends the configured startup commands in order while the session is connecting
+func (sm *ServerManager) runStartupCommands(config ServerManagerConfig) error {
+	for i, command := range config.StartupCommands {
+		if config.NormalizeCommands {
+			command = normalizeCommand(command)
+		}
+		if command == "" {
+			continue
+		}
+
+		logger.Info("Running startup command",
+			zap.Int("index", i),
+			zap.String("command", command))
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		output, err := sm.sendCommandInStatus(ctx, command, Connecting)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("startup command %q: %v", command, err)
+		}
+
+		for _, line := range output {
+			if startupCommandErrorPattern.MatchString(strings.TrimSpace(line)) {
+				return fmt.Errorf("startup command %q: %s", command, line)
+			}
+		}
+	}
+	return nil
+}
+
 // Disconnect terminates the srvrmgr shell
 func (sm *ServerManager) Disconnect() error {
 	sm.mu.Lock()
@@ -215,34 +295,31 @@ func (sm *ServerManager) Disconnect() error {
 
 	logger.Info("Disconnecting from Siebel Server Manager", zap.String("previousStatus", string(currentStatus)))
 
-	// First try to send exit command with very short timeout
-	exitSuccessful := false
-	if cmd != nil && cmd.Process != nil {
-		logger.Debug("Attempting graceful exit via exit command")
+	// First ask srvrmgr to exit, so it can release its Siebel session properly
+	var exitWaitChan chan error
+	exitTimeout := sm.GetConfig().ExitTimeout
+	if cmd != nil && cmd.Process != nil && exitTimeout > 0 {
+		logger.Debug("Attempting graceful exit via exit command",
+			zap.Duration("exitTimeout", exitTimeout))
 
-		// Try to send exit command with short timeout
-		_, err := sm.SendCommandWithTimeout("exit", 1*time.Second)
-		if err != nil {
+		// The status is Disconnecting already, so the command is written directly.
+		// srvrmgr shows no prompt after exit, the process ending is the response.
+		if err := sm.writeStdin("exit"); err != nil {
 			logger.Debug("Exit command failed (continuing with kill)", zap.Error(err))
 		} else {
-			logger.Debug("Exit command sent successfully, waiting briefly for termination")
-			exitSuccessful = true
-
-			// Give the process a brief moment to exit gracefully
-			exitWaitChan := make(chan struct{})
+			exitWaitChan = make(chan error, 1)
 			go func() {
-				cmd.Wait()
-				close(exitWaitChan)
+				exitWaitChan <- cmd.Wait()
 			}()
 
-			// Wait up to 1 second for graceful exit
 			select {
-			case <-exitWaitChan:
-				logger.Debug("Process exited gracefully after exit command")
+			case err := <-exitWaitChan:
+				logProcessExit(err)
 				sm.setStatus(Disconnected)
 				return nil
-			case <-time.After(1 * time.Second):
-				logger.Debug("Process did not exit after exit command, proceeding to kill")
+			case <-time.After(exitTimeout):
+				logger.Debug("Process did not exit after exit command, proceeding to kill",
+					zap.Duration("exitTimeout", exitTimeout))
 			}
 		}
 	}
@@ -273,33 +350,20 @@ func (sm *ServerManager) Disconnect() error {
 	select {
 	case <-outputWaitChan:
 		logger.Debug("Output readers completed successfully")
-	case <-time.After(1 * time.Second):
+	case <-time.After(killWait):
 		logger.Warn("Timed out waiting for output readers to complete")
 	}
 
 	// If we previously tried an exit command and are still here,
 	// let's wait for the process to finish
-	if exitSuccessful && cmd != nil {
+	if exitWaitChan != nil {
 		logger.Debug("Waiting for srvrmgr process to exit after kill signal")
-		waitChan := make(chan error, 1)
-		go func() {
-			waitChan <- cmd.Wait()
-		}()
-
 		select {
-		case err := <-waitChan:
-			if err != nil {
-				// Don't fail on expected exit errors (process killed)
-				if !strings.Contains(err.Error(), "process already finished") &&
-					!strings.Contains(err.Error(), "signal: killed") {
-					logger.Warn("Error waiting for srvrmgr process to exit", zap.Error(err))
-				}
-				logger.Debug("srvrmgr process exited with expected error", zap.Error(err))
-			} else {
-				logger.Debug("srvrmgr process exited cleanly")
-			}
-		case <-time.After(1 * time.Second):
-			logger.Warn("Timed out waiting for srvrmgr process to exit after kill")
+		case err := <-exitWaitChan:
+			logProcessExit(err)
+		case <-time.After(killWait):
+			logger.Warn("Timed out waiting for srvrmgr process to exit after kill",
+				zap.Duration("wait", killWait))
 		}
 	}
 
@@ -308,6 +372,21 @@ func (sm *ServerManager) Disconnect() error {
 	return nil
 }
 
+// logProcessExit logs how the srvrmgr process ended. Being killed or having
+// finished already is expected while disconnecting, other errors are not.
+func logProcessExit(err error) {
+	if err == nil {
+		logger.Debug("srvrmgr process exited cleanly")
+		return
+	}
+	if !strings.Contains(err.Error(), "process already finished") &&
+		!strings.Contains(err.Error(), "signal: killed") {
+		logger.Warn("Error waiting for srvrmgr process to exit", zap.Error(err))
+		return
+	}
+	logger.Debug("srvrmgr process exited with expected error", zap.Error(err))
+}
+
 // EnableAutoReconnect enables automatic reconnection
 func (sm *ServerManager) EnableAutoReconnect(delay time.Duration) {
 	sm.mu.Lock()
@@ -373,3 +452,17 @@ func (sm *ServerManager) cleanupProcess() {
 	sm.reconnectWg.Wait()
 	logger.Debug("Process cleanup completed")
 }
+
+// writeStdin writes a line to srvrmgr without waiting for a response
+func (sm *ServerManager) writeStdin(line string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.stdin == nil {
+		return errors.New("stdin is not open")
+	}
+	if _, err := sm.stdin.WriteString(line + "\n"); err != nil {
+		return err
+	}
+	return sm.stdin.Flush()
+}
@@ -0,0 +1,178 @@
+package servermanager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+func TestStartupCommands(t *testing.T) {
+	failed := srvrmgrtest.Response{Lines: []string{"", "SBL-ADM-60070: Error reported on server 'SRV02' follows:", "", "0 rows returned."}}
+
+	tests := []struct {
+		name       string
+		commands   []string
+		responses  map[string]srvrmgrtest.Response
+		wantSent   []string
+		wantErr    string
+		wantStatus Status
+	}{
+		{
+			name:       "commands sent in order",
+			commands:   []string{"set server SRV01", "configure list comp show CC_ALIAS", "spool off"},
+			wantSent:   []string{"set server SRV01", "configure list comp show CC_ALIAS", "spool off"},
+			wantStatus: Connected,
+		},
+		{
+			name:       "empty commands skipped",
+			commands:   []string{"", "set server SRV01", "  "},
+			wantSent:   []string{"set server SRV01"},
+			wantStatus: Connected,
+		},
+		{
+			name:       "failing command aborts the connection",
+			commands:   []string{"set server SRV01", "set server SRV02", "spool off"},
+			responses:  map[string]srvrmgrtest.Response{"set server SRV02": failed},
+			wantSent:   []string{"set server SRV01", "set server SRV02"},
+			wantErr:    `startup command "set server SRV02": SBL-ADM-60070`,
+			wantStatus: ConnectionError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			for command, response := range tt.responses {
+				fake.Respond(command, response)
+			}
+			config := newTestConfig(fake)
+			config.StartupCommands = tt.commands
+
+			sm := NewServerManager(config)
+			err := sm.Connect()
+			t.Cleanup(func() {
+				sm.Disconnect()
+			})
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Connect() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Connect() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := sm.GetStatus(); got != tt.wantStatus {
+				t.Errorf("GetStatus() = %s, want %s", got, tt.wantStatus)
+			}
+
+			sent := slices.DeleteFunc(fake.Commands(), func(command string) bool { return command == "exit" })
+			if !slices.Equal(sent, tt.wantSent) {
+				t.Errorf("sent commands = %q, want %q", sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestConnectInvalidSrvrmgrPath(t *testing.T) {
+	dir := t.TempDir()
+	notExecutable := filepath.Join(dir, "srvrmgr")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		srvrmgrPath string
+		wantErr     string
+	}{
+		{name: "missing path", srvrmgrPath: filepath.Join(dir, "missing"), wantErr: "srvrmgr not found at " + filepath.Join(dir, "missing")},
+		{name: "non-executable file", srvrmgrPath: notExecutable, wantErr: "srvrmgr at " + notExecutable + " is not executable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			config := newTestConfig(fake)
+			config.SrvrmgrPath = tt.srvrmgrPath
+
+			sm := NewServerManager(config)
+			err := sm.Connect()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Connect() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := sm.GetStatus(); got != ConnectionError {
+				t.Errorf("GetStatus() = %s, want %s", got, ConnectionError)
+			}
+			if got := fake.Starts(); got != 0 {
+				t.Errorf("srvrmgr started %d times, want 0", got)
+			}
+		})
+	}
+}
+
+func TestConnectionModeArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(config *ServerManagerConfig)
+		want      []string
+	}{
+		{
+			name: "gateway mode",
+			want: []string{"-g", "gateway:2320", "-e", "SBA_81", "-s", "SRV01", "-u", "SADMIN", "-p", "secret"},
+		},
+		{
+			name: "gateway mode by default",
+			configure: func(config *ServerManagerConfig) {
+				config.ConnectionMode = ""
+			},
+			want: []string{"-g", "gateway:2320", "-e", "SBA_81", "-s", "SRV01", "-u", "SADMIN", "-p", "secret"},
+		},
+		{
+			name: "direct mode",
+			configure: func(config *ServerManagerConfig) {
+				config.ConnectionMode = DirectMode
+				config.Gateway = ""
+				config.ServerAddress = "srv01:2321"
+			},
+			want: []string{"-g", "srv01:2321", "-e", "SBA_81", "-s", "SRV01", "-u", "SADMIN", "-p", "secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			config := newTestConfig(fake)
+			if tt.configure != nil {
+				tt.configure(&config)
+			}
+			connectTestServerManager(t, config)
+
+			args := fake.Args()
+			if len(args) != 1 {
+				t.Fatalf("srvrmgr started %d times, want 1", len(args))
+			}
+			if !slices.Equal(args[0], tt.want) {
+				t.Errorf("srvrmgr args = %q, want %q", args[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestExtraArgs(t *testing.T) {
+	fake := srvrmgrtest.New(t)
+	config := newTestConfig(fake)
+	config.ExtraArgs = []string{"-l", "enu", "-k", "|"}
+	connectTestServerManager(t, config)
+
+	want := []string{"-g", "gateway:2320", "-e", "SBA_81", "-s", "SRV01", "-u", "SADMIN", "-p", "secret", "-l", "enu", "-k", "|"}
+	args := fake.Args()
+	if len(args) != 1 {
+		t.Fatalf("srvrmgr started %d times, want 1", len(args))
+	}
+	if !slices.Equal(args[0], want) {
+		t.Errorf("srvrmgr args = %q, want %q", args[0], want)
+	}
+}
@@ -0,0 +1,48 @@
+package servermanager
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+func TestMain(m *testing.M) {
+	srvrmgrtest.Main()
+	logger.Init(logger.ErrorLevel)
+	os.Exit(m.Run())
+}
+
+// newTestConfig returns a configuration for the fake srvrmgr with short waits
+func newTestConfig(fake *srvrmgrtest.Fake) ServerManagerConfig {
+	config := NewConfig()
+	config.Gateway = "gateway:2320"
+	config.Enterprise = "SBA_81"
+	config.Server = "SRV01"
+	config.User = "SADMIN"
+	config.Password = "secret"
+	config.SrvrmgrPath = fake.Path
+	config.TimeoutResyncWait = 2 * time.Second
+	return config
+}
+
+// connectTestServerManager connects to the fake srvrmgr and disconnects at the end of
+// the test, once a reconnection started by the test has finished
+func connectTestServerManager(t *testing.T, config ServerManagerConfig) *ServerManager {
+	t.Helper()
+	sm := NewServerManager(config)
+	if err := sm.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() {
+		// A reconnection still running would start srvrmgr after the fake's
+		// directory is removed
+		if !waitFor(t, 30*time.Second, func() bool { return !sm.IsReconnecting() }) {
+			t.Errorf("reconnection still running at the end of the test")
+		}
+		sm.Disconnect()
+	})
+	return sm
+}
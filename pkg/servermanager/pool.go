@@ -0,0 +1,228 @@
+package servermanager
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Pool is a fixed-size set of srvrmgr sessions to the same server, used to run
+// independent commands in parallel. Sessions are opened on first use and, with an
+// idle timeout, closed again when unused, like the connections of database/sql.
+type Pool struct {
+	primary     *ServerManager
+	config      ServerManagerConfig
+	idleTimeout time.Duration
+
+	// slots limits the number of sessions in use at the same time
+	slots chan struct{}
+
+	mu       sync.Mutex
+	idle     []idleSession
+	sessions []*ServerManager
+	closed   bool
+
+	// Acquires that waited for a free session, their total wait, and sessions
+	// closed for being idle
+	waitCount    uint64
+	waitDuration time.Duration
+	idleClosed   uint64
+
+	stopReaper chan struct{}
+}
+
+// idleSession is a session not in use and since when
+type idleSession struct {
+	sm    *ServerManager
+	since time.Time
+}
+
+// PoolStats describes the sessions of a pool
+type PoolStats struct {
+	MaxOpen      int           // Maximum number of sessions
+	Open         int           // Sessions opened, including the primary session
+	Idle         int           // Open sessions not in use
+	InUse        int           // Sessions in use
+	WaitCount    uint64        // Acquires that had to wait for a free session
+	WaitDuration time.Duration // Total time spent waiting for a free session
+	IdleClosed   uint64        // Sessions closed because they were idle longer than the idle timeout
+}
+
+// NewPool creates a pool of up to size sessions. The primary session is part of
+// the pool but stays owned by the caller and is neither reaped nor disconnected
+// by Close. Additional sessions idle longer than idleTimeout are disconnected to
+// free Siebel session slots, zero keeps them open.
+func NewPool(primary *ServerManager, size int, idleTimeout time.Duration) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	logger.Debug("Creating srvrmgr session pool",
+		zap.String("server", primary.GetConfig().Server),
+		zap.Int("size", size),
+		zap.Duration("idleTimeout", idleTimeout))
+
+	p := &Pool{
+		primary:     primary,
+		config:      primary.GetConfig(),
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, size),
+		idle:        []idleSession{{sm: primary, since: time.Now()}},
+		sessions:    []*ServerManager{primary},
+		stopReaper:  make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go p.reapIdle()
+	}
+	return p
+}
+
+// Size returns the maximum number of sessions of the pool
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// Stats returns the current usage of the pool
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{
+		MaxOpen:      cap(p.slots),
+		Open:         len(p.sessions),
+		Idle:         len(p.idle),
+		InUse:        len(p.sessions) - len(p.idle),
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
+		IdleClosed:   p.idleClosed,
+	}
+}
+
+// Acquire returns a connected session, waiting while all sessions are in use.
+// A new session is opened if none is idle. Every acquired session must be
+// given back with Release.
+func (p *Pool) Acquire() (*ServerManager, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		waitStart := time.Now()
+		p.slots <- struct{}{}
+		p.mu.Lock()
+		p.waitCount++
+		p.waitDuration += time.Since(waitStart)
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, fmt.Errorf("session pool is closed")
+	}
+
+	var sm *ServerManager
+	if n := len(p.idle); n > 0 {
+		// The most recently used session is taken, so that the others become idle long enough to be reaped
+		sm = p.idle[n-1].sm
+		p.idle = p.idle[:n-1]
+	} else {
+		sm = NewServerManager(p.config)
+		p.sessions = append(p.sessions, sm)
+		logger.Debug("Opening additional pooled srvrmgr session",
+			zap.String("server", p.config.Server),
+			zap.Int("sessions", len(p.sessions)))
+	}
+	p.mu.Unlock()
+
+	// Sessions that lost their connection and do not reconnect on their own are reopened here
+	if status := sm.GetStatus(); status == Disconnected || status == ConnectionError {
+		if err := sm.Connect(); err != nil {
+			p.Release(sm)
+			return nil, fmt.Errorf("pooled session connect error: %v", err)
+		}
+	}
+
+	return sm, nil
+}
+
+// Release gives a session back to the pool
+func (p *Pool) Release(sm *ServerManager) {
+	p.mu.Lock()
+	p.idle = append(p.idle, idleSession{sm: sm, since: time.Now()})
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// reapIdle periodically disconnects sessions idle longer than the idle timeout until the pool is closed
+func (p *Pool) reapIdle() {
+	ticker := time.NewTicker(max(p.idleTimeout/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopReaper:
+			return
+		case now := <-ticker.C:
+			for _, sm := range p.takeExpired(now) {
+				logger.Debug("Closing idle pooled srvrmgr session",
+					zap.String("server", p.config.Server),
+					zap.Duration("idleTimeout", p.idleTimeout))
+				if err := sm.Disconnect(); err != nil {
+					logger.Warn("Error disconnecting idle pooled session", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// takeExpired removes the sessions idle longer than the idle timeout from the pool
+// and returns them. The primary session is never removed.
+func (p *Pool) takeExpired(now time.Time) []*ServerManager {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+
+	var expired []*ServerManager
+	idle := p.idle[:0]
+	for _, s := range p.idle {
+		if s.sm != p.primary && now.Sub(s.since) > p.idleTimeout {
+			expired = append(expired, s.sm)
+			continue
+		}
+		idle = append(idle, s)
+	}
+	p.idle = idle
+
+	for _, sm := range expired {
+		p.sessions = slices.DeleteFunc(p.sessions, func(s *ServerManager) bool { return s == sm })
+	}
+	p.idleClosed += uint64(len(expired))
+	return expired
+}
+
+// Close disconnects all sessions opened by the pool. The primary session is left as is.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		close(p.stopReaper)
+	}
+	p.closed = true
+	sessions := p.sessions
+	p.mu.Unlock()
+
+	for _, sm := range sessions {
+		if sm == p.primary {
+			continue
+		}
+		if err := sm.Disconnect(); err != nil {
+			logger.Warn("Error disconnecting pooled session", zap.Error(err))
+		}
+	}
+}
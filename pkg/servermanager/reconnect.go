@@ -2,7 +2,8 @@ package servermanager
 
 import (
 	"context"
-	"math/rand"
+	"math/rand/v2"
+	"os"
 	"time"
 
 	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
@@ -15,20 +16,23 @@ func (sm *ServerManager) startHeartbeatChecker() {
 	if sm.heartbeatTicker != nil {
 		logger.Debug("Stopping existing heartbeat ticker")
 		sm.heartbeatTicker.Stop()
+		sm.heartbeatTicker = nil
 	}
 
-	autoReconnect := sm.config.AutoReconnect
-	sm.mu.Unlock()
-
-	if !autoReconnect {
+	if !sm.config.AutoReconnect {
+		sm.mu.Unlock()
 		logger.Debug("Auto-reconnect disabled, not starting heartbeat checker")
 		return
 	}
 
-	logger.Info("Starting heartbeat checker")
+	// Start a new heartbeat ticker (every 30 seconds). The goroutine gets the
+	// ticker and stop channel as locals, Disconnect replaces both under the lock.
+	ticker := time.NewTicker(30 * time.Second)
+	sm.heartbeatTicker = ticker
+	stopCh := sm.stopReconnect
+	sm.mu.Unlock()
 
-	// Start a new heartbeat ticker (every 30 seconds)
-	sm.heartbeatTicker = time.NewTicker(30 * time.Second)
+	logger.Info("Starting heartbeat checker")
 
 	go func() {
 		logger.Debug("Heartbeat checker goroutine started")
@@ -36,25 +40,31 @@ func (sm *ServerManager) startHeartbeatChecker() {
 
 		for {
 			select {
-			case <-sm.heartbeatTicker.C:
+			case <-ticker.C:
 				heartbeatCount++
 				logger.Debug("Performing heartbeat check", zap.Int("count", heartbeatCount))
 
 				// Check if we need to perform a heartbeat
 				if !sm.checkConnectionHealth() {
 					logger.Warn("Connection health check failed", zap.Int("heartbeatCount", heartbeatCount))
-					// Try to reconnect if the connection is unhealthy
-					sm.tryReconnect()
+					if sm.recordHeartbeatFailure(time.Now()) {
+						// Reconnecting did not help, start over with a fresh process
+						sm.watchdogRestart()
+					} else {
+						// Try to reconnect if the connection is unhealthy
+						sm.tryReconnect()
+					}
 				} else {
+					sm.mu.Lock()
+					sm.heartbeatFailures = nil
+					sm.mu.Unlock()
 					logger.Debug("Connection health check passed", zap.Int("heartbeatCount", heartbeatCount))
 				}
-			case <-sm.stopReconnect:
+			case <-stopCh:
 				// Stop the heartbeat ticker when reconnection is disabled
 				logger.Debug("Heartbeat checker received stop signal")
-				if sm.heartbeatTicker != nil {
-					sm.heartbeatTicker.Stop()
-					logger.Debug("Heartbeat ticker stopped")
-				}
+				ticker.Stop()
+				logger.Debug("Heartbeat ticker stopped")
 				logger.Debug("Heartbeat checker goroutine exiting")
 				return
 			}
@@ -118,43 +128,149 @@ func (sm *ServerManager) checkConnectionHealth() bool {
 	return true
 }
 
-// tryReconnect attempts to reconnect to the server with exponential backoff
-func (sm *ServerManager) tryReconnect() {
+// recordHeartbeatFailure remembers a failed heartbeat and reports whether the
+// watchdog threshold is reached. Failures during a reconnection cycle are not
+// counted, the watchdog only acts on sessions the reconnect logic considers fine.
+func (sm *ServerManager) recordHeartbeatFailure(now time.Time) bool {
 	sm.mu.Lock()
-	if sm.isReconnecting || !sm.config.AutoReconnect {
-		alreadyReconnecting := sm.isReconnecting
-		autoReconnectEnabled := sm.config.AutoReconnect
-		sm.mu.Unlock()
+	defer sm.mu.Unlock()
+
+	watchdog := sm.config.Watchdog
+	if watchdog.Failures <= 0 || sm.isReconnecting {
+		return false
+	}
+
+	sm.heartbeatFailures = append(sm.heartbeatFailures, now)
 
-		if alreadyReconnecting {
-			logger.Debug("Reconnection already in progress, skipping new attempt")
-		} else if !autoReconnectEnabled {
-			logger.Debug("Auto-reconnect disabled, skipping reconnection attempt")
+	// Only failures within the window count
+	if watchdog.Window > 0 {
+		for len(sm.heartbeatFailures) > 0 && now.Sub(sm.heartbeatFailures[0]) > watchdog.Window {
+			sm.heartbeatFailures = sm.heartbeatFailures[1:]
 		}
-		return
 	}
 
-	// Add a small delay before reconnecting
-	time.Sleep(500 * time.Millisecond)
+	return len(sm.heartbeatFailures) >= watchdog.Failures
+}
 
+// watchdogRestart kills the srvrmgr process and connects with a fresh one. When
+// restarting keeps failing, the exporter exits if configured to do so.
+func (sm *ServerManager) watchdogRestart() {
+	sm.mu.Lock()
+	// The restart counts as reconnection cycle, a pipe error meanwhile must not start another one
+	if sm.isReconnecting {
+		sm.mu.Unlock()
+		logger.Debug("Reconnection already in progress, skipping watchdog restart")
+		return
+	}
 	sm.isReconnecting = true
+	watchdog := sm.config.Watchdog
+	failures := len(sm.heartbeatFailures)
+	sm.heartbeatFailures = nil
+	sm.watchdogRestarts++
+	sm.mu.Unlock()
+
+	logger.Warn("Watchdog restarting unresponsive srvrmgr process",
+		zap.String("server", sm.GetConfig().Server),
+		zap.Int("failedHeartbeats", failures),
+		zap.Duration("window", watchdog.Window))
+
+	sm.cleanupProcess()
+
+	// The session may still be marked Connected, connect refuses to start then
+	sm.mu.Lock()
 	sm.status = Reconnecting
-	backoffConfig := sm.config.BackoffConfig
 	sm.mu.Unlock()
 
-	logger.Info("Initiating reconnection with exponential backoff",
-		zap.Duration("initialDelay", backoffConfig.InitialDelay),
-		zap.Duration("maxDelay", backoffConfig.MaxDelay),
-		zap.Float64("multiplier", backoffConfig.Multiplier),
-		zap.Int("maxRetries", backoffConfig.MaxRetries))
+	err := sm.connect()
+
+	sm.mu.Lock()
+	sm.isReconnecting = false
+	if err == nil {
+		sm.failedRestarts = 0
+	} else {
+		sm.failedRestarts++
+	}
+	failedRestarts := sm.failedRestarts
+	sm.mu.Unlock()
+
+	if err == nil {
+		logger.Info("Watchdog restarted srvrmgr process successfully")
+		return
+	}
+
+	logger.Error("Watchdog restart of srvrmgr process failed",
+		zap.Int("failedRestarts", failedRestarts),
+		zap.Int("maxFailedRestarts", watchdog.MaxFailedRestarts),
+		zap.Error(err))
 
-	// Create a new stopReconnect channel
+	if watchdog.MaxFailedRestarts > 0 && failedRestarts >= watchdog.MaxFailedRestarts {
+		logger.Error("Watchdog giving up, exiting",
+			zap.Int("exitCode", watchdog.ExitCode))
+		_ = logger.Sync()
+		os.Exit(watchdog.ExitCode)
+	}
+}
+
+// tryReconnect attempts to reconnect to the server with exponential backoff
+func (sm *ServerManager) tryReconnect() {
+	if stopCh, backoffConfig, claimed := sm.claimReconnect(); claimed {
+		sm.reconnect(stopCh, backoffConfig)
+	}
+}
+
+// startReconnect claims the reconnection before returning and runs it in the
+// background. A command that lost its connection sees the reconnection in
+// progress as soon as it waits for it.
+func (sm *ServerManager) startReconnect() {
+	if stopCh, backoffConfig, claimed := sm.claimReconnect(); claimed {
+		go sm.reconnect(stopCh, backoffConfig)
+	}
+}
+
+// claimReconnect marks a reconnection cycle as running. It reports false if one
+// is running already or auto-reconnect is disabled.
+func (sm *ServerManager) claimReconnect() (chan struct{}, BackoffConfig, bool) {
 	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.isReconnecting {
+		logger.Debug("Reconnection already in progress, skipping new attempt")
+		return nil, BackoffConfig{}, false
+	}
+	if !sm.config.AutoReconnect {
+		logger.Debug("Auto-reconnect disabled, skipping reconnection attempt")
+		return nil, BackoffConfig{}, false
+	}
+
+	// Claim the reconnection before releasing the lock, so that concurrent pipe
+	// errors and heartbeats cannot start a second reconnection cycle
+	sm.isReconnecting = true
+	sm.status = Reconnecting
 	if sm.stopReconnect == nil {
 		sm.stopReconnect = make(chan struct{})
 	}
-	stopCh := sm.stopReconnect
-	sm.mu.Unlock()
+	return sm.stopReconnect, sm.config.BackoffConfig, true
+}
+
+// reconnect runs a claimed reconnection cycle
+func (sm *ServerManager) reconnect(stopCh chan struct{}, backoffConfig BackoffConfig) {
+	// Add a small delay before reconnecting
+	select {
+	case <-stopCh:
+		sm.mu.Lock()
+		sm.isReconnecting = false
+		sm.mu.Unlock()
+		logger.Debug("Reconnection cancelled before it started")
+		return
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	logger.Info("Initiating reconnection with exponential backoff",
+		zap.Duration("initialDelay", backoffConfig.InitialDelay),
+		zap.Duration("maxDelay", backoffConfig.MaxDelay),
+		zap.Float64("multiplier", backoffConfig.Multiplier),
+		zap.Int("maxRetries", backoffConfig.MaxRetries),
+		zap.Duration("maxElapsedTime", backoffConfig.MaxElapsedTime))
 
 	// Clean up any existing process
 	logger.Debug("Cleaning up existing process before reconnection")
@@ -173,9 +289,7 @@ func (sm *ServerManager) tryReconnect() {
 
 		currentDelay := backoffConfig.InitialDelay
 		retryCount := 0
-
-		// Initialize random number generator for jitter
-		rand.Seed(time.Now().UnixNano())
+		reconnectStart := time.Now()
 
 		for {
 			if retryCount >= backoffConfig.MaxRetries && backoffConfig.MaxRetries > 0 {
@@ -185,6 +299,14 @@ func (sm *ServerManager) tryReconnect() {
 				sm.setStatus(ConnectionError)
 				return
 			}
+			if elapsed := time.Since(reconnectStart); backoffConfig.MaxElapsedTime > 0 && elapsed >= backoffConfig.MaxElapsedTime {
+				logger.Error("Maximum reconnection time reached",
+					zap.Duration("maxElapsedTime", backoffConfig.MaxElapsedTime),
+					zap.Duration("elapsed", elapsed),
+					zap.Int("actualAttempts", retryCount))
+				sm.setStatus(ConnectionError)
+				return
+			}
 
 			select {
 			case <-stopCh:
@@ -198,6 +320,8 @@ func (sm *ServerManager) tryReconnect() {
 					zap.Int("maxRetries", backoffConfig.MaxRetries),
 					zap.Duration("currentDelay", currentDelay))
 
+				sm.setReconnectState(retryCount+1, currentDelay)
+
 				startTime := time.Now()
 				err := sm.connect()
 				duration := time.Since(startTime)
@@ -206,23 +330,31 @@ func (sm *ServerManager) tryReconnect() {
 					logger.Info("Successfully reconnected to Siebel Server Manager",
 						zap.Int("attemptsTaken", retryCount+1),
 						zap.Duration("reconnectTime", duration))
+					sm.setReconnectState(0, 0)
 					return
 				}
 
 				retryCount++
 
 				// Calculate next delay with jitter
-				jitter := 1.0
-				if backoffConfig.JitterFactor > 0 {
-					// Add random jitter between -JitterFactor and +JitterFactor
-					jitter = 1.0 + (rand.Float64()*2.0-1.0)*backoffConfig.JitterFactor
-				}
+				jitter := backoffJitter(backoffConfig.JitterFactor)
 
 				nextDelay := time.Duration(float64(currentDelay) * backoffConfig.Multiplier * jitter)
 				if nextDelay > backoffConfig.MaxDelay {
 					nextDelay = backoffConfig.MaxDelay
 				}
 
+				// Do not wait for an attempt that would start after the time budget
+				if elapsed := time.Since(reconnectStart); backoffConfig.MaxElapsedTime > 0 && elapsed+nextDelay > backoffConfig.MaxElapsedTime {
+					logger.Error("Maximum reconnection time reached, giving up",
+						zap.Error(err),
+						zap.Duration("maxElapsedTime", backoffConfig.MaxElapsedTime),
+						zap.Duration("elapsed", elapsed),
+						zap.Int("actualAttempts", retryCount))
+					sm.setStatus(ConnectionError)
+					return
+				}
+
 				logger.Warn("Reconnection failed, will retry with backoff",
 					zap.Error(err),
 					zap.Int("attempt", retryCount),
@@ -232,6 +364,7 @@ func (sm *ServerManager) tryReconnect() {
 					zap.Float64("jitterFactor", jitter))
 
 				currentDelay = nextDelay
+				sm.setReconnectState(retryCount, currentDelay)
 
 				// Wait before retry
 				logger.Debug("Waiting before next reconnection attempt",
@@ -270,3 +403,14 @@ func (sm *ServerManager) ForceReconnect() error {
 
 	return err
 }
+
+// backoffJitter returns a random factor between 1-factor and 1+factor to spread
+// the reconnection delays. The source of math/rand/v2 is seeded once by the
+// runtime and safe for concurrent use, so sessions reconnecting at the same time
+// get independent jitter.
+func backoffJitter(factor float64) float64 {
+	if factor <= 0 {
+		return 1.0
+	}
+	return 1.0 + (rand.Float64()*2.0-1.0)*factor
+}
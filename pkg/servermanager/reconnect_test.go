@@ -0,0 +1,295 @@
+package servermanager
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+// waitFor polls condition until it holds or the timeout expires
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return condition()
+}
+
+func TestConcurrentPipeErrorsReconnectOnce(t *testing.T) {
+	tests := []struct {
+		name       string
+		pipeErrors int
+		killed     bool
+	}{
+		{name: "single pipe error", pipeErrors: 1},
+		{name: "concurrent pipe errors", pipeErrors: 8},
+		{name: "concurrent pipe errors after srvrmgr died", pipeErrors: 8, killed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			config := newTestConfig(fake)
+			config.AutoReconnect = true
+			config.BackoffConfig = BackoffConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, MaxRetries: 3}
+			sm := connectTestServerManager(t, config)
+			generation := sm.Generation()
+
+			if tt.killed {
+				if err := sm.cmd.Process.Kill(); err != nil {
+					t.Fatalf("killing srvrmgr: %v", err)
+				}
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.pipeErrors; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					sm.handlePipeError()
+				}()
+			}
+			wg.Wait()
+
+			reconnected := waitFor(t, 10*time.Second, func() bool {
+				return !sm.IsReconnecting() && sm.GetStatus() == Connected
+			})
+			if !reconnected {
+				t.Fatalf("not reconnected, status %s", sm.GetStatus())
+			}
+
+			if got := fake.Starts(); got != 2 {
+				t.Errorf("srvrmgr started %d times, want 2", got)
+			}
+			if got := sm.Generation(); got != generation+1 {
+				t.Errorf("Generation() = %d, want %d", got, generation+1)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	const calls = 1000
+
+	tests := []struct {
+		name   string
+		factor float64
+	}{
+		{name: "disabled", factor: 0},
+		{name: "negative factor", factor: -0.2},
+		{name: "10%", factor: 0.1},
+		{name: "50%", factor: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Called concurrently, as by sessions reconnecting at the same time
+			values := make([]float64, calls)
+			var wg sync.WaitGroup
+			for i := range values {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					values[i] = backoffJitter(tt.factor)
+				}()
+			}
+			wg.Wait()
+
+			distinct := make(map[float64]bool)
+			below, above := 0, 0
+			for _, value := range values {
+				distinct[value] = true
+				if value < 1 {
+					below++
+				} else if value > 1 {
+					above++
+				}
+				if tt.factor <= 0 {
+					if value != 1 {
+						t.Fatalf("backoffJitter(%v) = %v, want 1", tt.factor, value)
+					}
+					continue
+				}
+				if value < 1-tt.factor || value > 1+tt.factor {
+					t.Fatalf("backoffJitter(%v) = %v, want within [%v, %v]", tt.factor, value, 1-tt.factor, 1+tt.factor)
+				}
+			}
+			if tt.factor > 0 && len(distinct) < calls*9/10 {
+				t.Errorf("got %d distinct values in %d calls, want jitter to vary", len(distinct), calls)
+			}
+			if tt.factor > 0 && (below < calls/4 || above < calls/4) {
+				t.Errorf("%d values below and %d above 1, want them spread around 1", below, above)
+			}
+		})
+	}
+}
+
+func TestReconnectTimeBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		backoff      BackoffConfig
+		wantAttempts int
+	}{
+		// Attempts at 0, 200ms and 600ms, the next one at 1.4s would exceed the budget
+		{name: "1s budget", backoff: BackoffConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, MaxElapsedTime: time.Second}, wantAttempts: 3},
+		// The delay after 1.4s is capped at 1s, the attempt at 2.4s would exceed the budget
+		{name: "2s budget", backoff: BackoffConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, MaxElapsedTime: 2 * time.Second}, wantAttempts: 4},
+		{name: "retries before budget", backoff: BackoffConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, MaxRetries: 2, MaxElapsedTime: 10 * time.Second}, wantAttempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			config := newTestConfig(fake)
+			config.AutoReconnect = true
+			config.BackoffConfig = tt.backoff
+			sm := connectTestServerManager(t, config)
+
+			// Every reconnection attempt fails right away
+			sm.mu.Lock()
+			sm.config.SrvrmgrPath = filepath.Join(t.TempDir(), "missing")
+			sm.mu.Unlock()
+
+			start := time.Now()
+			sm.handlePipeError()
+
+			// The cycle starts after a delay of 500ms
+			limit := 500*time.Millisecond + tt.backoff.MaxElapsedTime
+			gaveUp := waitFor(t, limit+time.Second, func() bool {
+				return !sm.IsReconnecting() && sm.GetStatus() == ConnectionError
+			})
+			elapsed := time.Since(start)
+			if !gaveUp {
+				t.Fatalf("still reconnecting after %v, status %s", elapsed, sm.GetStatus())
+			}
+			if elapsed > limit {
+				t.Errorf("gave up after %v, want within %v", elapsed, limit)
+			}
+			if attempts, _ := sm.ReconnectState(); attempts != tt.wantAttempts {
+				t.Errorf("reconnection attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestCommandRetryAfterPipeError(t *testing.T) {
+	tests := []struct {
+		name             string
+		commandRetries   int
+		commandRetryWait time.Duration
+		wantErr          bool
+	}{
+		{name: "retried after reconnect", commandRetries: 1, commandRetryWait: 10 * time.Second},
+		{name: "retries disabled", commandRetries: 0, commandRetryWait: 10 * time.Second, wantErr: true},
+		{name: "reconnect slower than retry wait", commandRetries: 1, commandRetryWait: 500 * time.Millisecond, wantErr: true},
+	}
+
+	table := srvrmgrtest.Table([]string{"CC_ALIAS", "CP_DISP_RUN_STATE"}, []string{"SCCObjMgr_enu", "Running"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.Respond("list comp", srvrmgrtest.Response{Lines: table})
+
+			config := newTestConfig(fake)
+			config.AutoReconnect = true
+			config.BackoffConfig = BackoffConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, MaxRetries: 3}
+			config.CommandRetries = tt.commandRetries
+			config.CommandRetryWait = tt.commandRetryWait
+			sm := connectTestServerManager(t, config)
+
+			if err := sm.cmd.Process.Kill(); err != nil {
+				t.Fatalf("killing srvrmgr: %v", err)
+			}
+			sm.cmd.Process.Wait()
+
+			got, err := sm.SendCommandWithTimeout("list comp", 5*time.Second)
+			if tt.wantErr {
+				var lost *connectionLostError
+				if !errors.As(err, &lost) {
+					t.Fatalf("SendCommand() error = %v, want a lost connection", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendCommand() error = %v", err)
+			}
+			if output := strings.Join(got, "\n"); !strings.Contains(output, "SCCObjMgr_enu") {
+				t.Errorf("output of the retried command missing:\n%s", output)
+			}
+			if starts := fake.Starts(); starts != 2 {
+				t.Errorf("srvrmgr started %d times, want 2", starts)
+			}
+		})
+	}
+}
+
+func TestWatchdogRestart(t *testing.T) {
+	tests := []struct {
+		name       string
+		killed     bool
+		missing    bool
+		wantStatus Status
+		wantStarts int
+	}{
+		{name: "unresponsive session still connected", wantStatus: Connected, wantStarts: 2},
+		{name: "srvrmgr died", killed: true, wantStatus: Connected, wantStarts: 2},
+		{name: "restart fails", missing: true, wantStatus: ConnectionError, wantStarts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			config := newTestConfig(fake)
+			config.AutoReconnect = true
+			config.Watchdog = WatchdogConfig{Failures: 2, Window: time.Minute}
+			sm := connectTestServerManager(t, config)
+			generation := sm.Generation()
+
+			if tt.killed {
+				if err := sm.cmd.Process.Kill(); err != nil {
+					t.Fatalf("killing srvrmgr: %v", err)
+				}
+			}
+			if tt.missing {
+				sm.mu.Lock()
+				sm.config.SrvrmgrPath = filepath.Join(t.TempDir(), "missing")
+				sm.mu.Unlock()
+			}
+
+			now := time.Now()
+			if sm.recordHeartbeatFailure(now) {
+				t.Fatal("watchdog triggered after the first failed heartbeat")
+			}
+			if !sm.recordHeartbeatFailure(now.Add(30 * time.Second)) {
+				t.Fatal("watchdog not triggered after the second failed heartbeat")
+			}
+			if status := sm.GetStatus(); status != Connected {
+				t.Fatalf("status before restart = %s, want %s", status, Connected)
+			}
+			sm.watchdogRestart()
+
+			if status := sm.GetStatus(); status != tt.wantStatus {
+				t.Errorf("status after restart = %s, want %s", status, tt.wantStatus)
+			}
+			if got := sm.WatchdogRestarts(); got != 1 {
+				t.Errorf("WatchdogRestarts() = %d, want 1", got)
+			}
+			if got := fake.Starts(); got != tt.wantStarts {
+				t.Errorf("srvrmgr started %d times, want %d", got, tt.wantStarts)
+			}
+			if tt.wantStatus == Connected && sm.Generation() != generation+1 {
+				t.Errorf("Generation() = %d, want %d", sm.Generation(), generation+1)
+			}
+		})
+	}
+}
@@ -24,6 +24,17 @@ type ServerManager struct {
 	promptEndedPattern   *regexp.Regexp
 	status               Status
 
+	// Command sequence number and the number of prompts still owed by timed-out
+	// commands, whose late output must not be attributed to the next command
+	epoch        uint64
+	stalePrompts int
+
+	// Incremented on every successful connect, so callers can tell a session was re-established
+	generation uint64
+
+	// srvrmgr version and build from the banner of the current session
+	version, build string
+
 	// Configuration
 	config ServerManagerConfig
 
@@ -33,6 +44,16 @@ type ServerManager struct {
 	lastActivity    time.Time
 	heartbeatTicker *time.Ticker
 	isReconnecting  bool
+
+	// State of the active reconnection cycle, reset on success
+	reconnectAttempts int
+	reconnectDelay    time.Duration
+
+	// Watchdog state: times of the current run of failed heartbeats, restarts
+	// performed and restarts that failed in a row
+	heartbeatFailures []time.Time
+	watchdogRestarts  uint64
+	failedRestarts    int
 }
 
 // NewServerManager creates an instance of ServerManager with the provided configuration
@@ -43,8 +64,12 @@ func NewServerManager(config ServerManagerConfig) *ServerManager {
 	}
 
 	// Define patterns for prompt detection
-	promptPattern := regexp.MustCompile(`srvrmgr(:.*|>)`)
-	promptEndedPattern := regexp.MustCompile(`.*\ row(|s)\ returned\.`)
+	promptPattern, promptEndedPattern, err := config.promptPatterns()
+	if err != nil {
+		logger.Error("Invalid prompt pattern, using defaults", zap.Error(err))
+		promptPattern = regexp.MustCompile(DefaultPromptPattern)
+		promptEndedPattern = regexp.MustCompile(DefaultPromptEndedPattern)
+	}
 
 	logger.Debug("Creating new ServerManager instance",
 		zap.String("gateway", config.Gateway),
@@ -107,11 +132,42 @@ func (sm *ServerManager) GetStatus() Status {
 	return sm.status
 }
 
+// Generation returns the number of successful connects of this ServerManager. It changes
+// whenever the srvrmgr session is re-established.
+func (sm *ServerManager) Generation() uint64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.generation
+}
+
 // IsConnected returns true if the ServerManager is in Connected status
 func (sm *ServerManager) IsConnected() bool {
 	return sm.GetStatus() == Connected
 }
 
+// ReconnectState returns the number of attempts and the current backoff delay of
+// the active reconnection cycle. Both are zero when no reconnection is in progress.
+func (sm *ServerManager) ReconnectState() (int, time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.reconnectAttempts, sm.reconnectDelay
+}
+
+// setReconnectState records the progress of the active reconnection cycle
+func (sm *ServerManager) setReconnectState(attempts int, delay time.Duration) {
+	sm.mu.Lock()
+	sm.reconnectAttempts = attempts
+	sm.reconnectDelay = delay
+	sm.mu.Unlock()
+}
+
+// WatchdogRestarts returns the number of times the watchdog restarted the srvrmgr process
+func (sm *ServerManager) WatchdogRestarts() uint64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.watchdogRestarts
+}
+
 // IsReconnecting returns true if the ServerManager is actively trying to reconnect
 func (sm *ServerManager) IsReconnecting() bool {
 	sm.mu.Lock()
@@ -135,7 +191,7 @@ func (sm *ServerManager) handlePipeError() {
 
 	// If reconnection is enabled and we thought we were connected, try to reconnect
 	if autoReconnect && currentStatus == Connected {
-		go sm.tryReconnect()
+		sm.startReconnect()
 	}
 }
 
@@ -0,0 +1,283 @@
+// Package srvrmgrtest provides a fake srvrmgr for tests of code driving srvrmgr.
+// The test binary itself plays srvrmgr: it is started as SrvrmgrPath, and Main,
+// called first in TestMain, turns it into the fake. The fake is configured
+// through files in a temporary directory, so the responses can be changed while
+// a session is running.
+package srvrmgrtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Environment variables telling the test binary to act as fake srvrmgr
+const (
+	envFake = "SRVRMGRTEST_FAKE"
+	envDir  = "SRVRMGRTEST_DIR"
+)
+
+// Files in the directory of a fake
+const (
+	responsesFile = "responses.json"
+	bannerFile    = "banner.json"
+	stderrFile    = "stderr.json"
+	ignoreExit    = "ignore-exit"
+	commandsFile  = "commands.log"
+	argsFile      = "args.log"
+)
+
+// Prompt is shown by the fake before every echoed command
+const Prompt = "srvrmgr:SRV01> "
+
+// DefaultBanner is printed by the fake when it starts, like srvrmgr does
+var DefaultBanner = []string{
+	"Siebel Enterprise Applications Siebel Server Manager, Version 8.1.1.11 [23030] LANG_INDEPENDENT",
+	"Copyright (c) 2001 Siebel Systems, Inc.  All rights reserved.",
+	"",
+	"Connected to 1 server(s) out of a total of 1 server(s) in the enterprise",
+	"",
+}
+
+// Response is the reply of the fake to a command
+type Response struct {
+	// Lines printed after the prompt with the echoed command. Commands without a
+	// response get "0 rows returned.".
+	Lines []string
+
+	// Time the fake is busy before it echoes the command and replies
+	Delay time.Duration
+
+	// Exit without replying, like a crashing srvrmgr
+	Exit bool
+
+	// Exit after replying, so the next command finds the srvrmgr pipes closed
+	ExitAfterReply bool
+}
+
+// Fake is a fake srvrmgr
+type Fake struct {
+	// Path of the executable to use as SrvrmgrPath
+	Path string
+
+	dir string
+	mu  sync.Mutex
+}
+
+// Main runs the fake srvrmgr and exits if the process was started as one. Tests
+// using a Fake call it first in TestMain.
+func Main() {
+	if os.Getenv(envFake) != "1" {
+		return
+	}
+	os.Exit(run(os.Getenv(envDir), os.Args[1:]))
+}
+
+// New creates a fake srvrmgr. Sessions started by the test get the fake through
+// the environment, so tests using it must not run in parallel.
+func New(t testing.TB) *Fake {
+	t.Helper()
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("locating the test binary: %v", err)
+	}
+	f := &Fake{Path: path, dir: t.TempDir()}
+	t.Setenv(envFake, "1")
+	t.Setenv(envDir, f.dir)
+	return f
+}
+
+// Respond sets the response to a command, replacing an earlier one
+func (f *Fake) Respond(command string, response Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	responses := make(map[string]Response)
+	readJSON(filepath.Join(f.dir, responsesFile), &responses)
+	responses[command] = response
+	writeJSON(filepath.Join(f.dir, responsesFile), responses)
+}
+
+// SetBanner replaces the lines printed on start
+func (f *Fake) SetBanner(lines ...string) {
+	writeJSON(filepath.Join(f.dir, bannerFile), lines)
+}
+
+// SetStderr makes the fake print lines to stderr on start, e.g. a login failure
+func (f *Fake) SetStderr(lines ...string) {
+	writeJSON(filepath.Join(f.dir, stderrFile), lines)
+}
+
+// IgnoreExit makes the fake ignore the exit command, so it has to be killed
+func (f *Fake) IgnoreExit() {
+	if err := os.WriteFile(filepath.Join(f.dir, ignoreExit), nil, 0600); err != nil {
+		panic(err)
+	}
+}
+
+// Commands returns the commands received by all processes of the fake, in order
+func (f *Fake) Commands() []string {
+	data, err := os.ReadFile(filepath.Join(f.dir, commandsFile))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// Args returns the arguments of every process of the fake, in the order they started
+func (f *Fake) Args() [][]string {
+	data, err := os.ReadFile(filepath.Join(f.dir, argsFile))
+	if err != nil {
+		return nil
+	}
+	var args [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var processArgs []string
+		if err := json.Unmarshal([]byte(line), &processArgs); err != nil {
+			panic(err)
+		}
+		args = append(args, processArgs)
+	}
+	return args
+}
+
+// Starts returns how often the fake was started
+func (f *Fake) Starts() int {
+	return len(f.Args())
+}
+
+// Table formats a srvrmgr result table with the "N rows returned." ending
+func Table(columns []string, rows ...[]string) []string {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = len(column)
+		for _, row := range rows {
+			if i < len(row) && len(row[i]) > widths[i] {
+				widths[i] = len(row[i])
+			}
+		}
+	}
+
+	format := func(cells []string) string {
+		padded := make([]string, len(columns))
+		for i := range columns {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			padded[i] = cell + strings.Repeat(" ", widths[i]-len(cell))
+		}
+		return strings.Join(padded, "  ")
+	}
+
+	separators := make([]string, len(columns))
+	for i, width := range widths {
+		separators[i] = strings.Repeat("-", width)
+	}
+
+	lines := []string{"", format(columns), format(separators)}
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	ending := fmt.Sprintf("%d rows returned.", len(rows))
+	if len(rows) == 1 {
+		ending = "1 row returned."
+	}
+	return append(lines, "", ending)
+}
+
+// run is the main loop of the fake srvrmgr process
+func run(dir string, args []string) int {
+	appendLine(filepath.Join(dir, argsFile), mustJSON(args))
+
+	banner := DefaultBanner
+	readJSON(filepath.Join(dir, bannerFile), &banner)
+	for _, line := range banner {
+		fmt.Println(line)
+	}
+	var stderr []string
+	readJSON(filepath.Join(dir, stderrFile), &stderr)
+	for _, line := range stderr {
+		fmt.Fprintln(os.Stderr, line)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		appendLine(filepath.Join(dir, commandsFile), command)
+
+		if command == "exit" {
+			if _, err := os.Stat(filepath.Join(dir, ignoreExit)); err == nil {
+				continue
+			}
+			return 0
+		}
+
+		responses := make(map[string]Response)
+		readJSON(filepath.Join(dir, responsesFile), &responses)
+		response, exists := responses[command]
+		if !exists {
+			response.Lines = []string{"", "0 rows returned."}
+		}
+		if response.Exit {
+			return 1
+		}
+
+		time.Sleep(response.Delay)
+		fmt.Println(Prompt + command)
+		for _, line := range response.Lines {
+			fmt.Println(line)
+		}
+		if response.ExitAfterReply {
+			return 1
+		}
+	}
+	return 0
+}
+
+func mustJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+// readJSON decodes a file into v, leaving v as is if the file does not exist
+func readJSON(path string, v any) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		panic(err)
+	}
+}
+
+// writeJSON replaces a file atomically, the fake may read it at any time
+func writeJSON(path string, v any) {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(mustJSON(v)), 0600); err != nil {
+		panic(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		panic(err)
+	}
+}
+
+func appendLine(path, line string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,49 @@
+package servermanager
+
+import (
+	"regexp"
+	"strings"
+)
+
+// UnknownVersion is reported for sessions whose banner carries no recognizable version
+const UnknownVersion = "unknown"
+
+// versionPattern matches the version in the srvrmgr banner, e.g.
+// "Siebel Enterprise Applications Siebel Server Manager, Version 8.1.1.11 [23030] LANG_INDEPENDENT"
+// or "... Version 22.3 [22.3.0.0] LANG_INDEPENDENT". The build in brackets is optional.
+var versionPattern = regexp.MustCompile(`(?i)\bVersion\s+(\d[\w.]*)(?:\s*\[([^\]]+)\])?`)
+
+// parseVersion returns the version and build from the banner lines srvrmgr prints
+// when it starts. Both are UnknownVersion if no line carries a version. The line
+// naming the Server Manager is preferred, localized banners may not name it.
+func parseVersion(lines []string) (version, build string) {
+	var match []string
+	for _, line := range lines {
+		if m := versionPattern.FindStringSubmatch(line); m != nil {
+			if strings.Contains(strings.ToLower(line), "server manager") {
+				match = m
+				break
+			}
+			if match == nil {
+				match = m
+			}
+		}
+	}
+	if match == nil {
+		return UnknownVersion, UnknownVersion
+	}
+
+	build = strings.TrimSpace(match[2])
+	if build == "" {
+		build = UnknownVersion
+	}
+	return match[1], build
+}
+
+// Version returns the srvrmgr version and build of the current session, parsed from
+// the banner on connect. Both are empty until the first connect.
+func (sm *ServerManager) Version() (version, build string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.version, sm.build
+}
@@ -0,0 +1,107 @@
+package servermanager
+
+import (
+	"testing"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager/srvrmgrtest"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		banner      []string
+		wantVersion string
+		wantBuild   string
+	}{
+		{
+			name: "Siebel 8.1",
+			banner: []string{
+				"Siebel Enterprise Applications Siebel Server Manager, Version 8.1.1.11 [23030] LANG_INDEPENDENT",
+				"Copyright (c) 2008, 2014, Oracle. All rights reserved.",
+				"",
+				"Connected to 1 server(s) out of a total of 1 server(s) in the enterprise",
+			},
+			wantVersion: "8.1.1.11", wantBuild: "23030",
+		},
+		{
+			name: "Siebel IP 2016",
+			banner: []string{
+				"Siebel Enterprise Applications Siebel Server Manager, Version 16.0.0.0 [23057] LANG_INDEPENDENT",
+				"Copyright (c) 2001 Siebel Systems, Inc.  All rights reserved.",
+			},
+			wantVersion: "16.0.0.0", wantBuild: "23057",
+		},
+		{
+			name: "Siebel 22 with dotted build",
+			banner: []string{
+				"Siebel Enterprise Applications Siebel Server Manager, Version 22.3 [22.3.0.0] LANG_INDEPENDENT",
+			},
+			wantVersion: "22.3", wantBuild: "22.3.0.0",
+		},
+		{
+			name: "Siebel 7.8 without build",
+			banner: []string{
+				"Siebel Server Manager, Version 7.8.2.16",
+			},
+			wantVersion: "7.8.2.16", wantBuild: UnknownVersion,
+		},
+		{
+			name: "server manager line preferred",
+			banner: []string{
+				"Oracle Database Client Version 12.1.0 [2] loaded",
+				"Siebel Enterprise Applications Siebel Server Manager, Version 8.2.2.4 [23044] LANG_INDEPENDENT",
+			},
+			wantVersion: "8.2.2.4", wantBuild: "23044",
+		},
+		{
+			name: "localized banner",
+			banner: []string{
+				"Applications d'entreprise Siebel Gestionnaire de serveurs Siebel, Version 8.1.1.11 [23030] LANG_INDEPENDENT",
+				"Copyright (c) 2008, 2014, Oracle. Tous droits réservés.",
+			},
+			wantVersion: "8.1.1.11", wantBuild: "23030",
+		},
+		{
+			name: "no version",
+			banner: []string{
+				"Connected to 1 server(s) out of a total of 1 server(s) in the enterprise",
+			},
+			wantVersion: UnknownVersion, wantBuild: UnknownVersion,
+		},
+		{name: "no banner", wantVersion: UnknownVersion, wantBuild: UnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, build := parseVersion(tt.banner)
+			if version != tt.wantVersion || build != tt.wantBuild {
+				t.Errorf("parseVersion() = %q, %q, want %q, %q", version, build, tt.wantVersion, tt.wantBuild)
+			}
+		})
+	}
+}
+
+func TestVersionFromBanner(t *testing.T) {
+	tests := []struct {
+		name        string
+		banner      []string
+		wantVersion string
+		wantBuild   string
+	}{
+		{name: "default banner", banner: srvrmgrtest.DefaultBanner, wantVersion: "8.1.1.11", wantBuild: "23030"},
+		{name: "banner without version", banner: []string{"", "Connected to 1 server(s) out of a total of 1 server(s) in the enterprise", ""}, wantVersion: UnknownVersion, wantBuild: UnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := srvrmgrtest.New(t)
+			fake.SetBanner(tt.banner...)
+			sm := connectTestServerManager(t, newTestConfig(fake))
+
+			version, build := sm.Version()
+			if version != tt.wantVersion || build != tt.wantBuild {
+				t.Errorf("Version() = %q, %q, want %q, %q", version, build, tt.wantVersion, tt.wantBuild)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+	"go.uber.org/zap"
+)
+
+// configSetting is a configuration value shown on the home page and by /config.
+// Secrets such as the password are never part of the settings.
+type configSetting struct {
+	Section string
+	Key     string
+	Name    string
+	Value   interface{}
+}
+
+// configSettings returns the configuration the exporter is running with
+func (s *Server) configSettings() []configSetting {
+	windows := make([]string, 0, len(s.exporterConfig.MaintenanceWindows))
+	for _, window := range s.exporterConfig.MaintenanceWindows {
+		windows = append(windows, window.String())
+	}
+
+	return []configSetting{
+		{"serverManager", "gateway", "Gateway", s.smConfig.Gateway},
+		{"serverManager", "connectionMode", "Connection Mode", string(s.smConfig.ConnectionMode)},
+		{"serverManager", "serverAddress", "Server Address", s.smConfig.ServerAddress},
+		{"serverManager", "enterprise", "Enterprise", s.smConfig.Enterprise},
+		{"serverManager", "server", "Server", s.smConfig.Server},
+		{"serverManager", "user", "User", s.smConfig.User},
+		{"serverManager", "passwordFile", "Password File", s.smConfig.PasswordFile},
+		{"serverManager", "srvrmgrPath", "Srvrmgr Path", s.smConfig.SrvrmgrPath},
+		{"serverManager", "normalizeCommands", "Normalize Commands", s.smConfig.NormalizeCommands},
+		{"serverManager", "drainQuietPeriod", "Drain Quiet Period", s.smConfig.DrainQuietPeriod.String()},
+		{"serverManager", "timeoutResyncWait", "Timeout Resync Wait", s.smConfig.TimeoutResyncWait.String()},
+		{"serverManager", "resyncBeforeCommand", "Resync Before Command", s.smConfig.ResyncBeforeCommand},
+		{"serverManager", "mergeStderr", "Merge Stderr", s.smConfig.MergeStderr},
+		{"serverManager", "extraArgs", "Extra Arguments", servermanager.RedactArgs(s.smConfig.ExtraArgs)},
+		{"serverManager", "startupCommands", "Startup Commands", s.smConfig.StartupCommands},
+		{"serverManager", "promptPattern", "Prompt Pattern", s.smConfig.PromptPattern},
+		{"serverManager", "promptEndedPattern", "Prompt Ended Pattern", s.smConfig.PromptEndedPattern},
+		{"serverManager", "autoReconnect", "Auto Reconnect", s.smConfig.AutoReconnect},
+		{"serverManager", "reconnectDelay", "Reconnect Delay", s.smConfig.ReconnectDelay.String()},
+		{"serverManager", "backoffInitial", "Backoff Initial Delay", s.smConfig.BackoffConfig.InitialDelay.String()},
+		{"serverManager", "backoffMax", "Backoff Max Delay", s.smConfig.BackoffConfig.MaxDelay.String()},
+		{"serverManager", "backoffMultiplier", "Backoff Multiplier", s.smConfig.BackoffConfig.Multiplier},
+		{"serverManager", "backoffMaxRetries", "Backoff Max Retries", s.smConfig.BackoffConfig.MaxRetries},
+		{"serverManager", "backoffJitter", "Backoff Jitter", s.smConfig.BackoffConfig.JitterFactor},
+		{"serverManager", "backoffMaxElapsed", "Backoff Max Elapsed Time", s.smConfig.BackoffConfig.MaxElapsedTime.String()},
+		{"serverManager", "commandRetryWait", "Command Retry Wait", s.smConfig.CommandRetryWait.String()},
+		{"serverManager", "commandRetries", "Command Retries", s.smConfig.CommandRetries},
+		{"serverManager", "exitTimeout", "Exit Timeout", s.smConfig.ExitTimeout.String()},
+		{"exporter", "reconnectAfterScrape", "Reconnect After Scrape", s.exporterConfig.ReconnectAfterScrape},
+		{"exporter", "serverDownIsScrapeError", "Server Down Is Scrape Error", s.exporterConfig.ServerDownIsScrapeError},
+		{"exporter", "staleMetricsIsScrapeError", "Stale Metrics Is Scrape Error", s.exporterConfig.StaleMetricsIsScrapeError},
+		{"exporter", "instanceId", "Instance ID", s.exporterConfig.InstanceID},
+		{"exporter", "exemplars", "Exemplars", s.exporterConfig.Exemplars},
+		{"exporter", "namespace", "Namespace", s.exporterConfig.Namespace},
+		{"exporter", "metricsFile", "Metrics File", s.exporterConfig.DefaultMetricsFile},
+		{"exporter", "customMetricsFiles", "Custom Metrics Files", s.exporterConfig.CustomMetricsFiles},
+		{"exporter", "dateFormats", "Date Formats", s.exporterConfig.DateFormats},
+		{"exporter", "timeZone", "Time Zone", s.exporterConfig.TimeZone},
+		{"exporter", "defaultMetricType", "Default Metric Type", s.exporterConfig.DefaultMetricType},
+		{"exporter", "disableEmptyMetricsOverride", "Disable Empty Metrics Override", s.exporterConfig.DisableEmptyMetricsOverride},
+		{"exporter", "disableExtendedMetrics", "Disable Extended Metrics", s.exporterConfig.DisableExtendedMetrics},
+		{"exporter", "collapseLabelWhitespace", "Collapse Label Whitespace", s.exporterConfig.CollapseLabelWhitespace},
+		{"exporter", "debugUnmappedColumns", "Debug Unmapped Columns", s.exporterConfig.DebugUnmappedColumns},
+		{"exporter", "ignoreZeroResult", "Ignore Zero Result", s.exporterConfig.IgnoreZeroResult},
+		{"exporter", "dedupScrapeErrors", "Dedup Scrape Errors", s.exporterConfig.DedupScrapeErrors},
+		{"exporter", "enterpriseLabel", "Enterprise Label", s.exporterConfig.EnterpriseLabel},
+		{"exporter", "enterpriseInNamespace", "Enterprise In Namespace", s.exporterConfig.EnterpriseInNamespace},
+		{"exporter", "chunkSize", "Chunk Size", s.exporterConfig.ChunkSize},
+		{"exporter", "scrapeConcurrency", "Scrape Concurrency", s.exporterConfig.ScrapeConcurrency},
+		{"exporter", "poolIdleTimeout", "Pool Idle Timeout", s.exporterConfig.PoolIdleTimeout.String()},
+		{"exporter", "maxScrapeMemory", "Max Scrape Memory", s.exporterConfig.MaxScrapeMemory},
+		{"exporter", "maintenanceWindows", "Maintenance Windows", windows},
+		{"exporter", "componentHealth", "Component Health", s.exporterConfig.ComponentHealth.Enabled},
+		{"exporter", "componentHealthCommand", "Component Health Command", s.exporterConfig.ComponentHealth.Command},
+		{"exporter", "componentHealthStatusColumn", "Component Health Status Column", s.exporterConfig.ComponentHealth.StatusColumn},
+		{"exporter", "componentRunningValues", "Component Running Values", s.exporterConfig.ComponentHealth.RunningValues},
+		{"web", "listenAddress", "Web Listen Address", s.config.ListenAddress},
+		{"web", "adminListenAddress", "Web Admin Listen Address", s.config.AdminListenAddress},
+		{"web", "routePrefix", "Route Prefix", s.config.RoutePrefix},
+		{"web", "metricsPath", "Metrics Path", s.config.MetricsPath},
+		{"web", "disableExporterMetrics", "Disable Exporter Metrics", s.config.DisableExporterMetrics},
+		{"web", "disableLogs", "Disable Logs", s.config.DisableLogs},
+		{"web", "disableCompression", "Disable Compression", s.config.DisableCompression},
+		{"web", "logStreamBuffer", "Log Stream Buffer", s.config.LogStreamBuffer},
+		{"web", "enableMultiTarget", "Enable Multi Target", s.config.EnableMultiTarget},
+		{"web", "enableCommandEndpoint", "Enable Command Endpoint", s.config.EnableCommandEndpoint},
+		{"web", "enablePprof", "Enable Pprof", s.config.EnablePprof},
+		{"web", "tlsCertFile", "TLS Certificate File", s.config.TLSCertFile},
+		{"web", "tlsClientCAFile", "TLS Client CA File", s.config.TLSClientCAFile},
+		{"log", "level", "Log Level", string(logger.GetLevel())},
+	}
+}
+
+// String formats the value for display
+func (c configSetting) String() string {
+	if values, ok := c.Value.([]string); ok {
+		return strings.Join(values, ", ")
+	}
+	return fmt.Sprint(c.Value)
+}
+
+// configHandler returns the current configuration as JSON object of sections
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	config := map[string]map[string]interface{}{}
+	for _, setting := range s.configSettings() {
+		if config[setting.Section] == nil {
+			config[setting.Section] = map[string]interface{}{}
+		}
+		config[setting.Section][setting.Key] = setting.Value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config); err != nil {
+		logger.Warn("Error writing configuration", zap.Error(err))
+	}
+}
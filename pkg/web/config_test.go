@@ -0,0 +1,56 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/exporter"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+)
+
+func TestConfigHandlerOmitsPassword(t *testing.T) {
+	const password = "s3cr3t-Passw0rd"
+
+	tests := []struct {
+		name     string
+		smConfig servermanager.ServerManagerConfig
+	}{
+		{name: "password", smConfig: servermanager.ServerManagerConfig{User: "SADMIN", Password: password}},
+		{name: "password file", smConfig: servermanager.ServerManagerConfig{User: "SADMIN", Password: password, PasswordFile: "/run/secrets/siebel"}},
+		{name: "password in extra arguments", smConfig: servermanager.ServerManagerConfig{User: "SADMIN", ExtraArgs: []string{"-l", "enu", "-p", password}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(ServerConfig{MetricsPath: "/metrics"}, &tt.smConfig, exporter.NewDefaultExporterConfig())
+
+			recorder := httptest.NewRecorder()
+			s.configHandler(recorder, httptest.NewRequest(http.MethodGet, "/config", nil))
+			body := recorder.Body.String()
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", recorder.Code)
+			}
+			if strings.Contains(body, password) {
+				t.Errorf("/config contains the password:\n%s", body)
+			}
+
+			var config map[string]map[string]interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &config); err != nil {
+				t.Fatalf("/config is not a JSON object of sections: %v", err)
+			}
+			if _, exists := config["serverManager"]["password"]; exists {
+				t.Error("/config has a password setting")
+			}
+			if got := config["serverManager"]["user"]; got != "SADMIN" {
+				t.Errorf("user = %v, want SADMIN", got)
+			}
+			if got := config["serverManager"]["passwordFile"]; got != tt.smConfig.PasswordFile {
+				t.Errorf("passwordFile = %v, want %q", got, tt.smConfig.PasswordFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// discoveryTarget is a target group of the Prometheus HTTP service discovery
+type discoveryTarget struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// discoveryHandler lists the configured enterprises and servers in the format of
+// the Prometheus HTTP service discovery. Each group scrapes one server through the
+// multi-target endpoint of this exporter.
+func (s *Server) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	srvrmgrs := s.exporter.ServerManagers()
+	names := make([]string, 0, len(srvrmgrs))
+	for name := range srvrmgrs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	groups := make([]discoveryTarget, 0, len(names))
+	for _, name := range names {
+		config := srvrmgrs[name].GetConfig()
+		groups = append(groups, discoveryTarget{
+			Targets: []string{r.Host},
+			Labels: map[string]string{
+				"__metrics_path__":   s.route("/scrape"),
+				"__param_target":     config.Gateway,
+				"__param_enterprise": config.Enterprise,
+				"__param_server":     config.Server,
+				"enterprise":         config.Enterprise,
+				"server":             config.Server,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		logger.Warn("Error writing discovery targets", zap.Error(err))
+	}
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Prefix of listen addresses that are Unix domain sockets, e.g. unix:/run/siebel_exporter.sock
+const unixAddressPrefix = "unix:"
+
+// Permissions of the socket file, a sidecar running as another user of the group may connect
+const unixSocketMode = 0660
+
+// listen creates the listener of a TCP address or of a Unix domain socket given as unix:/path
+func listen(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, unixAddressPrefix)
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("missing socket path in listen address %q", address)
+	}
+
+	// A socket left behind by a killed exporter would make the listen fail
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnixSocket(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, path string)
+		noPath  bool
+		wantErr string
+	}{
+		{name: "new socket"},
+		{
+			name: "stale socket",
+			prepare: func(t *testing.T, path string) {
+				listener, err := net.Listen("unix", path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				listener.(*net.UnixListener).SetUnlinkOnClose(false)
+				listener.Close()
+			},
+		},
+		{
+			name: "regular file",
+			prepare: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "is not a socket",
+		},
+		{name: "missing path", noPath: true, wantErr: "missing socket path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "exporter.sock")
+			if tt.prepare != nil {
+				tt.prepare(t, path)
+			}
+			address := unixAddressPrefix + path
+			if tt.noPath {
+				address = unixAddressPrefix
+			}
+			s := newTestServer(ServerConfig{ListenAddress: address})
+
+			if tt.wantErr != "" {
+				if err := s.Start(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Start() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			result := startTestServer(t, s, "unix", path)
+			defer stopTestServer(t, s, result)
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := info.Mode().Perm(); mode != unixSocketMode {
+				t.Errorf("socket mode = %o, want %o", mode, unixSocketMode)
+			}
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", path)
+				},
+			}}
+			resp, err := client.Get("http://exporter/metrics")
+			if err != nil {
+				t.Fatalf("GET over the socket: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200: %s", resp.StatusCode, body)
+			}
+		})
+	}
+}
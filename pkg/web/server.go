@@ -1,11 +1,21 @@
 package web
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
+	"io"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,82 +26,591 @@ import (
 	"go.uber.org/zap"
 )
 
+// Entries buffered per /logs/stream client unless configured
+const defaultLogStreamBuffer = 100
+
 // ServerConfig holds the web server configuration
 type ServerConfig struct {
 	ListenAddress          string
 	MetricsPath            string
 	DisableExporterMetrics bool
 	DisableLogs            bool
+	DisableCompression     bool // Never compress the metrics response, e.g. when a proxy compresses it
+	EnableMultiTarget      bool
+
+	// POST /debug/command runs arbitrary srvrmgr commands, so it is off by default
+	// and requires the token as bearer token
+	EnableCommandEndpoint bool
+	CommandEndpointToken  string
+
+	// Static headers added to the metrics response, e.g. X-Scope-OrgID for multi-tenant Cortex or Mimir
+	ResponseHeaders map[string]string
+
+	// Path prefix of all endpoints, e.g. "/siebel" behind a reverse proxy serving
+	// the exporter under a subpath. Empty serves them at the root.
+	RoutePrefix string
+
+	// Serve the net/http/pprof profiles under /debug/pprof/, requiring PprofToken
+	// as bearer token if set
+	EnablePprof bool
+	PprofToken  string
+
+	// Entries buffered per /logs/stream client, a slower client misses entries
+	LogStreamBuffer int
+
+	// PUT /-/log-level requires this bearer token, changing the level is refused without one
+	LogLevelToken string
+
+	// POST /-/pause and /-/resume require this bearer token, pausing is refused without one
+	ControlToken string
+
+	// Serve HTTPS with this certificate and key. With a client CA file, clients
+	// must authenticate with a certificate signed by one of its CAs (mTLS).
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	// Serve the logs, configuration, control and debug endpoints on this separate
+	// address only, e.g. localhost or a management interface. Empty serves them
+	// together with the metrics.
+	AdminListenAddress string
 }
 
 // Server represents the web server
 type Server struct {
 	config         ServerConfig
+	httpServer     *http.Server
+	mux            *http.ServeMux
+	adminServer    *http.Server   // nil unless AdminListenAddress is set
+	adminMux       *http.ServeMux // mux of the admin endpoints, the same as mux without adminServer
 	registry       *prometheus.Registry
 	smConfig       *servermanager.ServerManagerConfig
 	exporterConfig *exporter.ExporterConfig
-	logLevel       string
+	exporter       *exporter.Exporter
+	targets        *targetPool
 	startTime      time.Time
+	shutdown       chan struct{} // closed on shutdown to end streaming responses
+	shutdownOnce   sync.Once
 }
 
 // NewServer creates a new web server
-func NewServer(config ServerConfig, smConfig *servermanager.ServerManagerConfig, exporterConfig *exporter.ExporterConfig, logLevel string) *Server {
-	return &Server{
-		config:         config,
+func NewServer(config ServerConfig, smConfig *servermanager.ServerManagerConfig, exporterConfig *exporter.ExporterConfig) *Server {
+	mux := http.NewServeMux()
+
+	s := &Server{
+		config: config,
+		httpServer: &http.Server{
+			Addr:    config.ListenAddress,
+			Handler: mux,
+		},
+		mux:            mux,
+		adminMux:       mux,
 		registry:       prometheus.NewRegistry(),
 		smConfig:       smConfig,
 		exporterConfig: exporterConfig,
-		logLevel:       logLevel,
+		targets:        newTargetPool(*smConfig),
 		startTime:      time.Now(),
+		shutdown:       make(chan struct{}),
 	}
+	if config.AdminListenAddress != "" {
+		s.adminMux = http.NewServeMux()
+		s.adminServer = &http.Server{
+			Addr:    config.AdminListenAddress,
+			Handler: s.adminMux,
+		}
+	}
+
+	// Shutdown waits for open connections, streams would hold it up until its timeout
+	closeShutdown := func() { s.shutdownOnce.Do(func() { close(s.shutdown) }) }
+	s.httpServer.RegisterOnShutdown(closeShutdown)
+	if s.adminServer != nil {
+		s.adminServer.RegisterOnShutdown(closeShutdown)
+	}
+	return s
+}
+
+// RegisterCollector registers an additional collector with the Prometheus registry
+func (s *Server) RegisterCollector(collector prometheus.Collector) {
+	s.registry.MustRegister(collector)
+}
+
+// Gatherer returns the registry served on the metrics path, e.g. for scheduled collection
+func (s *Server) Gatherer() prometheus.Gatherer {
+	return s.registry
 }
 
 // RegisterExporter registers the Siebel exporter with the Prometheus registry
 func (s *Server) RegisterExporter(siebelExporter *exporter.Exporter) {
+	s.exporter = siebelExporter
 	s.registry.MustRegister(siebelExporter)
 
 	// If not disabled, register Go collector and process collector
 	if !s.config.DisableExporterMetrics {
 		s.registry.MustRegister(prometheus.NewGoCollector())
 		s.registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
-		s.registry.MustRegister(prometheus.NewBuildInfoCollector())
+		prometheus.WrapRegistererWith(prometheus.Labels{"instance_id": s.exporterConfig.InstanceID}, s.registry).
+			MustRegister(prometheus.NewBuildInfoCollector())
 		logger.Info("Registered standard exporters")
 	} else {
 		logger.Info("Standard exporters disabled")
 	}
 }
 
-// Start starts the web server
+// Start starts the web server and blocks until it is stopped.
+// It returns nil when the server was shut down via Stop.
 func (s *Server) Start() error {
 	// Setup HTTP handlers
-	http.Handle(s.config.MetricsPath, promhttp.HandlerFor(
-		s.registry,
-		promhttp.HandlerOpts{
-			EnableOpenMetrics: true,
-		},
-	))
+	s.mux.Handle(s.config.MetricsPath, withResponseHeaders(promhttp.HandlerFor(s.registry, s.handlerOpts()), s.config.ResponseHeaders))
+
+	s.mux.HandleFunc(s.metricNamesPath(), s.metricNamesHandler)
+	s.adminMux.HandleFunc("/config", s.configHandler)
+	s.adminMux.HandleFunc("/-/reload", s.reloadHandler)
+	s.adminMux.HandleFunc("/-/pause", s.pauseHandler)
+	s.adminMux.HandleFunc("/-/resume", s.resumeHandler)
+	s.adminMux.HandleFunc("/-/log-level", s.logLevelHandler)
+	s.mux.HandleFunc("/", s.homeHandler)
+	if s.adminServer != nil {
+		s.adminMux.HandleFunc("/", s.homeHandler)
+	}
+
+	// Only register multi-target scrape handler if enabled
+	if s.config.EnableMultiTarget {
+		s.mux.HandleFunc("/scrape", s.scrapeHandler)
+		s.mux.HandleFunc("/discovery", s.discoveryHandler)
+	}
 
-	http.HandleFunc("/", s.homeHandler)
+	if s.config.EnableCommandEndpoint {
+		s.adminMux.HandleFunc("/debug/command", s.commandHandler)
+	}
+
+	if s.config.EnablePprof {
+		s.registerPprof()
+	}
 
 	// Only register logs handler if not disabled
 	if !s.config.DisableLogs {
-		http.HandleFunc("/logs", s.logsHandler)
+		s.adminMux.HandleFunc("/logs", s.logsHandler)
+		s.adminMux.HandleFunc("/logs.json", s.logsJSONHandler)
+		s.adminMux.HandleFunc("/logs/stream", s.logsStreamHandler)
+	}
+
+	// The handlers are registered at the root and served below the prefix
+	s.httpServer.Handler = s.withRoutePrefix(s.mux)
+	if s.adminServer != nil {
+		s.adminServer.Handler = s.withRoutePrefix(s.adminMux)
 	}
 
 	logger.Info("Starting HTTP server",
 		zap.String("address", s.config.ListenAddress),
+		zap.String("adminAddress", s.config.AdminListenAddress),
 		zap.String("metricsPath", s.config.MetricsPath),
+		zap.String("routePrefix", s.routePrefix()),
 		zap.Bool("exporterMetricsDisabled", s.config.DisableExporterMetrics),
-		zap.Bool("logsDisabled", s.config.DisableLogs))
+		zap.Bool("logsDisabled", s.config.DisableLogs),
+		zap.Bool("multiTargetEnabled", s.config.EnableMultiTarget),
+		zap.Bool("commandEndpointEnabled", s.config.EnableCommandEndpoint),
+		zap.Bool("tls", s.config.TLSCertFile != ""),
+		zap.Bool("clientCertificates", s.config.TLSClientCAFile != ""))
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	servers := []*http.Server{s.httpServer}
+	if s.adminServer != nil {
+		servers = append(servers, s.adminServer)
+	}
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, server := range servers {
+		listener, err := listen(server.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	// Either server failing ends Start, both end with nil on Stop
+	errs := make(chan error, len(servers))
+	for i, server := range servers {
+		go func() {
+			var err error
+			if tlsConfig != nil {
+				server.TLSConfig = tlsConfig
+				err = server.ServeTLS(listeners[i], "", "")
+			} else {
+				err = server.Serve(listeners[i])
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			errs <- err
+		}()
+	}
+	for range servers {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withRoutePrefix serves the handlers of mux below the route prefix, if configured
+func (s *Server) withRoutePrefix(mux *http.ServeMux) http.Handler {
+	prefix := s.routePrefix()
+	if prefix == "" {
+		return mux
+	}
+	prefixed := http.NewServeMux()
+	prefixed.Handle(prefix+"/", http.StripPrefix(prefix, mux))
+	return prefixed
+}
+
+// isAdminRequest reports whether a request may see the admin endpoints, which is
+// any request unless they are served on a separate admin address
+func (s *Server) isAdminRequest(r *http.Request) bool {
+	if s.adminServer == nil {
+		return true
+	}
+	server, _ := r.Context().Value(http.ServerContextKey).(*http.Server)
+	return server == s.adminServer
+}
+
+// Stop gracefully shuts down the web server, waiting for active requests
+// to complete until the context is done.
+func (s *Server) Stop(ctx context.Context) error {
+	logger.Info("Stopping HTTP server")
+	err := s.httpServer.Shutdown(ctx)
+	if s.adminServer != nil {
+		if adminErr := s.adminServer.Shutdown(ctx); err == nil {
+			err = adminErr
+		}
+	}
+
+	// Disconnect sessions opened for multi-target scrapes
+	s.targets.close()
+
+	return err
+}
+
+// scrapeHandler scrapes a single target given by query parameters, following the
+// Prometheus multi-target exporter pattern. Enterprise and server default to the
+// configured ones when omitted.
+func (s *Server) scrapeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	gateway := query.Get("target")
+	if gateway == "" {
+		http.Error(w, "Missing 'target' parameter", http.StatusBadRequest)
+		return
+	}
+
+	enterprise := query.Get("enterprise")
+	if enterprise == "" {
+		enterprise = s.smConfig.Enterprise
+	}
+
+	server := query.Get("server")
+	if server == "" {
+		server = s.smConfig.Server
+	}
+
+	// With several configured enterprises or servers there is no default
+	if enterprise == "" || server == "" || strings.Contains(enterprise, ",") || strings.ContainsAny(server, ",/") {
+		http.Error(w, "Missing or ambiguous 'enterprise' or 'server' parameter", http.StatusBadRequest)
+		return
+	}
+
+	target, err := s.targets.acquire(gateway, enterprise, server)
+	if err != nil {
+		logger.Error("Failed to connect to scrape target",
+			zap.String("gateway", gateway),
+			zap.String("enterprise", enterprise),
+			zap.String("server", server),
+			zap.Error(err))
+		http.Error(w, fmt.Sprintf("Failed to connect to target: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer s.targets.release(target)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter.NewTargetExporter(target.srvrmgr, s.exporterConfig))
+
+	promhttp.HandlerFor(registry, s.handlerOpts()).ServeHTTP(w, r)
+}
+
+// handlerOpts returns the options of the metrics handlers. The format is negotiated
+// from the Accept header and independently the compression from Accept-Encoding,
+// gzip or zstd, unless compression is disabled.
+func (s *Server) handlerOpts() promhttp.HandlerOpts {
+	return promhttp.HandlerOpts{
+		EnableOpenMetrics:   true,
+		DisableCompression:  s.config.DisableCompression,
+		OfferedCompressions: []promhttp.Compression{promhttp.Identity, promhttp.Gzip, promhttp.Zstd},
+	}
+}
+
+// reloadHandler reloads the metrics file on demand
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(w, r, s.config.ControlToken) {
+		return
+	}
+
+	if s.exporter == nil {
+		http.Error(w, "Exporter not registered", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := s.exporter.ReloadMetrics(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload metrics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Metrics reloaded")
+}
+
+// commandHandler sends the command given in the request body or "command" form value
+// to srvrmgr and returns the raw output lines, to diagnose parsing issues
+func (s *Server) commandHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(w, r, s.config.CommandEndpointToken) {
+		return
+	}
+
+	if s.exporter == nil {
+		http.Error(w, "Exporter not registered", http.StatusServiceUnavailable)
+		return
+	}
+
+	command := r.FormValue("command")
+	if command == "" {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		command = string(body)
+	}
+	command = strings.TrimSpace(command)
+	if command == "" {
+		http.Error(w, "Missing command", http.StatusBadRequest)
+		return
+	}
+
+	timeout := servermanager.DefaultTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid timeout: %s", value), http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	lines, err := s.exporter.RunCommand(r.URL.Query().Get("server"), command, timeout)
+	switch {
+	case errors.Is(err, exporter.ErrUnknownServer):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, exporter.ErrNotConnected):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Command failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// withResponseHeaders sets the given static headers on every response of next
+func withResponseHeaders(next http.Handler, headers map[string]string) http.Handler {
+	if len(headers) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registerPprof registers the profiling handlers of net/http/pprof, e.g. to capture
+// a heap profile during a large scrape
+func (s *Server) registerPprof() {
+	handlers := map[string]http.HandlerFunc{
+		"/debug/pprof/":        pprof.Index,
+		"/debug/pprof/cmdline": pprof.Cmdline,
+		"/debug/pprof/profile": pprof.Profile,
+		"/debug/pprof/symbol":  pprof.Symbol,
+		"/debug/pprof/trace":   pprof.Trace,
+	}
+	for path, handler := range handlers {
+		if s.config.PprofToken != "" {
+			handler = withToken(handler, s.config.PprofToken)
+		}
+		s.adminMux.HandleFunc(path, handler)
+	}
+	logger.Info("Profiling endpoints enabled", zap.Bool("tokenRequired", s.config.PprofToken != ""))
+}
+
+// withToken requires the bearer token for every request to next
+func withToken(next http.HandlerFunc, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r, token) {
+			next(w, r)
+		}
+	}
+}
+
+// authorized checks the bearer token of a request and answers 401 if it does not
+// match. An empty token never matches.
+func authorized(w http.ResponseWriter, r *http.Request, token string) bool {
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// logLevelHandler returns the current log level on GET and changes it on an
+// authenticated PUT with the level as body or "level" form value
+func (s *Server) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if !authorized(w, r, s.config.LogLevelToken) {
+			return
+		}
+
+		level := r.FormValue("level")
+		if level == "" {
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1024))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error reading request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			level = string(body)
+		}
+
+		if err := logger.SetLevel(logger.Level(strings.TrimSpace(level))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Only GET and PUT requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, logger.GetLevel())
+}
+
+// pauseHandler stops the exporter from sending commands to Siebel, e.g. during
+// maintenance, on an authenticated POST
+func (s *Server) pauseHandler(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+// resumeHandler continues scraping after a pause on an authenticated POST
+func (s *Server) resumeHandler(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
+
+func (s *Server) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(w, r, s.config.ControlToken) {
+		return
+	}
+
+	if s.exporter == nil {
+		http.Error(w, "Exporter not registered", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if paused {
+		s.exporter.Pause()
+		fmt.Fprintln(w, "Scraping paused")
+	} else {
+		s.exporter.Resume()
+		fmt.Fprintln(w, "Scraping resumed")
+	}
+}
+
+// routePrefix returns the configured route prefix with a leading and without a
+// trailing slash, or "" if none is configured
+func (s *Server) routePrefix() string {
+	prefix := strings.TrimRight(s.config.RoutePrefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// route returns the external path of an endpoint, including the route prefix,
+// for links in the generated pages
+func (s *Server) route(path string) string {
+	return s.routePrefix() + path
+}
+
+// metricNamesPath returns the path of the metric names endpoint, relative to the metrics path
+func (s *Server) metricNamesPath() string {
+	return strings.TrimRight(s.config.MetricsPath, "/") + "/names"
+}
+
+// metricNamesHandler lists the sorted names of the metrics of the last scrape.
+// It does not scrape, before the first scrape the list is empty.
+func (s *Server) metricNamesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.exporter == nil {
+		http.Error(w, "Exporter not registered", http.StatusServiceUnavailable)
+		return
+	}
 
-	return http.ListenAndServe(s.config.ListenAddress, nil)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, name := range s.exporter.MetricNames() {
+		fmt.Fprintln(w, name)
+	}
 }
 
 // homeHandler handles the home page
 func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
-	var html strings.Builder
+	// With a separate admin address, admin paths on the metrics address must not look served
+	if s.adminServer != nil && r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
 
-	html.WriteString(`<html>
+	var page strings.Builder
+
+	page.WriteString(`<html>
 <head>
   <title>Siebel Exporter</title>
   <style>
@@ -112,92 +631,68 @@ func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 </head>
 <body>
   <div class="container">
-    <h1>Siebel Exporter</h1>
-    <a href="` + s.config.MetricsPath + `" class="metrics-link">View Metrics</a>`)
+    <h1>Siebel Exporter</h1>`)
+
+	// The metrics and the admin endpoints may be served on different addresses
+	admin := s.isAdminRequest(r)
+	if s.adminServer == nil || !admin {
+		page.WriteString(`
+    <a href="` + html.EscapeString(s.route(s.config.MetricsPath)) + `" class="metrics-link">View Metrics</a>`)
+	}
 
 	// Only show logs link if not disabled
-	if !s.config.DisableLogs {
-		html.WriteString(`
-    <a href="/logs" class="metrics-link" style="margin-left: 10px;">View Logs</a>`)
+	if !s.config.DisableLogs && admin {
+		page.WriteString(`
+    <a href="` + html.EscapeString(s.route("/logs")) + `" class="metrics-link" style="margin-left: 10px;">View Logs</a>`)
 	}
 
-	html.WriteString(`
+	// The configuration is only shown where the admin endpoints are served
+	if admin {
+		page.WriteString(`
     
     <h3>Current Configuration</h3>
     <table>
       <tr>
         <th>Setting</th>
         <th>Value</th>
-      </tr>
-      <tr>
-        <td>Gateway</td>
-        <td>` + s.smConfig.Gateway + `</td>
-      </tr>
-      <tr>
-        <td>Enterprise</td>
-        <td>` + s.smConfig.Enterprise + `</td>
-      </tr>
-      <tr>
-        <td>Server</td>
-        <td>` + s.smConfig.Server + `</td>
-      </tr>
-      <tr>
-        <td>User</td>
-        <td>` + s.smConfig.User + `</td>
-      </tr>
-      <tr>
-        <td>Srvrmgr Path</td>
-        <td>` + s.smConfig.SrvrmgrPath + `</td>
-      </tr>
-      <tr>
-        <td>Auto Reconnect</td>
-        <td>` + fmt.Sprintf("%t", s.smConfig.AutoReconnect) + `</td>
-      </tr>
-      <tr>
-        <td>Reconnect Delay</td>
-        <td>` + s.smConfig.ReconnectDelay.String() + `</td>
-      </tr>
-      <tr>
-        <td>Reconnect After Scrape</td>
-        <td>` + fmt.Sprintf("%t", s.exporterConfig.ReconnectAfterScrape) + `</td>
-      </tr>
-      <tr>
-        <td>Metrics File</td>
-        <td>` + s.exporterConfig.MetricsFile + `</td>
-      </tr>
-      <tr>
-        <td>Date Format</td>
-        <td>` + s.exporterConfig.DateFormat + `</td>
-      </tr>
-      <tr>
-        <td>Disable Empty Metrics Override</td>
-        <td>` + fmt.Sprintf("%t", s.exporterConfig.DisableEmptyMetricsOverride) + `</td>
-      </tr>
-      <tr>
-        <td>Disable Extended Metrics</td>
-        <td>` + fmt.Sprintf("%t", s.exporterConfig.DisableExtendedMetrics) + `</td>
-      </tr>
-      <tr>
-        <td>Web Listen Address</td>
-        <td>` + s.config.ListenAddress + `</td>
-      </tr>
-      <tr>
-        <td>Metrics Path</td>
-        <td>` + s.config.MetricsPath + `</td>
-      </tr>
+      </tr>`)
+		for _, setting := range s.configSettings() {
+			page.WriteString(`
       <tr>
-        <td>Disable Exporter Metrics</td>
-        <td>` + fmt.Sprintf("%t", s.config.DisableExporterMetrics) + `</td>
-      </tr>
+        <td>` + html.EscapeString(setting.Name) + `</td>
+        <td>` + html.EscapeString(setting.String()) + `</td>
+      </tr>`)
+		}
+		page.WriteString(`
+    </table>`)
+	}
+
+	// List every scraped server with its current connection status
+	if s.exporter != nil {
+		srvrmgrs := s.exporter.ServerManagers()
+		serverNames := make([]string, 0, len(srvrmgrs))
+		for name := range srvrmgrs {
+			serverNames = append(serverNames, name)
+		}
+		sort.Strings(serverNames)
+
+		page.WriteString(`
+    <h3>Siebel Servers</h3>
+    <table>
       <tr>
-        <td>Disable Logs</td>
-        <td>` + fmt.Sprintf("%t", s.config.DisableLogs) + `</td>
-      </tr>
+        <th>Server</th>
+        <th>Status</th>
+      </tr>`)
+		for _, name := range serverNames {
+			page.WriteString(`
       <tr>
-        <td>Log Level</td>
-        <td>` + s.logLevel + `</td>
-      </tr>
+        <td>` + html.EscapeString(name) + `</td>
+        <td>` + html.EscapeString(string(srvrmgrs[name].GetStatus())) + `</td>
+      </tr>`)
+		}
+		page.WriteString(`
     </table>`)
+	}
 
 	// Get current memory statistics
 	var memStats runtime.MemStats
@@ -227,7 +722,7 @@ func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 	// Get uptime
 	uptime := time.Since(s.startTime).Round(time.Second)
 
-	html.WriteString(`
+	page.WriteString(`
     <h3>Performance Metrics</h3>
     <table>
       <tr>
@@ -265,14 +760,14 @@ func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Only show logs count if logs are enabled
 	if !s.config.DisableLogs {
-		html.WriteString(`
+		page.WriteString(`
       <tr>
         <td>Logs in Memory</td>
         <td>` + fmt.Sprintf("%d entries", logCount) + `</td>
       </tr>`)
 	}
 
-	html.WriteString(`
+	page.WriteString(`
       <tr>
         <td>Uptime</td>
         <td>` + uptime.String() + `</td>
@@ -296,19 +791,38 @@ func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 </body>
 </html>`)
 
-	w.Write([]byte(html.String()))
+	w.Write([]byte(page.String()))
 }
 
-// logsHandler handles the logs page
-func (s *Server) logsHandler(w http.ResponseWriter, r *http.Request) {
-	// Skip if logs are disabled
-	if s.config.DisableLogs {
-		http.Error(w, "Logs endpoint is disabled", http.StatusNotFound)
-		return
-	}
-
+// filterLogEntries returns the buffered log entries matching the since, until,
+// level and component query parameters of a logs request
+func filterLogEntries(r *http.Request) ([]logger.LogEntry, error) {
 	entries := logger.GetLogEntries()
 
+	// Time range filter
+	now := time.Now()
+	since, err := parseLogTime(r.URL.Query().Get("since"), now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'since' parameter: %v", err)
+	}
+	until, err := parseLogTime(r.URL.Query().Get("until"), now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'until' parameter: %v", err)
+	}
+	if !since.IsZero() || !until.IsZero() {
+		var filtered []logger.LogEntry
+		for _, entry := range entries {
+			if !since.IsZero() && entry.Timestamp.Before(since) {
+				continue
+			}
+			if !until.IsZero() && entry.Timestamp.After(until) {
+				continue
+			}
+			filtered = append(filtered, entry)
+		}
+		entries = filtered
+	}
+
 	// Simple log level filter
 	level := r.URL.Query().Get("level")
 	if level != "" {
@@ -322,6 +836,128 @@ func (s *Server) logsHandler(w http.ResponseWriter, r *http.Request) {
 		entries = filtered
 	}
 
+	// Component filter, the package that logged the entry
+	if component := r.URL.Query().Get("component"); component != "" {
+		var filtered []logger.LogEntry
+		for _, entry := range entries {
+			if entry.Component == component {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	return entries, nil
+}
+
+// logsJSONHandler returns the buffered log entries as JSON array, filtered like
+// /logs. With ?limit=N only the last N matching entries are returned.
+func (s *Server) logsJSONHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := filterLogEntries(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("Invalid 'limit' parameter: %s", value), http.StatusBadRequest)
+			return
+		}
+		if len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+
+	// An empty result is an empty array, not null
+	if entries == nil {
+		entries = []logger.LogEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		logger.Warn("Error writing log entries", zap.Error(err))
+	}
+}
+
+// logsStreamHandler streams new log entries as server-sent events, one JSON
+// object per event. The level and component filters of /logs apply.
+func (s *Server) logsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	level := strings.ToUpper(r.URL.Query().Get("level"))
+	component := r.URL.Query().Get("component")
+
+	bufferSize := s.config.LogStreamBuffer
+	if bufferSize <= 0 {
+		bufferSize = defaultLogStreamBuffer
+	}
+	entries, unsubscribe := logger.Subscribe(bufferSize)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		case entry := <-entries:
+			if (level != "" && entry.Level != level) || (component != "" && entry.Component != component) {
+				continue
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// parseLogTime parses a time filter of the logs endpoint, either an RFC3339 time or
+// a duration like "5m" relative to now. An empty value yields the zero time.
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC3339 time or a duration like 5m")
+	}
+	return now.Add(-d), nil
+}
+
+// logsHandler handles the logs page
+func (s *Server) logsHandler(w http.ResponseWriter, r *http.Request) {
+	// Skip if logs are disabled
+	if s.config.DisableLogs {
+		http.Error(w, "Logs endpoint is disabled", http.StatusNotFound)
+		return
+	}
+
+	entries, err := filterLogEntries(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	component := r.URL.Query().Get("component")
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	fmt.Fprintf(w, `<!DOCTYPE html>
@@ -395,12 +1031,24 @@ func (s *Server) logsHandler(w http.ResponseWriter, r *http.Request) {
     }
   </style>
   <script>
-    function filterLogs(level) {
-      if (level) {
-        window.location.href = '/logs?level=' + level;
+    function setFilter(name, value) {
+      // Keep the other filters when switching one
+      const params = new URLSearchParams(window.location.search);
+      if (value) {
+        params.set(name, value);
       } else {
-        window.location.href = '/logs';
+        params.delete(name);
       }
+      const query = params.toString();
+      window.location.href = query ? window.location.pathname + '?' + query : window.location.pathname;
+    }
+
+    function filterLogs(level) {
+      setFilter('level', level);
+    }
+
+    function filterComponent(component) {
+      setFilter('component', component);
     }
     
     function refreshLogs() {
@@ -428,7 +1076,7 @@ func (s *Server) logsHandler(w http.ResponseWriter, r *http.Request) {
     <h1>Siebel Exporter - Logs</h1>
     
     <div class="nav">
-      <a href="/">← Back to Dashboard</a>
+      <a href="%s">← Back to Dashboard</a>
     </div>
     
     <div class="filters">
@@ -437,6 +1085,20 @@ func (s *Server) logsHandler(w http.ResponseWriter, r *http.Request) {
       <span class="filter-btn" id="filter-info" onclick="filterLogs('INFO')">Info</span>
       <span class="filter-btn" id="filter-warn" onclick="filterLogs('WARN')">Warning</span>
       <span class="filter-btn" id="filter-error" onclick="filterLogs('ERROR')">Error</span>
+      <select id="filter-component" onchange="filterComponent(this.value)">
+        <option value="">All components</option>`, html.EscapeString(s.route("/")))
+
+	for _, c := range logger.GetLogComponents() {
+		selected := ""
+		if c == component {
+			selected = " selected"
+		}
+		fmt.Fprintf(w, `
+        <option value="%s"%s>%s</option>`, html.EscapeString(c), selected, html.EscapeString(c))
+	}
+
+	fmt.Fprintf(w, `
+      </select>
       <button class="refresh-btn" onclick="refreshLogs()">Refresh Logs</button>
     </div>
     
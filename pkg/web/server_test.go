@@ -0,0 +1,588 @@
+package web
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/razims/siebel_prometheus_exporter/pkg/exporter"
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+	"go.uber.org/zap"
+)
+
+// newTestServer creates a server with the default configuration and the given settings
+func newTestServer(config ServerConfig) *Server {
+	if config.MetricsPath == "" {
+		config.MetricsPath = "/metrics"
+	}
+	return NewServer(config, &servermanager.ServerManagerConfig{}, exporter.NewDefaultExporterConfig())
+}
+
+// freeAddress returns a local TCP address nothing listens on
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// startTestServer runs Start in the background, waits until it accepts
+// connections and returns the channel of its result
+func startTestServer(t *testing.T, s *Server, network, address string) <-chan error {
+	t.Helper()
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Start()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial(network, address); err == nil {
+			conn.Close()
+			return result
+		}
+		select {
+		case err := <-result:
+			t.Fatalf("Start returned before listening: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Fatalf("server did not listen on %s", address)
+	return nil
+}
+
+// stopTestServer stops the server and waits for Start to return
+func stopTestServer(t *testing.T, s *Server, result <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Start returned error after Stop: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after Stop")
+	}
+}
+
+// registerTestExporter registers an exporter of a server that is never connected,
+// its scrapes only report the server down
+func registerTestExporter(t *testing.T, s *Server) {
+	t.Helper()
+	e := exporter.NewTargetExporter(servermanager.NewServerManager(servermanager.ServerManagerConfig{Server: "SRV01"}), s.exporterConfig)
+	t.Cleanup(e.Close)
+	s.RegisterExporter(e)
+}
+
+// runTestServer starts the server on a free local address, stops it at the end of
+// the test and returns its base URL
+func runTestServer(t *testing.T, s *Server) string {
+	t.Helper()
+	address := freeAddress(t)
+	s.config.ListenAddress = address
+	s.httpServer.Addr = address
+	result := startTestServer(t, s, "tcp", address)
+	t.Cleanup(func() {
+		stopTestServer(t, s, result)
+	})
+	return "http://" + address
+}
+
+// get requests a URL with the given headers and returns the response with its body read
+func get(t *testing.T, url string, header map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response of %s: %v", url, err)
+	}
+	return resp, string(body)
+}
+
+func TestStopReturnsAfterShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		request bool
+	}{
+		{name: "idle"},
+		{name: "after serving a request", request: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := freeAddress(t)
+			s := newTestServer(ServerConfig{ListenAddress: address})
+			result := startTestServer(t, s, "tcp", address)
+
+			if tt.request {
+				resp, err := http.Get("http://" + address + "/metrics")
+				if err != nil {
+					t.Fatalf("request before Stop failed: %v", err)
+				}
+				resp.Body.Close()
+			}
+
+			stopTestServer(t, s, result)
+
+			if _, err := http.Get("http://" + address + "/metrics"); err == nil {
+				t.Error("server still answers after Stop")
+			}
+		})
+	}
+}
+
+func TestHomePageEscapesValues(t *testing.T) {
+	const script = `<script>alert(1)</script>`
+
+	tests := []struct {
+		name      string
+		smConfig  servermanager.ServerManagerConfig
+		config    ServerConfig
+		withTable bool
+	}{
+		{name: "server name", smConfig: servermanager.ServerManagerConfig{Server: script}, withTable: true},
+		{name: "gateway", smConfig: servermanager.ServerManagerConfig{Gateway: script}},
+		{name: "user", smConfig: servermanager.ServerManagerConfig{User: script}},
+		{name: "extra arguments", smConfig: servermanager.ServerManagerConfig{ExtraArgs: []string{"-l", script}}},
+		{name: "metrics path", config: ServerConfig{MetricsPath: "/" + script}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config.MetricsPath == "" {
+				tt.config.MetricsPath = "/metrics"
+			}
+			s := NewServer(tt.config, &tt.smConfig, exporter.NewDefaultExporterConfig())
+			if tt.withTable {
+				s.exporter = exporter.NewTargetExporter(servermanager.NewServerManager(tt.smConfig), s.exporterConfig)
+			}
+
+			recorder := httptest.NewRecorder()
+			s.homeHandler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			body := recorder.Body.String()
+
+			if strings.Contains(body, script) {
+				t.Errorf("home page contains the unescaped value %q", script)
+			}
+			escaped := html.EscapeString(script)
+			if !strings.Contains(body, escaped) {
+				t.Errorf("home page does not contain the escaped value %q", escaped)
+			}
+			if tt.withTable && strings.Count(body, escaped) < 2 {
+				t.Errorf("server name is escaped in %d places, want the configuration and the servers table", strings.Count(body, escaped))
+			}
+		})
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	const token = "t0ken"
+
+	initial := logger.GetLevel()
+	t.Cleanup(func() {
+		logger.SetLevel(initial)
+	})
+	logger.SetLevel(logger.InfoLevel)
+
+	s := newTestServer(ServerConfig{LogLevelToken: token})
+
+	// The steps run in order, each starting from the level the previous one left
+	steps := []struct {
+		name       string
+		method     string
+		body       string
+		token      string
+		wantStatus int
+		wantLevel  logger.Level
+	}{
+		{name: "read level", method: http.MethodGet, wantStatus: http.StatusOK, wantLevel: logger.InfoLevel},
+		{name: "switch to debug", method: http.MethodPut, body: "debug", token: token, wantStatus: http.StatusOK, wantLevel: logger.DebugLevel},
+		{name: "read debug level", method: http.MethodGet, wantStatus: http.StatusOK, wantLevel: logger.DebugLevel},
+		{name: "switch back to info", method: http.MethodPut, body: "info\n", token: token, wantStatus: http.StatusOK, wantLevel: logger.InfoLevel},
+		{name: "switch to debug by form value", method: http.MethodPut, body: "level=debug", token: token, wantStatus: http.StatusOK, wantLevel: logger.DebugLevel},
+		{name: "unknown level", method: http.MethodPut, body: "verbose", token: token, wantStatus: http.StatusBadRequest, wantLevel: logger.DebugLevel},
+		{name: "missing token", method: http.MethodPut, body: "info", wantStatus: http.StatusUnauthorized, wantLevel: logger.DebugLevel},
+		{name: "wrong token", method: http.MethodPut, body: "info", token: "guess", wantStatus: http.StatusUnauthorized, wantLevel: logger.DebugLevel},
+		{name: "unsupported method", method: http.MethodPost, body: "info", token: token, wantStatus: http.StatusMethodNotAllowed, wantLevel: logger.DebugLevel},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			req := httptest.NewRequest(step.method, "/-/log-level", strings.NewReader(step.body))
+			if strings.HasPrefix(step.body, "level=") {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if step.token != "" {
+				req.Header.Set("Authorization", "Bearer "+step.token)
+			}
+			recorder := httptest.NewRecorder()
+			s.logLevelHandler(recorder, req)
+
+			if recorder.Code != step.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, step.wantStatus, recorder.Body)
+			}
+			if recorder.Code == http.StatusOK && strings.TrimSpace(recorder.Body.String()) != string(step.wantLevel) {
+				t.Errorf("body = %q, want %q", recorder.Body, step.wantLevel)
+			}
+			if got := logger.GetLevel(); got != step.wantLevel {
+				t.Errorf("GetLevel() = %s, want %s", got, step.wantLevel)
+			}
+			if got, want := logger.Log.Core().Enabled(zap.DebugLevel), step.wantLevel == logger.DebugLevel; got != want {
+				t.Errorf("debug logging enabled = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPauseResumeHandlers(t *testing.T) {
+	const token = "t0ken"
+
+	tests := []struct {
+		name         string
+		controlToken string
+		path         string
+		method       string
+		token        string
+		wantStatus   int
+		wantPaused   bool
+	}{
+		{name: "pause", controlToken: token, path: "/-/pause", method: http.MethodPost, token: token, wantStatus: http.StatusOK, wantPaused: true},
+		{name: "resume", controlToken: token, path: "/-/resume", method: http.MethodPost, token: token, wantStatus: http.StatusOK},
+		{name: "pause without token", controlToken: token, path: "/-/pause", method: http.MethodPost, wantStatus: http.StatusUnauthorized},
+		{name: "pause with wrong token", controlToken: token, path: "/-/pause", method: http.MethodPost, token: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "pause without configured token", path: "/-/pause", method: http.MethodPost, wantStatus: http.StatusUnauthorized},
+		{name: "resume without token", controlToken: token, path: "/-/resume", method: http.MethodPost, wantStatus: http.StatusUnauthorized, wantPaused: true},
+		{name: "pause by GET", controlToken: token, path: "/-/pause", method: http.MethodGet, token: token, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(ServerConfig{ControlToken: tt.controlToken})
+			registerTestExporter(t, s)
+			// Resuming needs a paused exporter
+			if tt.path == "/-/resume" {
+				s.exporter.Pause()
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			if tt.path == "/-/resume" {
+				s.resumeHandler(recorder, req)
+			} else {
+				s.pauseHandler(recorder, req)
+			}
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if got := s.exporter.IsPaused(); got != tt.wantPaused {
+				t.Errorf("IsPaused() = %v, want %v", got, tt.wantPaused)
+			}
+		})
+	}
+}
+
+func TestLogsJSONFilters(t *testing.T) {
+	// Entries logged by other tests are older than start and filtered out by since
+	start := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	logger.AddLogEntry("INFO", "first")
+	time.Sleep(10 * time.Millisecond)
+	middle := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	logger.AddLogEntry("WARN", "second")
+	logger.AddLogEntry("ERROR", "third")
+	logger.AddLogEntry("INFO", "fourth")
+
+	since := "since=" + url.QueryEscape(start.Format(time.RFC3339Nano))
+	sinceMiddle := "since=" + url.QueryEscape(middle.Format(time.RFC3339Nano))
+
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantMessages []string
+	}{
+		{name: "since", query: since, wantStatus: http.StatusOK, wantMessages: []string{"first", "second", "third", "fourth"}},
+		{name: "since later entry", query: sinceMiddle, wantStatus: http.StatusOK, wantMessages: []string{"second", "third", "fourth"}},
+		{name: "level", query: since + "&level=warn", wantStatus: http.StatusOK, wantMessages: []string{"second"}},
+		{name: "level and limit", query: since + "&level=INFO&limit=1", wantStatus: http.StatusOK, wantMessages: []string{"fourth"}},
+		{name: "since and level", query: sinceMiddle + "&level=info", wantStatus: http.StatusOK, wantMessages: []string{"fourth"}},
+		{name: "since and limit", query: sinceMiddle + "&limit=2", wantStatus: http.StatusOK, wantMessages: []string{"third", "fourth"}},
+		{name: "since, level and limit", query: since + "&level=info&limit=5", wantStatus: http.StatusOK, wantMessages: []string{"first", "fourth"}},
+		{name: "component", query: since + "&component=web", wantStatus: http.StatusOK, wantMessages: []string{"first", "second", "third", "fourth"}},
+		{name: "other component", query: since + "&component=exporter", wantStatus: http.StatusOK, wantMessages: []string{}},
+		{name: "limit 0", query: since + "&limit=0", wantStatus: http.StatusOK, wantMessages: []string{}},
+		{name: "invalid since", query: "since=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: since + "&limit=-1", wantStatus: http.StatusBadRequest},
+	}
+
+	s := newTestServer(ServerConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.logsJSONHandler(recorder, httptest.NewRequest(http.MethodGet, "/logs.json?"+tt.query, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var entries []logger.LogEntry
+			if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+				t.Fatalf("invalid JSON: %v\n%s", err, recorder.Body)
+			}
+			if entries == nil {
+				t.Fatalf("body = %s, want an array", recorder.Body)
+			}
+			messages := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				messages = append(messages, entry.Message)
+			}
+			if !slices.Equal(messages, tt.wantMessages) {
+				t.Errorf("messages = %q, want %q", messages, tt.wantMessages)
+			}
+		})
+	}
+}
+
+func TestExemplarsOnlyInOpenMetrics(t *testing.T) {
+	const openMetrics = "application/openmetrics-text; version=1.0.0"
+
+	tests := []struct {
+		name          string
+		exemplars     bool
+		accept        string
+		wantType      string
+		wantExemplars bool
+	}{
+		{name: "OpenMetrics", exemplars: true, accept: openMetrics, wantType: "application/openmetrics-text", wantExemplars: true},
+		{name: "text format", exemplars: true, accept: "text/plain;version=0.0.4", wantType: "text/plain"},
+		{name: "no accept header", exemplars: true, wantType: "text/plain"},
+		{name: "OpenMetrics with exemplars disabled", accept: openMetrics, wantType: "application/openmetrics-text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smConfig := &servermanager.ServerManagerConfig{Server: "SRV01"}
+			exporterConfig := exporter.NewDefaultExporterConfig()
+			exporterConfig.ServerManagerConfig = smConfig
+			exporterConfig.Exemplars = tt.exemplars
+			exporterConfig.ExemplarLabels = map[string]string{"cluster": "prod"}
+
+			s := NewServer(ServerConfig{MetricsPath: "/metrics", DisableExporterMetrics: true}, smConfig, exporterConfig)
+			e := exporter.NewTargetExporter(servermanager.NewServerManager(*smConfig), exporterConfig)
+			t.Cleanup(e.Close)
+			s.RegisterExporter(e)
+			base := runTestServer(t, s)
+
+			header := map[string]string{}
+			if tt.accept != "" {
+				header["Accept"] = tt.accept
+			}
+			resp, body := get(t, base+"/metrics", header)
+
+			if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			var scrapes string
+			for _, line := range strings.Split(body, "\n") {
+				if strings.HasPrefix(line, "siebel_exporter_scrapes_total") {
+					scrapes = line
+				}
+			}
+			if scrapes == "" {
+				t.Fatalf("siebel_exporter_scrapes_total missing:\n%s", body)
+			}
+			hasExemplar := strings.Contains(scrapes, " # {")
+			if hasExemplar != tt.wantExemplars {
+				t.Errorf("exemplar present = %v, want %v: %s", hasExemplar, tt.wantExemplars, scrapes)
+			}
+			if tt.wantExemplars && (!strings.Contains(scrapes, `scrape_id="`) || !strings.Contains(scrapes, `cluster="prod"`)) {
+				t.Errorf("exemplar without scrape_id and configured labels: %s", scrapes)
+			}
+		})
+	}
+}
+
+func TestPprofRoutes(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      ServerConfig
+		token       string
+		wantStatus  int
+		wantProfile bool
+	}{
+		{name: "disabled", wantStatus: http.StatusOK},
+		{name: "enabled", config: ServerConfig{EnablePprof: true}, wantStatus: http.StatusOK, wantProfile: true},
+		{name: "enabled with token, no token given", config: ServerConfig{EnablePprof: true, PprofToken: "t0ken"}, wantStatus: http.StatusUnauthorized},
+		{name: "enabled with token", config: ServerConfig{EnablePprof: true, PprofToken: "t0ken"}, token: "t0ken", wantStatus: http.StatusOK, wantProfile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := runTestServer(t, newTestServer(tt.config))
+
+			header := map[string]string{}
+			if tt.token != "" {
+				header["Authorization"] = "Bearer " + tt.token
+			}
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline"} {
+				resp, body := get(t, base+path, header)
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, tt.wantStatus)
+				}
+				// Unknown paths fall through to the home page
+				if isProfile := !strings.Contains(body, "<h1>Siebel Exporter</h1>") && resp.StatusCode == http.StatusOK; isProfile != tt.wantProfile {
+					t.Errorf("GET %s served by pprof = %v, want %v", path, isProfile, tt.wantProfile)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutePrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{name: "prefix", prefix: "/siebel"},
+		{name: "prefix with trailing slash", prefix: "/siebel/"},
+		{name: "prefix without leading slash", prefix: "siebel"},
+	}
+
+	// The scrape handler answers 400 without target, which still shows the route is served
+	routes := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/", http.StatusOK},
+		{"/metrics", http.StatusOK},
+		{"/metrics/names", http.StatusOK},
+		{"/config", http.StatusOK},
+		{"/-/log-level", http.StatusOK},
+		{"/logs", http.StatusOK},
+		{"/logs.json", http.StatusOK},
+		{"/discovery", http.StatusOK},
+		{"/scrape", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(ServerConfig{RoutePrefix: tt.prefix, EnableMultiTarget: true})
+			registerTestExporter(t, s)
+			base := runTestServer(t, s)
+
+			for _, route := range routes {
+				resp, body := get(t, base+"/siebel"+route.path, nil)
+				if resp.StatusCode != route.wantStatus {
+					t.Errorf("GET /siebel%s status = %d, want %d: %s", route.path, resp.StatusCode, route.wantStatus, body)
+				}
+				// Unknown paths fall through to the home page
+				if route.path != "/" && strings.Contains(body, "<h1>Siebel Exporter</h1>") {
+					t.Errorf("GET /siebel%s served the home page", route.path)
+				}
+			}
+
+			resp, _ := get(t, base+"/metrics", nil)
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("GET /metrics without prefix status = %d, want 404", resp.StatusCode)
+			}
+
+			_, home := get(t, base+"/siebel/", nil)
+			for _, link := range []string{`href="/siebel/metrics"`, `href="/siebel/logs"`} {
+				if !strings.Contains(home, link) {
+					t.Errorf("home page has no link %s", link)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsCompression(t *testing.T) {
+	tests := []struct {
+		name               string
+		disableCompression bool
+		acceptEncoding     string
+		wantEncoding       string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{name: "gzip preferred", acceptEncoding: "br;q=0.5, gzip;q=1.0", wantEncoding: "gzip"},
+		{name: "no compression requested", acceptEncoding: "identity"},
+		{name: "compression disabled", disableCompression: true, acceptEncoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(ServerConfig{DisableCompression: tt.disableCompression})
+			registerTestExporter(t, s)
+			base := runTestServer(t, s)
+
+			// Setting Accept-Encoding keeps the client from decompressing transparently
+			resp, body := get(t, base+"/metrics", map[string]string{
+				"Accept-Encoding": tt.acceptEncoding,
+				"Accept":          "text/plain;version=0.0.4",
+			})
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+
+			exposition := io.Reader(strings.NewReader(body))
+			if tt.wantEncoding == "gzip" {
+				reader, err := gzip.NewReader(exposition)
+				if err != nil {
+					t.Fatalf("body is not gzip: %v", err)
+				}
+				defer reader.Close()
+				exposition = reader
+			}
+
+			var parser expfmt.TextParser
+			families, err := parser.TextToMetricFamilies(exposition)
+			if err != nil {
+				t.Fatalf("invalid exposition: %v", err)
+			}
+			for _, name := range []string{"siebel_exporter_scrapes_total", "go_goroutines"} {
+				if _, found := families[name]; !found {
+					t.Errorf("%s missing from %d metric families", name, len(families))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"sync"
+
+	"github.com/razims/siebel_prometheus_exporter/pkg/logger"
+	"github.com/razims/siebel_prometheus_exporter/pkg/servermanager"
+	"go.uber.org/zap"
+)
+
+// pooledTarget is a srvrmgr session for a target scraped via the multi-target endpoint
+type pooledTarget struct {
+	srvrmgr *servermanager.ServerManager
+	mu      sync.Mutex // serializes scrapes of the same target
+}
+
+// targetPool keeps srvrmgr sessions for targets scraped via the multi-target endpoint,
+// so that consecutive scrapes of the same target reuse the connection
+type targetPool struct {
+	mu      sync.Mutex
+	base    servermanager.ServerManagerConfig
+	targets map[string]*pooledTarget
+}
+
+// newTargetPool creates a pool whose sessions use the credentials and settings of base
+func newTargetPool(base servermanager.ServerManagerConfig) *targetPool {
+	return &targetPool{
+		base:    base,
+		targets: make(map[string]*pooledTarget),
+	}
+}
+
+// acquire returns a connected session for the target, creating it on first use.
+// The returned target is locked and must be released with release.
+func (p *targetPool) acquire(gateway, enterprise, server string) (*pooledTarget, error) {
+	key := gateway + "/" + enterprise + "/" + server
+
+	p.mu.Lock()
+	t, exists := p.targets[key]
+	if !exists {
+		config := p.base
+		config.Gateway = gateway
+		config.Enterprise = enterprise
+		config.Server = server
+
+		logger.Info("Creating srvrmgr session for scrape target",
+			zap.String("gateway", gateway),
+			zap.String("enterprise", enterprise),
+			zap.String("server", server))
+
+		t = &pooledTarget{srvrmgr: servermanager.NewServerManager(config)}
+		p.targets[key] = t
+	}
+	p.mu.Unlock()
+
+	t.mu.Lock()
+
+	// Connect new sessions and sessions that lost their connection
+	status := t.srvrmgr.GetStatus()
+	if status == servermanager.Disconnected || status == servermanager.ConnectionError {
+		if err := t.srvrmgr.Connect(); err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// release unlocks a target acquired with acquire
+func (p *targetPool) release(t *pooledTarget) {
+	t.mu.Unlock()
+}
+
+// close disconnects all pooled sessions
+func (p *targetPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, t := range p.targets {
+		if err := t.srvrmgr.Disconnect(); err != nil {
+			logger.Warn("Error disconnecting scrape target", zap.String("target", key), zap.Error(err))
+		}
+		delete(p.targets, key)
+	}
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// tlsConfig returns the TLS configuration of the web server, nil to serve plain
+// HTTP. With a client CA file every client must present a certificate signed by
+// one of its CAs, for all endpoints.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	if s.config.TLSCertFile == "" && s.config.TLSKeyFile == "" {
+		if s.config.TLSClientCAFile != "" {
+			return nil, errors.New("client certificate verification requires a TLS certificate and key")
+		}
+		return nil, nil
+	}
+	if s.config.TLSCertFile == "" || s.config.TLSKeyFile == "" {
+		return nil, errors.New("TLS requires both a certificate and a key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.config.TLSCertFile, s.config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if s.config.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(s.config.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in client CA file %s", s.config.TLSClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}